@@ -45,11 +45,20 @@ func ConnectDatabase() {
 
 	DB = database
 
+	if err := MigrateSchema(DB); err != nil {
+		log.Fatal("Error al migrar la base de datos:", err)
+	}
+
+	log.Println("Base de datos conectada y migrada exitosamente")
+}
+
+// MigrateSchema creates and updates the tables of every model. Changes
+// AutoMigrate cannot make are goose migrations, run by RunMigrations.
+func MigrateSchema(db *gorm.DB) error {
 	// Auto migrate the schema
-	err = DB.AutoMigrate(
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Credit{},
-		&models.CreditHistory{},
 		&models.Space{},
 		&models.Schedule{},
 		&models.Reservation{},
@@ -60,18 +69,42 @@ func ConnectDatabase() {
 		&models.ClosedDate{},
 		&models.CreditTransaction{},
 		&models.ExternalClient{},
+		&models.ReservationSeries{},
+		&models.WaitlistEntry{},
+		&models.CancellationPolicy{},
+		&models.CancellationPolicyTier{},
+		&models.SpacePricingRule{},
+		&models.JobRun{},
+		&models.JobLock{},
+		&models.ReservationHistory{},
+		&models.NoShowPolicy{},
+		&models.PenaltyAppeal{},
+		&models.PenaltyPolicy{},
+		&models.CreditPackage{},
+		&models.MembershipPlan{},
+		&models.Membership{},
+		&models.MembershipPeriod{},
+		&models.PromoCode{},
+		&models.PromoRedemption{},
+		&models.CreditHold{},
+		&models.IdempotencyKey{},
+		&models.Checkout{},
+		&models.PaymentWebhookEvent{},
+		&models.Invoice{},
+		&models.CashShift{},
+		&models.CashShiftTotal{},
 	)
 	if err != nil {
-		log.Fatal("Error al migrar la base de datos:", err)
+		return err
 	}
 
 	// Run manual migration to make user_id nullable in reservations table
-	err = migrateReservationsUserID(DB)
+	err = migrateReservationsUserID(db)
 	if err != nil {
 		log.Printf("Warning: Could not migrate reservations user_id column: %v", err)
 	}
 
-	log.Println("Base de datos conectada y migrada exitosamente")
+	return nil
 }
 
 // migrateReservationsUserID makes the user_id column nullable in reservations table
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/IkingariSolorzano/omma-be/config"
@@ -20,6 +21,8 @@ type AdminController struct {
 	authService        *services.AuthService
 	creditService      *services.CreditService
 	reservationService *services.ReservationService
+	pricingService     *services.PricingService
+	noShowService      *services.NoShowService
 }
 
 // Per-lot handlers
@@ -29,7 +32,8 @@ func (ac *AdminController) ExtendCreditLot(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := ac.creditService.ExtendCreditLot(req.CreditID, req.Days); err != nil {
+	adminID, _ := c.Get("user_id")
+	if err := ac.creditService.ExtendCreditLot(req.CreditID, req.Days, adminID.(uint)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -47,7 +51,8 @@ func (ac *AdminController) ReactivateCreditLot(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de fecha inválido. Use YYYY-MM-DD"})
 		return
 	}
-	if err := ac.creditService.ReactivateCreditLot(req.CreditID, newExpiry); err != nil {
+	adminID, _ := c.Get("user_id")
+	if err := ac.creditService.ReactivateCreditLot(req.CreditID, newExpiry, adminID.(uint)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -60,7 +65,8 @@ func (ac *AdminController) TransferFromCreditLot(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := ac.creditService.TransferFromLot(req.CreditID, req.ToUserID, req.Amount); err != nil {
+	adminID, _ := c.Get("user_id")
+	if err := ac.creditService.TransferFromLot(req.CreditID, req.ToUserID, req.Amount, adminID.(uint)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -73,7 +79,8 @@ func (ac *AdminController) DeductFromCreditLot(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := ac.creditService.AdminDeductFromLot(req.CreditID, req.Amount); err != nil {
+	adminID, _ := c.Get("user_id")
+	if err := ac.creditService.AdminDeductFromLot(req.CreditID, req.Amount, adminID.(uint)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -85,6 +92,8 @@ func NewAdminController() *AdminController {
 		authService:        services.NewAuthService(),
 		creditService:      services.NewCreditService(),
 		reservationService: services.NewReservationService(),
+		pricingService:     services.NewPricingService(),
+		noShowService:      services.NewNoShowService(),
 	}
 }
 
@@ -103,18 +112,38 @@ func (ac *AdminController) GetUserCreditLots(c *gin.Context) {
 		return
 	}
 
-	activeCredits, err := ac.creditService.GetActiveCredits(uint(uid))
+	balance, err := ac.creditService.GetBalance(uint(uid))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los créditos activos del usuario"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"credits":        credits,
-		"active_credits": activeCredits,
+		"credits":           credits,
+		"active_credits":    balance.Total,
+		"available_credits": balance.Available,
+		"held_credits":      balance.Held,
 	})
 }
 
+// GetUserCreditLedger returns a user's credit movements and ledger balance,
+// optionally as of the end of a given day (?as_of=YYYY-MM-DD)
+func (ac *AdminController) GetUserCreditLedger(c *gin.Context) {
+	uid, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario invalido"})
+		return
+	}
+
+	asOf, err := parseAsOf(c.Query("as_of"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondCreditLedger(c, ac.creditService, uint(uid), asOf)
+}
+
 type CreateUserRequest struct {
 	Email       string          `json:"email" binding:"required,email"`
 	Password    string          `json:"password" binding:"required,min=6"`
@@ -177,6 +206,31 @@ type CreateSpaceRequest struct {
 	Description string `json:"description"`
 	Capacity    int    `json:"capacity"`
 	CostCredits int    `json:"cost_credits"`
+
+	// Pricing (defaults to a flat cost per reservation)
+	PricingMode        models.PricingMode  `json:"pricing_mode"`
+	BlockMinutes       int                 `json:"block_minutes"`
+	MinBillableMinutes int                 `json:"min_billable_minutes"`
+	RoundingMode       models.RoundingMode `json:"rounding_mode"`
+}
+
+// applyPricing copies the pricing settings of the request to the space,
+// filling in defaults for the ones not provided
+func (req CreateSpaceRequest) applyPricing(space *models.Space) {
+	space.PricingMode = req.PricingMode
+	space.BlockMinutes = req.BlockMinutes
+	space.MinBillableMinutes = req.MinBillableMinutes
+	space.RoundingMode = req.RoundingMode
+
+	if space.PricingMode == "" {
+		space.PricingMode = models.PricingFlat
+	}
+	if space.BlockMinutes == 0 {
+		space.BlockMinutes = 60
+	}
+	if space.RoundingMode == "" {
+		space.RoundingMode = models.RoundUp
+	}
 }
 
 type CreateScheduleRequest struct {
@@ -211,9 +265,10 @@ type CreateClosedDateRequest struct {
 }
 
 type CancelReservationRequest struct {
-	Reason  string  `json:"reason" binding:"required"`
-	Penalty float64 `json:"penalty"`
-	Notes   string  `json:"notes"`
+	Reason      string  `json:"reason" binding:"required"`
+	Penalty     float64 `json:"penalty"`
+	Notes       string  `json:"notes"`
+	ApplyPolicy bool    `json:"apply_policy"` // opcional, reembolsa según la política de cancelación en lugar de reembolsar todo
 }
 
 type UpdateReservationRequest struct {
@@ -221,6 +276,7 @@ type UpdateReservationRequest struct {
 	StartTime *string `json:"start_time"`
 	EndTime   *string `json:"end_time"`
 	Notes     *string `json:"notes"`
+	Override  bool    `json:"override"` // Book over failed hours/schedule rules
 }
 
 func (ac *AdminController) CreateUser(c *gin.Context) {
@@ -260,8 +316,11 @@ func (ac *AdminController) GetUsers(c *gin.Context) {
 		models.User
 		ActiveCredits int `json:"active_credits"`
 		TotalCredits  int `json:"total_credits"`
+		NoShowCount   int `json:"no_show_count"`
 	}
 
+	noShowCounts := ac.noShowService.GetNoShowCounts()
+
 	var usersWithCredits []UserWithCredits
 	for _, user := range users {
 		activeCredits, totalCredits := ac.creditService.GetUserCreditCounts(user.ID)
@@ -269,6 +328,7 @@ func (ac *AdminController) GetUsers(c *gin.Context) {
 			User:          user,
 			ActiveCredits: activeCredits,
 			TotalCredits:  totalCredits,
+			NoShowCount:   noShowCounts[user.ID],
 		}
 		usersWithCredits = append(usersWithCredits, userWithCredits)
 	}
@@ -284,25 +344,18 @@ func (ac *AdminController) AddCredits(c *gin.Context) {
 	}
 
 	adminID, _ := c.Get("user_id")
-	notes := fmt.Sprintf("Créditos agregados por administrador ID: %d", adminID)
+	admin := adminID.(uint)
 
-	credit, err := ac.creditService.AddCredits(req.UserID, req.Amount, "Créditos agregados por administrador", 0, notes)
+	credit, err := ac.creditService.AddCredits(config.DB, req.UserID, req.Amount, services.CreditEntry{
+		Type:    models.TransactionTypeGrant,
+		Reason:  "Créditos agregados por administrador",
+		AdminID: &admin,
+	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Record in credit history for dashboard statistics
-	creditHistory := models.CreditHistory{
-		UserID:      req.UserID,
-		AdminID:     adminID.(uint),
-		Amount:      req.Amount,
-		Action:      "granted",
-		Description: "Créditos agregados por administrador",
-		Notes:       notes,
-	}
-	config.DB.Create(&creditHistory)
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Creditos agregados exitosamente",
 		"credit":  credit,
@@ -315,7 +368,8 @@ func (ac *AdminController) ExtendCreditExpiry(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := ac.creditService.ExtendExpiry(req.UserID, req.Days); err != nil {
+	adminID, _ := c.Get("user_id")
+	if err := ac.creditService.ExtendExpiry(req.UserID, req.Days, adminID.(uint)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -333,7 +387,8 @@ func (ac *AdminController) ReactivateExpiredCredits(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de fecha inválido. Use YYYY-MM-DD"})
 		return
 	}
-	_, err = ac.creditService.ReactivateExpired(req.UserID, newExpiry)
+	adminID, _ := c.Get("user_id")
+	_, err = ac.creditService.ReactivateExpired(req.UserID, newExpiry, adminID.(uint))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -347,7 +402,8 @@ func (ac *AdminController) TransferCredits(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := ac.creditService.TransferCredits(req.FromUserID, req.ToUserID, req.Amount); err != nil {
+	adminID, _ := c.Get("user_id")
+	if err := ac.creditService.TransferCredits(req.FromUserID, req.ToUserID, req.Amount, adminID.(uint)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -360,7 +416,8 @@ func (ac *AdminController) DeductCredits(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := ac.creditService.AdminDeduct(req.UserID, req.Amount); err != nil {
+	adminID, _ := c.Get("user_id")
+	if err := ac.creditService.AdminDeduct(req.UserID, req.Amount, adminID.(uint)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -389,6 +446,12 @@ func (ac *AdminController) CreateSpace(c *gin.Context) {
 		space.CostCredits = 6
 	}
 
+	req.applyPricing(&space)
+	if err := ac.pricingService.ValidatePricing(&space); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := config.DB.Create(&space).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -443,6 +506,12 @@ func (ac *AdminController) UpdateSpace(c *gin.Context) {
 		space.CostCredits = 6
 	}
 
+	req.applyPricing(&space)
+	if err := ac.pricingService.ValidatePricing(&space); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := config.DB.Save(&space).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar el espacio"})
 		return
@@ -470,7 +539,7 @@ func (ac *AdminController) DeleteSpace(c *gin.Context) {
 
 	// Check if space has active reservations
 	var reservationCount int64
-	config.DB.Model(&models.Reservation{}).Where("space_id = ? AND status != ?", spaceID, models.StatusCancelled).Count(&reservationCount)
+	config.DB.Model(&models.Reservation{}).Where("space_id = ? AND status NOT IN (?, ?)", spaceID, models.StatusCancelled, models.StatusRejected).Count(&reservationCount)
 	if reservationCount > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No se puede eliminar el espacio porque tiene reservas activas"})
 		return
@@ -650,6 +719,23 @@ func (ac *AdminController) GetReservationDetails(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"reservation": reservation})
 }
 
+// GetReservationHistory returns every status transition of a reservation
+func (ac *AdminController) GetReservationHistory(c *gin.Context) {
+	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de reserva inválido"})
+		return
+	}
+
+	history, err := ac.reservationService.GetHistory(uint(reservationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
 func (ac *AdminController) CancelReservation(c *gin.Context) {
 	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -665,7 +751,7 @@ func (ac *AdminController) CancelReservation(c *gin.Context) {
 
 	adminID, _ := c.Get("user_id")
 
-	err = ac.reservationService.AdminCancelReservation(uint(reservationID), adminID.(uint), req.Reason, req.Penalty, req.Notes)
+	cancellation, err := ac.reservationService.AdminCancelReservation(uint(reservationID), adminID.(uint), req.Reason, req.Penalty, req.Notes, req.ApplyPolicy)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -696,7 +782,10 @@ func (ac *AdminController) CancelReservation(c *gin.Context) {
 		config.WSHub.BroadcastMessage(websocket.EventReservationCancelled, event)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Reserva cancelada exitosamente"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Reserva cancelada exitosamente",
+		"cancellation": cancellation,
+	})
 }
 
 func (ac *AdminController) ApproveReservation(c *gin.Context) {
@@ -708,38 +797,158 @@ func (ac *AdminController) ApproveReservation(c *gin.Context) {
 
 	adminID, _ := c.Get("user_id")
 
-	err = ac.reservationService.ApproveReservation(uint(reservationID), adminID.(uint))
+	quote, err := ac.reservationService.ApproveReservation(uint(reservationID), adminID.(uint))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Broadcast WebSocket event
-	if config.WSHub != nil {
-		var reservation models.Reservation
-		config.DB.Preload("Space").Preload("User").Preload("ExternalClient").First(&reservation, reservationID)
-		
-		userName := "Cliente"
-		if reservation.User != nil {
-			userName = reservation.User.Name
-		} else if reservation.ExternalClient != nil {
-			userName = reservation.ExternalClient.Name
+	broadcastReservationApproved(uint(reservationID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reserva aprobada exitosamente",
+		"quote":   quote,
+	})
+}
+
+// broadcastReservationApproved sends the approval WebSocket event
+func broadcastReservationApproved(reservationID uint) {
+	if config.WSHub == nil {
+		return
+	}
+
+	var reservation models.Reservation
+	config.DB.Preload("Space").Preload("User").Preload("ExternalClient").First(&reservation, reservationID)
+
+	userName := "Cliente"
+	if reservation.User != nil {
+		userName = reservation.User.Name
+	} else if reservation.ExternalClient != nil {
+		userName = reservation.ExternalClient.Name
+	}
+
+	event := websocket.ReservationEvent{
+		ReservationID: reservation.ID,
+		SpaceID:       reservation.SpaceID,
+		SpaceName:     reservation.Space.Name,
+		UserName:      userName,
+		StartTime:     reservation.StartTime.Format(time.RFC3339),
+		EndTime:       reservation.EndTime.Format(time.RFC3339),
+		Status:        string(reservation.Status),
+		Action:        "approved",
+		UserID:        reservation.UserID,
+	}
+	config.WSHub.BroadcastMessage(websocket.EventReservationApproved, event)
+}
+
+type RejectReservationRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// RejectReservation declines a pending reservation with a reason that is sent
+// to the professional. Credits are not touched.
+func (ac *AdminController) RejectReservation(c *gin.Context) {
+	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de la reserva invalido"})
+		return
+	}
+
+	var req RejectReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	reservation, err := ac.reservationService.RejectReservation(uint(reservationID), adminID.(uint), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Reserva rechazada exitosamente",
+		"reservation": reservation,
+	})
+}
+
+type BatchReservationRequest struct {
+	ReservationIDs []uint `json:"reservation_ids" binding:"required,min=1"`
+	Reason         string `json:"reason"` // Required when rejecting
+}
+
+// BatchResult is the outcome of a batch action for one reservation
+type BatchResult struct {
+	ReservationID uint   `json:"reservation_id"`
+	Success       bool   `json:"success"`
+	Error         string `json:"error,omitempty"`
+}
+
+// BatchApproveReservations approves several pending reservations. Each one is
+// processed independently, so a failure does not stop the rest.
+func (ac *AdminController) BatchApproveReservations(c *gin.Context) {
+	var req BatchReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	results := make([]BatchResult, len(req.ReservationIDs))
+	approved := 0
+	for i, reservationID := range req.ReservationIDs {
+		results[i].ReservationID = reservationID
+		if _, err := ac.reservationService.ApproveReservation(reservationID, adminID.(uint)); err != nil {
+			results[i].Error = err.Error()
+			continue
 		}
-		
-		event := websocket.ReservationEvent{
-			ReservationID: reservation.ID,
-			SpaceID:       reservation.SpaceID,
-			SpaceName:     reservation.Space.Name,
-			UserName:      userName,
-			StartTime:     reservation.StartTime.Format(time.RFC3339),
-			EndTime:       reservation.EndTime.Format(time.RFC3339),
-			Status:        string(reservation.Status),
-			Action:        "approved",
+		results[i].Success = true
+		approved++
+		broadcastReservationApproved(reservationID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("%d de %d reservas aprobadas", approved, len(req.ReservationIDs)),
+		"results":  results,
+		"approved": approved,
+	})
+}
+
+// BatchRejectReservations rejects several pending reservations with the same
+// reason. Each one is processed independently.
+func (ac *AdminController) BatchRejectReservations(c *gin.Context) {
+	var req BatchReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El motivo del rechazo es requerido"})
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	results := make([]BatchResult, len(req.ReservationIDs))
+	rejected := 0
+	for i, reservationID := range req.ReservationIDs {
+		results[i].ReservationID = reservationID
+		if _, err := ac.reservationService.RejectReservation(reservationID, adminID.(uint), req.Reason); err != nil {
+			results[i].Error = err.Error()
+			continue
 		}
-		config.WSHub.BroadcastMessage(websocket.EventReservationApproved, event)
+		results[i].Success = true
+		rejected++
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Reserva aprobada exitosamente"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("%d de %d reservas rechazadas", rejected, len(req.ReservationIDs)),
+		"results":  results,
+		"rejected": rejected,
+	})
 }
 
 func (ac *AdminController) UpdateUser(c *gin.Context) {
@@ -1056,6 +1265,7 @@ type CreateExternalReservationRequest struct {
 	Duration  int    `json:"duration" binding:"required"`   // Hours
 	Status    string `json:"status"`                        // "confirmed" or "pending"
 	Notes     string `json:"notes"`
+	Override  bool   `json:"override"` // Book over failed hours/schedule rules
 }
 
 func (ac *AdminController) CreateExternalReservation(c *gin.Context) {
@@ -1109,25 +1319,23 @@ func (ac *AdminController) CreateExternalReservation(c *gin.Context) {
 	}
 
 	// Create reservation
-	adminIDUint := adminID.(uint)
-	reservation := models.Reservation{
-		ExternalClientID: &externalClient.ID,
+	reservation, evaluation, err := ac.reservationService.CreateExternalReservation(services.ExternalReservationInput{
+		ExternalClientID: externalClient.ID,
 		SpaceID:          req.SpaceID,
 		StartTime:        startTime,
 		EndTime:          endTime,
 		Status:           models.ReservationStatus(status),
-		CreditsUsed:      0, // External clients don't use credits
-		CreatedBy:        &adminIDUint,
 		Notes:            req.Notes,
-	}
-
-	if err := config.DB.Create(&reservation).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear la reserva"})
+		AdminID:          adminID.(uint),
+		Override:         req.Override,
+	})
+	if err != nil {
+		respondBookingError(c, err, evaluation)
 		return
 	}
 
 	// Load relations for response
-	config.DB.Preload("ExternalClient").Preload("Space").First(&reservation, reservation.ID)
+	config.DB.Preload("ExternalClient").Preload("Space").First(reservation, reservation.ID)
 
 	// Broadcast WebSocket event
 	if config.WSHub != nil {
@@ -1147,9 +1355,23 @@ func (ac *AdminController) CreateExternalReservation(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"message":     "Reserva creada exitosamente",
 		"reservation": reservation,
+		"evaluation":  evaluation,
 	})
 }
 
+// respondBookingError reports a reservation rejected by the booking engine,
+// including the rule report so the admin can retry with override
+func respondBookingError(c *gin.Context, err error, evaluation *services.BookingEvaluation) {
+	switch {
+	case errors.Is(err, services.ErrPeriodReserved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "evaluation": evaluation})
+	case errors.Is(err, services.ErrBookingRulesFailed):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "evaluation": evaluation})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "evaluation": evaluation})
+	}
+}
+
 // UpdateReservation allows admin to update reservation details
 func (ac *AdminController) UpdateReservation(c *gin.Context) {
 	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -1164,131 +1386,46 @@ func (ac *AdminController) UpdateReservation(c *gin.Context) {
 		return
 	}
 
-	// Debug logging
-	log.Printf("Update request for reservation %d: %+v", reservationID, req)
-
-	// Get the existing reservation
-	var reservation models.Reservation
-	if err := config.DB.Preload("User").Preload("ExternalClient").Preload("Space").First(&reservation, reservationID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Reserva no encontrada"})
-		return
-	}
-
-	// Check if reservation can be updated (not cancelled)
-	if reservation.Status == models.StatusCancelled {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No se puede actualizar una reserva cancelada"})
-		return
-	}
-
-	// Log original values
-	log.Printf("Original reservation - SpaceID: %d, StartTime: %v, EndTime: %v",
-		reservation.SpaceID, reservation.StartTime, reservation.EndTime)
-
-	// Update fields if provided
-	if req.SpaceID != nil {
-		// Verify the space exists
-		var space models.Space
-		if err := config.DB.First(&space, *req.SpaceID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Espacio no encontrado"})
-			return
-		}
-		log.Printf("Updating SpaceID from %d to %d", reservation.SpaceID, *req.SpaceID)
-		reservation.SpaceID = *req.SpaceID
+	adminID, _ := c.Get("user_id")
+	input := services.RescheduleInput{
+		SpaceID:  req.SpaceID,
+		Notes:    req.Notes,
+		Override: req.Override,
+		AdminID:  adminID.(uint),
 	}
-
 	if req.StartTime != nil {
 		startTime, err := time.Parse(time.RFC3339, *req.StartTime)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de fecha de inicio inválido"})
 			return
 		}
-		reservation.StartTime = startTime
+		input.StartTime = &startTime
 	}
-
 	if req.EndTime != nil {
 		endTime, err := time.Parse(time.RFC3339, *req.EndTime)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de fecha de fin inválido"})
 			return
 		}
-		reservation.EndTime = endTime
+		input.EndTime = &endTime
 	}
 
-	if req.Notes != nil {
-		reservation.Notes = *req.Notes
-	}
-
-	// Validate that start time is before end time
-	if reservation.StartTime.After(reservation.EndTime) || reservation.StartTime.Equal(reservation.EndTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "La hora de inicio debe ser anterior a la hora de fin"})
-		return
-	}
-
-	// Check for conflicts with other reservations (excluding current one)
-	var conflictCount int64
-	config.DB.Model(&models.Reservation{}).
-		Where("space_id = ? AND id != ? AND status != ? AND ((start_time < ? AND end_time > ?) OR (start_time < ? AND end_time > ?))",
-			reservation.SpaceID, reservation.ID, models.StatusCancelled,
-			reservation.EndTime, reservation.StartTime,
-			reservation.StartTime, reservation.EndTime).
-		Count(&conflictCount)
-
-	if conflictCount > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ya existe una reserva en ese horario para el espacio seleccionado"})
-		return
-	}
-
-	// Log values before save
-	log.Printf("Before save - SpaceID: %d, StartTime: %v, EndTime: %v",
-		reservation.SpaceID, reservation.StartTime, reservation.EndTime)
-
-	// Use Updates instead of Save to ensure changes persist
-	updates := make(map[string]interface{})
-	if req.SpaceID != nil {
-		log.Printf("Adding space_id to updates: %d", *req.SpaceID)
-		updates["space_id"] = *req.SpaceID
-	}
-	if req.StartTime != nil {
-		startTime, _ := time.Parse(time.RFC3339, *req.StartTime)
-		log.Printf("Adding start_time to updates: %v", startTime)
-		updates["start_time"] = startTime
-	}
-	if req.EndTime != nil {
-		endTime, _ := time.Parse(time.RFC3339, *req.EndTime)
-		log.Printf("Adding end_time to updates: %v", endTime)
-		updates["end_time"] = endTime
-	}
-	if req.Notes != nil {
-		log.Printf("Adding notes to updates: %s", *req.Notes)
-		updates["notes"] = *req.Notes
-	}
-
-	log.Printf("Final updates map before DB call: %+v", updates)
-
-	// Use direct SQL update to bypass any GORM hooks that might be interfering
-	result := config.DB.Model(&models.Reservation{}).Where("id = ?", reservation.ID).Updates(updates)
-	if result.Error != nil {
-		log.Printf("Error updating reservation: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar la reserva"})
+	reservation, evaluation, err := ac.reservationService.UpdateReservation(uint(reservationID), input)
+	if err != nil {
+		respondBookingError(c, err, evaluation)
 		return
 	}
 
-	log.Printf("Rows affected: %d", result.RowsAffected)
-
-	log.Printf("Reservation updated successfully with: %+v", updates)
-
 	// Reload with relations for response
 	var updatedReservation models.Reservation
 	if err := config.DB.Preload("User").Preload("ExternalClient").Preload("Space").Preload("CreatedByUser").First(&updatedReservation, reservation.ID).Error; err != nil {
 		log.Printf("Error reloading reservation: %v", err)
 	}
 
-	log.Printf("After reload - SpaceID: %d, StartTime: %v, EndTime: %v",
-		updatedReservation.SpaceID, updatedReservation.StartTime, updatedReservation.EndTime)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":     "Reserva actualizada exitosamente",
 		"reservation": updatedReservation,
+		"evaluation":  evaluation,
 	})
 }
 
@@ -8,13 +8,18 @@ import (
 
 	"github.com/IkingariSolorzano/omma-be/config"
 	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
 	"github.com/gin-gonic/gin"
 )
 
-type CalendarController struct{}
+type CalendarController struct {
+	pricingService *services.PricingService
+}
 
 func NewCalendarController() *CalendarController {
-	return &CalendarController{}
+	return &CalendarController{
+		pricingService: services.NewPricingService(),
+	}
 }
 
 type CalendarReservation struct {
@@ -235,11 +240,21 @@ func (cc *CalendarController) GetAvailableSlots(c *gin.Context) {
 		StartTime time.Time `json:"start_time"`
 		EndTime   time.Time `json:"end_time"`
 		Available bool      `json:"available"`
+		Credits   int       `json:"credits"`             // Price of the slot in credits
+		RuleName  string    `json:"rule_name,omitempty"` // Pricing rule applied, if any
 	}
 
 	var slots []AvailableSlot
+	// Pricing rules are loaded once per space instead of once per slot
+	pricingRules := make(map[uint][]models.SpacePricingRule)
 
 	for _, schedule := range schedules {
+		rules, ok := pricingRules[schedule.SpaceID]
+		if !ok {
+			rules = cc.pricingService.LoadRules(schedule.SpaceID)
+			pricingRules[schedule.SpaceID] = rules
+		}
+
 		// Parse schedule times
 		startTime, _ := time.Parse("15:04", schedule.StartTime)
 		endTime, _ := time.Parse("15:04", schedule.EndTime)
@@ -264,13 +279,24 @@ func (cc *CalendarController) GetAvailableSlots(c *gin.Context) {
 				}
 			}
 
-			slots = append(slots, AvailableSlot{
+			slot := AvailableSlot{
 				SpaceID:   schedule.SpaceID,
 				SpaceName: schedule.Space.Name,
 				StartTime: current,
 				EndTime:   slotEnd,
 				Available: available,
-			})
+			}
+			// Slots inside the schedule never carry the special reservation surcharge
+			if quote, err := cc.pricingService.QuoteWithRules(&schedule.Space, rules, current, slotEnd, false); err == nil {
+				slot.Credits = quote.TotalCredits
+				for _, unit := range quote.Breakdown {
+					if unit.RuleName != "" {
+						slot.RuleName = unit.RuleName
+						break
+					}
+				}
+			}
+			slots = append(slots, slot)
 
 			current = slotEnd
 		}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CancellationPolicyController struct {
+	policyService *services.CancellationPolicyService
+}
+
+func NewCancellationPolicyController() *CancellationPolicyController {
+	return &CancellationPolicyController{
+		policyService: services.NewCancellationPolicyService(),
+	}
+}
+
+type CancellationTierRequest struct {
+	MinHours      float64 `json:"min_hours" binding:"min=0"`
+	RefundPercent int     `json:"refund_percent" binding:"min=0,max=100"`
+}
+
+type CancellationPolicyRequest struct {
+	Name     string                    `json:"name" binding:"required"`
+	SpaceID  *uint                     `json:"space_id"`  // Omit for the default policy
+	IsActive *bool                     `json:"is_active"` // Defaults to true
+	Tiers    []CancellationTierRequest `json:"tiers" binding:"required,min=1,dive"`
+}
+
+// activeByDefault reads an optional is_active field of a catalog request,
+// which is true when omitted
+func activeByDefault(isActive *bool) bool {
+	return isActive == nil || *isActive
+}
+
+func (req CancellationPolicyRequest) tiers() []models.CancellationPolicyTier {
+	tiers := make([]models.CancellationPolicyTier, len(req.Tiers))
+	for i, tier := range req.Tiers {
+		tiers[i] = models.CancellationPolicyTier{
+			MinHours:      tier.MinHours,
+			RefundPercent: tier.RefundPercent,
+		}
+	}
+	return tiers
+}
+
+func (pc *CancellationPolicyController) GetPolicies(c *gin.Context) {
+	policies, err := pc.policyService.GetPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las políticas de cancelación"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+func (pc *CancellationPolicyController) CreatePolicy(c *gin.Context) {
+	var req CancellationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := pc.policyService.CreatePolicy(req.Name, req.SpaceID, activeByDefault(req.IsActive), req.tiers())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Política de cancelación creada exitosamente",
+		"policy":  policy,
+	})
+}
+
+func (pc *CancellationPolicyController) UpdatePolicy(c *gin.Context) {
+	policyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de política inválido"})
+		return
+	}
+
+	var req CancellationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := pc.policyService.UpdatePolicy(uint(policyID), req.Name, activeByDefault(req.IsActive), req.tiers())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Política de cancelación actualizada exitosamente",
+		"policy":  policy,
+	})
+}
+
+func (pc *CancellationPolicyController) DeletePolicy(c *gin.Context) {
+	policyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de política inválido"})
+		return
+	}
+
+	if err := pc.policyService.DeletePolicy(uint(policyID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Política de cancelación eliminada exitosamente"})
+}
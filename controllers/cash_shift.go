@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CashShiftController struct {
+	cashShiftService *services.CashShiftService
+}
+
+func NewCashShiftController() *CashShiftController {
+	return &CashShiftController{
+		cashShiftService: services.NewCashShiftService(),
+	}
+}
+
+type OpenCashShiftRequest struct {
+	OpeningCash models.Money `json:"opening_cash" binding:"omitempty,gte=0"` // Fondo inicial en caja, texto decimal como "500.00"
+	Notes       string       `json:"notes"`
+}
+
+type CountedAmount struct {
+	PaymentMethod string       `json:"payment_method" binding:"required"`
+	Amount        models.Money `json:"amount" binding:"gte=0"` // Monto contado, en efectivo incluye el fondo inicial
+}
+
+type CloseCashShiftRequest struct {
+	Counted []CountedAmount `json:"counted" binding:"required,dive"` // Un monto por cada forma de pago del turno
+	Notes   string          `json:"notes"`
+}
+
+// OpenShift opens the register with the opening cash in the drawer
+func (cc *CashShiftController) OpenShift(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req OpenCashShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shift, err := cc.cashShiftService.OpenShift(adminID.(uint), req.OpeningCash, req.Notes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Turno de caja abierto exitosamente",
+		"shift":   shift,
+	})
+}
+
+// GetCurrentShift returns the open shift with its running totals
+func (cc *CashShiftController) GetCurrentShift(c *gin.Context) {
+	summary, err := cc.cashShiftService.GetCurrentShift()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summary": summary})
+}
+
+func (cc *CashShiftController) GetShifts(c *gin.Context) {
+	shifts, err := cc.cashShiftService.GetShifts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los turnos de caja"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shifts": shifts})
+}
+
+func (cc *CashShiftController) GetShift(c *gin.Context) {
+	shiftID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de turno inválido"})
+		return
+	}
+
+	summary, err := cc.cashShiftService.GetShift(uint(shiftID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summary": summary})
+}
+
+// CloseShift does the corte de caja with the amounts the admin counted
+func (cc *CashShiftController) CloseShift(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	shiftID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de turno inválido"})
+		return
+	}
+
+	var req CloseCashShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	counted := make(map[string]models.Money, len(req.Counted))
+	for _, amount := range req.Counted {
+		counted[amount.PaymentMethod] += amount.Amount
+	}
+
+	summary, err := cc.cashShiftService.CloseShift(uint(shiftID), adminID.(uint), counted, req.Notes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Corte de caja registrado exitosamente",
+		"summary": summary,
+	})
+}
+
+// ExportShift downloads a closed shift as CSV
+func (cc *CashShiftController) ExportShift(c *gin.Context) {
+	shiftID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de turno inválido"})
+		return
+	}
+
+	data, err := cc.cashShiftService.ExportShift(uint(shiftID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="corte-de-caja-%d.csv"`, shiftID))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CheckInController struct {
+	checkInService *services.CheckInService
+	noShowService  *services.NoShowService
+}
+
+func NewCheckInController() *CheckInController {
+	return &CheckInController{
+		checkInService: services.NewCheckInService(),
+		noShowService:  services.NewNoShowService(),
+	}
+}
+
+type CheckInRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type NoShowPolicyRequest struct {
+	Enabled        bool `json:"enabled"`
+	GraceMinutes   int  `json:"grace_minutes" binding:"min=0"`
+	PenaltyCredits int  `json:"penalty_credits" binding:"min=0"`
+}
+
+// CheckIn lets the professional check in to their reservation with the code
+// given at the front desk
+func (cc *CheckInController) CheckIn(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de reserva inválido"})
+		return
+	}
+
+	var req CheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reservation, err := cc.checkInService.CheckInWithCode(uint(reservationID), userID.(uint), req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Llegada registrada exitosamente",
+		"reservation": reservation,
+	})
+}
+
+// GenerateCheckInCode creates the time-limited code the professional enters
+// to check in
+func (cc *CheckInController) GenerateCheckInCode(c *gin.Context) {
+	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de reserva inválido"})
+		return
+	}
+
+	code, expiresAt, err := cc.checkInService.GenerateCode(uint(reservationID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":       code,
+		"expires_at": expiresAt,
+	})
+}
+
+// AdminCheckIn records the professional's arrival from the front desk
+func (cc *CheckInController) AdminCheckIn(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de reserva inválido"})
+		return
+	}
+
+	reservation, err := cc.checkInService.AdminCheckIn(uint(reservationID), adminID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Llegada registrada exitosamente",
+		"reservation": reservation,
+	})
+}
+
+func (cc *CheckInController) GetNoShowPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"policy": cc.noShowService.GetPolicy()})
+}
+
+func (cc *CheckInController) UpdateNoShowPolicy(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req NoShowPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := cc.noShowService.UpdatePolicy(req.Enabled, req.GraceMinutes, req.PenaltyCredits, adminID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Política de inasistencia actualizada exitosamente",
+		"policy":  policy,
+	})
+}
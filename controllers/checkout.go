@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CheckoutController struct {
+	checkoutService *services.CheckoutService
+}
+
+func NewCheckoutController() *CheckoutController {
+	return &CheckoutController{
+		checkoutService: services.NewCheckoutService(),
+	}
+}
+
+// Enabled reports whether the online payment routes should be registered
+func (cc *CheckoutController) Enabled() bool {
+	return cc.checkoutService.Enabled()
+}
+
+// UsesFakeGateway reports whether the development-only routes of the fake
+// gateway should be registered
+func (cc *CheckoutController) UsesFakeGateway() bool {
+	_, ok := cc.checkoutService.FakeGateway()
+	return ok
+}
+
+type StartCheckoutRequest struct {
+	PackageID *uint        `json:"package_id"`                      // opcional, compra un paquete del catálogo
+	Amount    models.Money `json:"amount" binding:"omitempty,gt=0"` // requerido sin paquete, texto decimal como "99.99"
+}
+
+type SimulateCheckoutRequest struct {
+	Status models.CheckoutStatus `json:"status" binding:"required"` // "paid" o "failed"
+}
+
+// StartCheckout opens an online payment for the professional to buy credits
+func (cc *CheckoutController) StartCheckout(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req StartCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	checkout, err := cc.checkoutService.StartCheckout(userID.(uint), req.PackageID, req.Amount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Pago en línea iniciado",
+		"checkout": checkout,
+	})
+}
+
+func (cc *CheckoutController) GetMyCheckouts(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	checkouts, err := cc.checkoutService.GetUserCheckouts(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los pagos en línea"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"checkouts": checkouts})
+}
+
+// GetCheckout returns an online payment with its current status
+func (cc *CheckoutController) GetCheckout(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	checkoutID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de pago en línea inválido"})
+		return
+	}
+
+	checkout, err := cc.checkoutService.GetCheckout(uint(checkoutID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"checkout": checkout})
+}
+
+// SimulateCheckout pays or fails a checkout at the fake gateway. It is only
+// registered for admins in debug mode.
+func (cc *CheckoutController) SimulateCheckout(c *gin.Context) {
+	checkoutID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de pago en línea inválido"})
+		return
+	}
+
+	var req SimulateCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	checkout, err := cc.checkoutService.SimulatePayment(uint(checkoutID), req.Status)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"checkout": checkout})
+}
+
+// HandleWebhook receives the gateway's notifications. Any error makes the
+// provider deliver the event again later.
+func (cc *CheckoutController) HandleWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el webhook"})
+		return
+	}
+
+	if err := cc.checkoutService.HandleWebhook(payload, c.Request.Header); err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookSignature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error al procesar el webhook de pagos: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CreditPackageController struct {
+	packageService *services.CreditPackageService
+}
+
+func NewCreditPackageController() *CreditPackageController {
+	return &CreditPackageController{
+		packageService: services.NewCreditPackageService(),
+	}
+}
+
+type CreditPackageRequest struct {
+	Name         string       `json:"name" binding:"required"`
+	Description  string       `json:"description"`
+	Price        models.Money `json:"price" binding:"required,gt=0"` // Decimal string, e.g. "90.00"
+	Credits      int          `json:"credits" binding:"required,min=1"`
+	BonusCredits int          `json:"bonus_credits" binding:"min=0"`
+	ValidityDays int          `json:"validity_days" binding:"required,min=1"`
+	ActiveFrom   *time.Time   `json:"active_from"`
+	ActiveUntil  *time.Time   `json:"active_until"`
+	IsActive     *bool        `json:"is_active"` // Defaults to true
+}
+
+func (req CreditPackageRequest) creditPackage() *models.CreditPackage {
+	return &models.CreditPackage{
+		Name:         req.Name,
+		Description:  req.Description,
+		Price:        req.Price,
+		Credits:      req.Credits,
+		BonusCredits: req.BonusCredits,
+		ValidityDays: req.ValidityDays,
+		ActiveFrom:   req.ActiveFrom,
+		ActiveUntil:  req.ActiveUntil,
+		IsActive:     activeByDefault(req.IsActive),
+	}
+}
+
+// GetAvailablePackages lists the packages on sale right now
+func (pc *CreditPackageController) GetAvailablePackages(c *gin.Context) {
+	packages, err := pc.packageService.GetPackages(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los paquetes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"packages": packages})
+}
+
+// GetPackages lists the whole catalog, including inactive packages
+func (pc *CreditPackageController) GetPackages(c *gin.Context) {
+	packages, err := pc.packageService.GetPackages(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los paquetes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"packages": packages})
+}
+
+func (pc *CreditPackageController) CreatePackage(c *gin.Context) {
+	var req CreditPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pkg := req.creditPackage()
+	if err := pc.packageService.CreatePackage(pkg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Paquete creado exitosamente",
+		"package": pkg,
+	})
+}
+
+func (pc *CreditPackageController) UpdatePackage(c *gin.Context) {
+	packageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de paquete inválido"})
+		return
+	}
+
+	var req CreditPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pkg, err := pc.packageService.UpdatePackage(uint(packageID), req.creditPackage())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Paquete actualizado exitosamente",
+		"package": pkg,
+	})
+}
+
+func (pc *CreditPackageController) DeletePackage(c *gin.Context) {
+	packageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de paquete inválido"})
+		return
+	}
+
+	if err := pc.packageService.DeletePackage(uint(packageID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Paquete eliminado exitosamente"})
+}
@@ -28,7 +28,7 @@ type DashboardStats struct {
 	PendingReservationsCount     int     `json:"pending_reservations_count"`
 
 	// Weekly Financial Stats
-	WeeklyIncome                 float64 `json:"weekly_income"`
+	WeeklyIncome                 models.Money `json:"weekly_income"` // Decimal string in models.DefaultCurrency
 	WeeklyCreditsPurchased       int     `json:"weekly_credits_purchased"`
 	WeeklyCreditsGranted         int     `json:"weekly_credits_granted"`
 	WeeklyCreditsTotal           int     `json:"weekly_credits_total"`
@@ -117,10 +117,10 @@ func (dc *DashboardController) GetDashboardStats(c *gin.Context) {
 	stats.PendingReservationsCount = int(pendingReservations)
 
 	// 3. Weekly Financials - Ingresos de la semana
-	var weeklyIncome float64
+	var weeklyIncome models.Money
 	config.DB.Model(&models.Payment{}).
 		Where("created_at >= ? AND created_at < ?", startOfWeek, endOfWeek).
-		Select("COALESCE(SUM(amount), 0)").
+		Select("COALESCE(SUM(amount_cents), 0)").
 		Scan(&weeklyIncome)
 	stats.WeeklyIncome = weeklyIncome
 
@@ -132,10 +132,10 @@ func (dc *DashboardController) GetDashboardStats(c *gin.Context) {
 		Scan(&creditsPurchased)
 	stats.WeeklyCreditsPurchased = int(creditsPurchased)
 
-	// Créditos otorgados por administrador (usando tabla de historial)
+	// Créditos otorgados por administrador (según el libro de créditos)
 	var creditsGranted int64
-	config.DB.Model(&models.CreditHistory{}).
-		Where("created_at >= ? AND created_at < ? AND action = ?", startOfWeek, endOfWeek, "granted").
+	config.DB.Model(&models.CreditTransaction{}).
+		Where("created_at >= ? AND created_at < ? AND type = ?", startOfWeek, endOfWeek, models.TransactionTypeGrant).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&creditsGranted)
 	stats.WeeklyCreditsGranted = int(creditsGranted)
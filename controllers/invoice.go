@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceController struct {
+	invoiceService *services.InvoiceService
+}
+
+func NewInvoiceController() *InvoiceController {
+	return &InvoiceController{
+		invoiceService: services.NewInvoiceService(),
+	}
+}
+
+type TaxDataRequest struct {
+	RFC       string `json:"rfc" binding:"required"`
+	LegalName string `json:"legal_name" binding:"required"` // Razón social como aparece en la constancia de situación fiscal
+	Regime    string `json:"regime" binding:"required"`     // Clave del régimen fiscal, ej. "612"
+	ZipCode   string `json:"zip_code" binding:"required"`   // Código postal del domicilio fiscal
+	CFDIUse   string `json:"cfdi_use" binding:"required"`   // Clave del uso de CFDI, ej. "G03"
+}
+
+func (req TaxDataRequest) taxData() models.TaxData {
+	return models.TaxData{
+		RFC:       req.RFC,
+		LegalName: req.LegalName,
+		Regime:    req.Regime,
+		ZipCode:   req.ZipCode,
+		CFDIUse:   req.CFDIUse,
+	}
+}
+
+// invoiceOwner is the user whose invoices the caller may see: any for an
+// admin, only their own for a professional
+func invoiceOwner(c *gin.Context) uint {
+	if role, _ := c.Get("user_role"); role == models.RoleAdmin {
+		return 0
+	}
+	userID, _ := c.Get("user_id")
+	return userID.(uint)
+}
+
+// UpdateMyTaxData saves the tax data the professional is invoiced with
+func (ic *InvoiceController) UpdateMyTaxData(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req TaxDataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := ic.invoiceService.UpdateTaxData(userID.(uint), req.taxData())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Datos fiscales actualizados exitosamente",
+		"tax_data": user.TaxData,
+	})
+}
+
+func (ic *InvoiceController) UpdateUserTaxData(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+		return
+	}
+
+	var req TaxDataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := ic.invoiceService.UpdateTaxData(uint(userID), req.taxData())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Datos fiscales actualizados exitosamente",
+		"tax_data": user.TaxData,
+	})
+}
+
+// CreateInvoice issues the CFDI of a payment. Professionals can only invoice
+// their own payments.
+func (ic *InvoiceController) CreateInvoice(c *gin.Context) {
+	actorID, _ := c.Get("user_id")
+
+	paymentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de pago inválido"})
+		return
+	}
+
+	invoice, err := ic.invoiceService.CreateInvoice(uint(paymentID), actorID.(uint), invoiceOwner(c))
+	if err != nil {
+		if invoice != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "invoice": invoice})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Factura generada exitosamente",
+		"invoice": invoice,
+	})
+}
+
+// GetInvoices lists the professional's invoices, or every invoice for an
+// admin. cancellation_required=true lists the ones of refunded payments.
+func (ic *InvoiceController) GetInvoices(c *gin.Context) {
+	invoices, err := ic.invoiceService.GetInvoices(invoiceOwner(c), c.Query("cancellation_required") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las facturas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoices": invoices})
+}
+
+func (ic *InvoiceController) DownloadXML(c *gin.Context) {
+	invoice, ok := ic.downloadableInvoice(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xml"`, invoice.UUID))
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", []byte(invoice.XML))
+}
+
+func (ic *InvoiceController) DownloadPDF(c *gin.Context) {
+	invoice, ok := ic.downloadableInvoice(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, invoice.UUID))
+	c.Data(http.StatusOK, "application/pdf", invoice.PDF)
+}
+
+// downloadableInvoice loads a stamped invoice the caller may download,
+// writing the error response otherwise
+func (ic *InvoiceController) downloadableInvoice(c *gin.Context) (*models.Invoice, bool) {
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de factura inválido"})
+		return nil, false
+	}
+
+	invoice, err := ic.invoiceService.GetInvoice(uint(invoiceID), invoiceOwner(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if invoice.Status != models.InvoiceStamped {
+		c.JSON(http.StatusConflict, gin.H{"error": "La factura no fue timbrada"})
+		return nil, false
+	}
+	return invoice, true
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type JobController struct {
+	schedulerService *services.SchedulerService
+}
+
+// NewJobController takes the scheduler started in main, so manual runs share
+// its leases
+func NewJobController(schedulerService *services.SchedulerService) *JobController {
+	return &JobController{
+		schedulerService: schedulerService,
+	}
+}
+
+func (jc *JobController) GetJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": jc.schedulerService.GetJobs()})
+}
+
+func (jc *JobController) GetJobRuns(c *gin.Context) {
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+	}
+
+	runs, err := jc.schedulerService.GetRuns(c.Param("name"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el historial del trabajo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// RunJob executes a job immediately, outside its schedule
+func (jc *JobController) RunJob(c *gin.Context) {
+	run, err := jc.schedulerService.RunNow(c.Param("name"))
+	if errors.Is(err, services.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrJobRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "run": run})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trabajo ejecutado exitosamente",
+		"run":     run,
+	})
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MembershipController struct {
+	membershipService *services.MembershipService
+}
+
+func NewMembershipController() *MembershipController {
+	return &MembershipController{
+		membershipService: services.NewMembershipService(),
+	}
+}
+
+type MembershipPlanRequest struct {
+	Name           string       `json:"name" binding:"required"`
+	Description    string       `json:"description"`
+	MonthlyPrice   models.Money `json:"monthly_price" binding:"required,gt=0"` // Decimal string, e.g. "800.00"
+	MonthlyCredits int          `json:"monthly_credits" binding:"required,min=1"`
+	RolloverCap    int          `json:"rollover_cap" binding:"min=0"`
+	IsActive       *bool        `json:"is_active"` // Defaults to true
+}
+
+func (req MembershipPlanRequest) plan() *models.MembershipPlan {
+	return &models.MembershipPlan{
+		Name:           req.Name,
+		Description:    req.Description,
+		MonthlyPrice:   req.MonthlyPrice,
+		MonthlyCredits: req.MonthlyCredits,
+		RolloverCap:    req.RolloverCap,
+		IsActive:       activeByDefault(req.IsActive),
+	}
+}
+
+type CreateMembershipRequest struct {
+	UserID     uint `json:"user_id" binding:"required"`
+	PlanID     uint `json:"plan_id" binding:"required"`
+	BillingDay int  `json:"billing_day" binding:"required,min=1,max=28"`
+}
+
+// GetMyMemberships lists the professional's memberships with their periods
+func (mc *MembershipController) GetMyMemberships(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	memberships, err := mc.membershipService.GetUserMemberships(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las membresías"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"memberships": memberships})
+}
+
+func (mc *MembershipController) GetPlans(c *gin.Context) {
+	plans, err := mc.membershipService.GetPlans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los planes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+func (mc *MembershipController) CreatePlan(c *gin.Context) {
+	var req MembershipPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan := req.plan()
+	if err := mc.membershipService.CreatePlan(plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Plan creado exitosamente",
+		"plan":    plan,
+	})
+}
+
+func (mc *MembershipController) UpdatePlan(c *gin.Context) {
+	planID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de plan inválido"})
+		return
+	}
+
+	var req MembershipPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := mc.membershipService.UpdatePlan(uint(planID), req.plan())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Plan actualizado exitosamente",
+		"plan":    plan,
+	})
+}
+
+func (mc *MembershipController) GetMemberships(c *gin.Context) {
+	var userID uint64
+	if raw := c.Query("user_id"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+			return
+		}
+		userID = parsed
+	}
+
+	memberships, err := mc.membershipService.GetMemberships(c.Query("status"), uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las membresías"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"memberships": memberships})
+}
+
+func (mc *MembershipController) GetMembership(c *gin.Context) {
+	membershipID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de membresía inválido"})
+		return
+	}
+
+	membership, err := mc.membershipService.GetMembership(uint(membershipID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"membership": membership})
+}
+
+// CreateMembership subscribes a professional and grants the first period's credits
+func (mc *MembershipController) CreateMembership(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req CreateMembershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	membership, err := mc.membershipService.CreateMembership(req.UserID, req.PlanID, req.BillingDay, adminID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Membresía creada exitosamente",
+		"membership": membership,
+	})
+}
+
+func (mc *MembershipController) CancelMembership(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	membershipID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de membresía inválido"})
+		return
+	}
+
+	membership, err := mc.membershipService.CancelMembership(uint(membershipID), adminID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Membresía cancelada exitosamente",
+		"membership": membership,
+	})
+}
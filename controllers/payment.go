@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/IkingariSolorzano/omma-be/models"
 	"github.com/IkingariSolorzano/omma-be/services"
 )
 
@@ -19,12 +20,15 @@ func NewPaymentController() *PaymentController {
 }
 
 type RegisterPaymentRequest struct {
-	UserID        uint    `json:"user_id" binding:"required"`
-	Amount        float64 `json:"amount" binding:"required,min=0.01"`
-	Credits       *int    `json:"credits,omitempty"` // opcional, ignorado por backend (se calcula desde Amount)
-	PaymentMethod string  `json:"payment_method" binding:"required"`
-	Reference     string  `json:"reference"`
-	Notes         string  `json:"notes"`
+	UserID        uint         `json:"user_id" binding:"required"`
+	PackageID     *uint        `json:"package_id"`                      // opcional, compra un paquete del catálogo
+	MembershipID  *uint        `json:"membership_id"`                   // opcional, paga el periodo pendiente más antiguo de la membresía
+	PromoCode     string       `json:"promo_code"`                      // opcional, código de bonificación sobre los créditos comprados
+	Amount        models.Money `json:"amount" binding:"omitempty,gt=0"` // requerido sin paquete, texto decimal como "99.99"
+	Credits       *int         `json:"credits,omitempty"`               // opcional, ignorado por backend (se calcula desde Amount o el paquete)
+	PaymentMethod string       `json:"payment_method" binding:"required"`
+	Reference     string       `json:"reference"`
+	Notes         string       `json:"notes"`
 }
 
 func (pc *PaymentController) RegisterPayment(c *gin.Context) {
@@ -36,14 +40,17 @@ func (pc *PaymentController) RegisterPayment(c *gin.Context) {
 
 	adminID, _ := c.Get("user_id")
 
-	payment, err := pc.paymentService.RegisterPayment(
-		req.UserID,
-		adminID.(uint),
-		req.Amount,
-		req.PaymentMethod,
-		req.Reference,
-		req.Notes,
-	)
+	payment, err := pc.paymentService.RegisterPayment(services.PaymentInput{
+		UserID:        req.UserID,
+		AdminID:       adminID.(uint),
+		PackageID:     req.PackageID,
+		MembershipID:  req.MembershipID,
+		PromoCode:     req.PromoCode,
+		Amount:        req.Amount,
+		PaymentMethod: req.PaymentMethod,
+		Reference:     req.Reference,
+		Notes:         req.Notes,
+	})
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -85,3 +92,88 @@ func (pc *PaymentController) GetPaymentHistory(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"payments": payments})
 	}
 }
+
+type RefundPaymentRequest struct {
+	Amount models.Money `json:"amount" binding:"omitempty,gt=0"` // opcional, sin monto se reembolsa lo que queda del pago
+	Reason string       `json:"reason" binding:"required"`
+}
+
+type VoidPaymentRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// RefundPayment returns part or all of a payment and takes back the unspent
+// credits it granted
+func (pc *PaymentController) RefundPayment(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	paymentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de pago inválido"})
+		return
+	}
+
+	var req RefundPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := pc.paymentService.RefundPayment(uint(paymentID), adminID.(uint), req.Amount, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, refundResponse("Reembolso registrado exitosamente", result))
+}
+
+// VoidPayment cancels a payment registered by mistake
+func (pc *PaymentController) VoidPayment(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	paymentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de pago inválido"})
+		return
+	}
+
+	var req VoidPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := pc.paymentService.VoidPayment(uint(paymentID), adminID.(uint), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, refundResponse("Pago anulado exitosamente", result))
+}
+
+// refundResponse warns the admin when the reversed payment was invoiced
+func refundResponse(message string, result *services.RefundResult) gin.H {
+	response := gin.H{
+		"message": message,
+		"refund":  result,
+	}
+	if result.Invoice != nil {
+		response["warning"] = "El pago tiene una factura timbrada que debe cancelarse o compensarse con una nota de crédito"
+	}
+	return response
+}
+
+// GetMyPayments lists the professional's own payments with their invoices
+func (pc *PaymentController) GetMyPayments(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	payments, err := pc.paymentService.GetPaymentHistory(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el historial de pagos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payments": payments})
+}
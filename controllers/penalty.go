@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PenaltyController struct {
+	penaltyService *services.PenaltyService
+}
+
+func NewPenaltyController() *PenaltyController {
+	return &PenaltyController{
+		penaltyService: services.NewPenaltyService(),
+	}
+}
+
+type AppealPenaltyRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+type SettlePenaltyRequest struct {
+	Method        string `json:"method" binding:"required,oneof=credits payment"`
+	PaymentMethod string `json:"payment_method"`
+	Reference     string `json:"reference"`
+	Notes         string `json:"notes"`
+}
+
+type ReviewAppealRequest struct {
+	Approve bool   `json:"approve"`
+	Note    string `json:"note" binding:"required"`
+}
+
+type PenaltyPolicyRequest struct {
+	BlockEnabled   bool `json:"block_enabled"`
+	BlockThreshold int  `json:"block_threshold" binding:"min=0"`
+}
+
+// GetMyPenalties lists the professional's penalties with what is still unpaid
+func (pc *PenaltyController) GetMyPenalties(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	penalties, err := pc.penaltyService.GetUserPenalties(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"penalties":      penalties,
+		"unpaid_credits": pc.penaltyService.UnpaidCredits(userID.(uint)),
+	})
+}
+
+// SettleMyPenalty pays one of the professional's own penalties with credits
+func (pc *PenaltyController) SettleMyPenalty(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	penaltyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de penalización inválido"})
+		return
+	}
+
+	penalty, err := pc.penaltyService.SettleWithCredits(uint(penaltyID), userID.(uint), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Penalización pagada exitosamente",
+		"penalty": penalty,
+	})
+}
+
+func (pc *PenaltyController) AppealPenalty(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	penaltyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de penalización inválido"})
+		return
+	}
+
+	var req AppealPenaltyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appeal, err := pc.penaltyService.AppealPenalty(uint(penaltyID), userID.(uint), req.Message)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Apelación enviada exitosamente",
+		"appeal":  appeal,
+	})
+}
+
+func (pc *PenaltyController) GetPenalties(c *gin.Context) {
+	var userID uint64
+	if raw := c.Query("user_id"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+			return
+		}
+		userID = parsed
+	}
+
+	penalties, err := pc.penaltyService.GetPenalties(c.Query("status"), uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"penalties": penalties})
+}
+
+// SettlePenalty settles a penalty from the user's credits or records a payment for it
+func (pc *PenaltyController) SettlePenalty(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	penaltyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de penalización inválido"})
+		return
+	}
+
+	var req SettlePenaltyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Method == "credits" {
+		penalty, err := pc.penaltyService.SettleWithCredits(uint(penaltyID), 0, adminID.(uint))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Penalización pagada exitosamente",
+			"penalty": penalty,
+		})
+		return
+	}
+
+	penalty, payment, err := pc.penaltyService.SettleWithPayment(uint(penaltyID), adminID.(uint), req.PaymentMethod, req.Reference, req.Notes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Penalización pagada exitosamente",
+		"penalty": penalty,
+		"payment": payment,
+	})
+}
+
+func (pc *PenaltyController) GetAppeals(c *gin.Context) {
+	appeals, err := pc.penaltyService.GetAppeals(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"appeals": appeals})
+}
+
+func (pc *PenaltyController) ReviewAppeal(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	appealID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de apelación inválido"})
+		return
+	}
+
+	var req ReviewAppealRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appeal, err := pc.penaltyService.ReviewAppeal(uint(appealID), adminID.(uint), req.Approve, req.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Apelación rechazada"
+	if req.Approve {
+		message = "Apelación aprobada, la penalización fue condonada"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"appeal":  appeal,
+	})
+}
+
+func (pc *PenaltyController) GetPenaltyPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"policy": pc.penaltyService.GetPolicy()})
+}
+
+func (pc *PenaltyController) UpdatePenaltyPolicy(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req PenaltyPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := pc.penaltyService.UpdatePolicy(req.BlockEnabled, req.BlockThreshold, adminID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Política de penalizaciones actualizada exitosamente",
+		"policy":  policy,
+	})
+}
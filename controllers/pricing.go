@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PricingController struct {
+	pricingService *services.PricingService
+}
+
+func NewPricingController() *PricingController {
+	return &PricingController{
+		pricingService: services.NewPricingService(),
+	}
+}
+
+type PricingRuleRequest struct {
+	Name         string     `json:"name" binding:"required"`
+	DayOfWeek    int        `json:"day_of_week" binding:"min=0,max=6"`
+	StartTime    string     `json:"start_time" binding:"required"`
+	EndTime      string     `json:"end_time" binding:"required"`
+	StartDate    *time.Time `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	Multiplier   *float64   `json:"multiplier"`
+	FixedCredits *int       `json:"fixed_credits"`
+	Priority     int        `json:"priority"`
+	IsActive     *bool      `json:"is_active"` // Defaults to true
+}
+
+func (req PricingRuleRequest) rule() *models.SpacePricingRule {
+	return &models.SpacePricingRule{
+		Name:         req.Name,
+		DayOfWeek:    req.DayOfWeek,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		StartDate:    req.StartDate,
+		EndDate:      req.EndDate,
+		Multiplier:   req.Multiplier,
+		FixedCredits: req.FixedCredits,
+		Priority:     req.Priority,
+		IsActive:     activeByDefault(req.IsActive),
+	}
+}
+
+func (pc *PricingController) GetRules(c *gin.Context) {
+	spaceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de espacio inválido"})
+		return
+	}
+
+	rules, err := pc.pricingService.GetRules(uint(spaceID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las reglas de precio"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (pc *PricingController) CreateRule(c *gin.Context) {
+	spaceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de espacio inválido"})
+		return
+	}
+
+	var req PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.rule()
+	rule.SpaceID = uint(spaceID)
+	if err := pc.pricingService.CreateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Regla de precio creada exitosamente",
+		"rule":    rule,
+	})
+}
+
+func (pc *PricingController) UpdateRule(c *gin.Context) {
+	ruleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de regla inválido"})
+		return
+	}
+
+	var req PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := pc.pricingService.UpdateRule(uint(ruleID), req.rule())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Regla de precio actualizada exitosamente",
+		"rule":    rule,
+	})
+}
+
+func (pc *PricingController) DeleteRule(c *gin.Context) {
+	ruleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de regla inválido"})
+		return
+	}
+
+	if err := pc.pricingService.DeleteRule(uint(ruleID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Regla de precio eliminada exitosamente"})
+}
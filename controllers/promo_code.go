@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PromoCodeController struct {
+	promoCodeService *services.PromoCodeService
+}
+
+func NewPromoCodeController() *PromoCodeController {
+	return &PromoCodeController{
+		promoCodeService: services.NewPromoCodeService(),
+	}
+}
+
+type PromoCodeRequest struct {
+	Code           string               `json:"code"` // Optional, generated when empty
+	Description    string               `json:"description"`
+	Kind           models.PromoCodeKind `json:"kind" binding:"required,oneof=credits bonus"`
+	Credits        int                  `json:"credits" binding:"min=0"`
+	BonusPercent   float64              `json:"bonus_percent" binding:"min=0"`
+	ValidityDays   int                  `json:"validity_days" binding:"min=0"`
+	MaxRedemptions int                  `json:"max_redemptions" binding:"min=0"`
+	PerUserLimit   *int                 `json:"per_user_limit"` // Defaults to 1
+	ExpiresAt      *time.Time           `json:"expires_at"`
+	IsActive       *bool                `json:"is_active"` // Defaults to true
+}
+
+func (req PromoCodeRequest) promoCode() *models.PromoCode {
+	perUserLimit := 1
+	if req.PerUserLimit != nil {
+		perUserLimit = *req.PerUserLimit
+	}
+	return &models.PromoCode{
+		Code:           req.Code,
+		Description:    req.Description,
+		Kind:           req.Kind,
+		Credits:        req.Credits,
+		BonusPercent:   req.BonusPercent,
+		ValidityDays:   req.ValidityDays,
+		MaxRedemptions: req.MaxRedemptions,
+		PerUserLimit:   perUserLimit,
+		ExpiresAt:      req.ExpiresAt,
+		IsActive:       activeByDefault(req.IsActive),
+	}
+}
+
+type RedeemPromoCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type SellVoucherRequest struct {
+	BuyerID       uint       `json:"buyer_id" binding:"required"`
+	Credits       int        `json:"credits" binding:"required,min=1"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	PaymentMethod string     `json:"payment_method" binding:"required"`
+	Reference     string     `json:"reference"`
+	Notes         string     `json:"notes"`
+}
+
+// RedeemCode redeems a credits code or gift voucher for the professional
+func (pc *PromoCodeController) RedeemCode(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req RedeemPromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redemption, err := pc.promoCodeService.RedeemCode(req.Code, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Código canjeado exitosamente",
+		"redemption": redemption,
+	})
+}
+
+func (pc *PromoCodeController) GetCodes(c *gin.Context) {
+	codes, err := pc.promoCodeService.GetCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los códigos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promo_codes": codes})
+}
+
+func (pc *PromoCodeController) CreateCode(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := req.promoCode()
+	code.CreatedBy = adminID.(uint)
+	if err := pc.promoCodeService.CreateCode(code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Código creado exitosamente",
+		"promo_code": code,
+	})
+}
+
+func (pc *PromoCodeController) UpdateCode(c *gin.Context) {
+	codeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de código inválido"})
+		return
+	}
+
+	var req PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code, err := pc.promoCodeService.UpdateCode(uint(codeID), req.promoCode())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Código actualizado exitosamente",
+		"promo_code": code,
+	})
+}
+
+// SellVoucher registers the sale of a gift voucher and returns its code
+func (pc *PromoCodeController) SellVoucher(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req SellVoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	voucher, payment, err := pc.promoCodeService.SellVoucher(req.BuyerID, adminID.(uint), req.Credits, req.ExpiresAt, req.PaymentMethod, req.Reference, req.Notes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Vale de regalo vendido exitosamente",
+		"voucher": voucher,
+		"payment": payment,
+	})
+}
+
+// GetRedemptionReport lists redemptions, optionally between start_date and
+// end_date (YYYY-MM-DD) and for a single code
+func (pc *PromoCodeController) GetRedemptionReport(c *gin.Context) {
+	var from, to *time.Time
+	if raw := c.Query("start_date"); raw != "" {
+		end, err := parseAsOf(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		start := end.Add(time.Nanosecond).AddDate(0, 0, -1)
+		from = &start
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		end, err := parseAsOf(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to = &end
+	}
+
+	var codeID uint64
+	if raw := c.Query("promo_code_id"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de código inválido"})
+			return
+		}
+		codeID = parsed
+	}
+
+	report, err := pc.promoCodeService.GetRedemptionReport(from, to, uint(codeID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el reporte de canjes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
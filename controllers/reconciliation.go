@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationController struct {
+	reconciliationService *services.ReconciliationService
+}
+
+func NewReconciliationController() *ReconciliationController {
+	return &ReconciliationController{
+		reconciliationService: services.NewReconciliationService(),
+	}
+}
+
+type CreditCorrectionRequest struct {
+	CreditID uint   `json:"credit_id" binding:"required"`
+	Reason   string `json:"reason" binding:"required"`
+}
+
+// GetReconciliation compares every credit lot with the ledger and lists the
+// users whose balances disagree
+func (rc *ReconciliationController) GetReconciliation(c *gin.Context) {
+	report, err := rc.reconciliationService.Reconcile()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conciliar los créditos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// PostCorrection posts a correction entry so the lot's ledger balance matches the lot
+func (rc *ReconciliationController) PostCorrection(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req CreditCorrectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := rc.reconciliationService.PostCorrection(req.CreditID, adminID.(uint), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Corrección registrada exitosamente",
+		"entry":   entry,
+	})
+}
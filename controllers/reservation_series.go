@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/IkingariSolorzano/omma-be/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+type ReservationSeriesController struct {
+	seriesService *services.ReservationSeriesService
+}
+
+func NewReservationSeriesController() *ReservationSeriesController {
+	return &ReservationSeriesController{
+		seriesService: services.NewReservationSeriesService(),
+	}
+}
+
+type CreateSeriesRequest struct {
+	SpaceID     uint                       `json:"space_id" binding:"required"`
+	StartTime   time.Time                  `json:"start_time" binding:"required"` // First occurrence
+	EndTime     time.Time                  `json:"end_time" binding:"required"`
+	Frequency   models.RecurrenceFrequency `json:"frequency" binding:"required"` // weekly, biweekly, monthly
+	UntilDate   string                     `json:"until_date"`                   // YYYY-MM-DD
+	Occurrences *int                       `json:"occurrences"`
+}
+
+type UpdateSeriesRequest struct {
+	ReservationID uint                 `json:"reservation_id"` // Required for "this" and "following"
+	Scope         services.SeriesScope `json:"scope" binding:"required"`
+	StartTime     time.Time            `json:"start_time" binding:"required"`
+	EndTime       time.Time            `json:"end_time" binding:"required"`
+	SpaceID       *uint                `json:"space_id"`
+}
+
+type CancelSeriesRequest struct {
+	ReservationID uint                 `json:"reservation_id"` // Required for "this" and "following"
+	Scope         services.SeriesScope `json:"scope" binding:"required"`
+}
+
+func (sc *ReservationSeriesController) CreateSeries(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime, endTime := toLocalReservationTimes(req.StartTime, req.EndTime)
+
+	var untilDate *time.Time
+	if req.UntilDate != "" {
+		until, err := time.ParseInLocation("2006-01-02", req.UntilDate, startTime.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de fecha inválido. Use YYYY-MM-DD"})
+			return
+		}
+		untilDate = &until
+	}
+
+	result, err := sc.seriesService.CreateSeries(userID.(uint), req.SpaceID, startTime, endTime, req.Frequency, untilDate, req.Occurrences)
+	if err != nil {
+		response := gin.H{"error": err.Error()}
+		status := http.StatusBadRequest
+		if result != nil {
+			response["conflicts"] = result.Conflicts
+			// The empty series could not be removed
+			if result.Series != nil {
+				response["series"] = result.Series
+				status = http.StatusInternalServerError
+			}
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	broadcastCalendarRefresh("reservation_series:created")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Serie de reservaciones creada exitosamente",
+		"series":       result.Series,
+		"reservations": result.Reservations,
+		"conflicts":    result.Conflicts,
+	})
+}
+
+func (sc *ReservationSeriesController) GetSeries(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	series, err := sc.seriesService.GetUserSeries(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series})
+}
+
+func (sc *ReservationSeriesController) GetSeriesDetails(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	seriesID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de serie inválido"})
+		return
+	}
+
+	series, err := sc.seriesService.GetSeries(uint(seriesID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series})
+}
+
+func (sc *ReservationSeriesController) UpdateSeries(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	seriesID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de serie inválido"})
+		return
+	}
+
+	var req UpdateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime, endTime := toLocalReservationTimes(req.StartTime, req.EndTime)
+
+	result, err := sc.seriesService.EditSeries(uint(seriesID), userID.(uint), req.ReservationID, req.Scope, startTime, endTime, req.SpaceID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	broadcastCalendarRefresh("reservation_series:updated")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Serie de reservaciones actualizada exitosamente",
+		"series":       result.Series,
+		"reservations": result.Reservations,
+		"conflicts":    result.Conflicts,
+	})
+}
+
+func (sc *ReservationSeriesController) CancelSeries(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	seriesID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de serie inválido"})
+		return
+	}
+
+	var req CancelSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cancelled, conflicts, err := sc.seriesService.CancelSeries(uint(seriesID), userID.(uint), req.ReservationID, req.Scope)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	broadcastCalendarRefresh("reservation_series:cancelled")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Serie de reservaciones cancelada exitosamente",
+		"cancelled": cancelled,
+		"conflicts": conflicts,
+	})
+}
+
+// broadcastCalendarRefresh asks connected clients to reload the calendar after
+// changes that touch several reservations at once
+func broadcastCalendarRefresh(reason string) {
+	if config.WSHub != nil {
+		config.WSHub.BroadcastMessage(websocket.EventCalendarRefresh, websocket.CalendarRefreshEvent{Reason: reason})
+	}
+}
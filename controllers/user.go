@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -57,57 +58,113 @@ func (uc *UserController) GetCredits(c *gin.Context) {
 		return
 	}
 
-	activeCredits, err := uc.creditService.GetActiveCredits(userID.(uint))
+	balance, err := uc.creditService.GetBalance(userID.(uint))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los créditos activos"})
 		return
 	}
 
+	holds, err := uc.creditService.GetActiveHolds(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los créditos retenidos"})
+		return
+	}
+
 	// Ensure credits is never null, return empty array if no credits
 	if credits == nil {
 		credits = []models.Credit{}
 	}
 
+	// active_credits is the total kept for older clients; available_credits
+	// excludes what is held for reservations pending approval
 	c.JSON(http.StatusOK, gin.H{
-		"credits":        credits,
-		"active_credits": activeCredits,
+		"credits":           credits,
+		"active_credits":    balance.Total,
+		"available_credits": balance.Available,
+		"held_credits":      balance.Held,
+		"total_credits":     balance.Total,
+		"holds":             holds,
 	})
 }
 
-func (uc *UserController) CreateReservation(c *gin.Context) {
+// GetCreditLedger returns the user's credit movements and the balance derived
+// from them, optionally as of the end of a given day (?as_of=YYYY-MM-DD)
+func (uc *UserController) GetCreditLedger(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	var req CreateReservationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	asOf, err := parseAsOf(c.Query("as_of"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Convert times to local timezone (GMT-6) if they come as UTC
+	respondCreditLedger(c, uc.creditService, userID.(uint), asOf)
+}
+
+// parseAsOf reads an as_of date as the end of that day in local time; an
+// empty value means now
+func parseAsOf(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+
 	loc, err := time.LoadLocation("America/Mexico_City")
 	if err != nil {
 		loc = time.Local
 	}
+	day, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, errors.New("Formato de fecha inválido. Use YYYY-MM-DD")
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
 
-	// Ensure times are interpreted in local timezone
-	startTime := req.StartTime.In(loc)
-	endTime := req.EndTime.In(loc)
+func respondCreditLedger(c *gin.Context, creditService *services.CreditService, userID uint, asOf time.Time) {
+	entries, err := creditService.GetLedger(userID, asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los movimientos de créditos"})
+		return
+	}
 
-	// If the times came as UTC but should be local, adjust them
-	if req.StartTime.Location() == time.UTC {
-		// Parse as if it were local time instead of UTC
-		startTime = time.Date(req.StartTime.Year(), req.StartTime.Month(), req.StartTime.Day(),
-			req.StartTime.Hour(), req.StartTime.Minute(), req.StartTime.Second(),
-			req.StartTime.Nanosecond(), loc)
-		endTime = time.Date(req.EndTime.Year(), req.EndTime.Month(), req.EndTime.Day(),
-			req.EndTime.Hour(), req.EndTime.Minute(), req.EndTime.Second(),
-			req.EndTime.Nanosecond(), loc)
+	balance, err := creditService.BalanceAt(userID, asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al calcular el saldo de créditos"})
+		return
 	}
 
-	reservation, err := uc.reservationService.CreateReservation(
+	if entries == nil {
+		entries = []models.CreditTransaction{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"as_of":   asOf,
+		"balance": balance,
+		"entries": entries,
+	})
+}
+
+func (uc *UserController) CreateReservation(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime, endTime := toLocalReservationTimes(req.StartTime, req.EndTime)
+
+	reservation, quote, err := uc.reservationService.CreateReservation(
 		userID.(uint), req.SpaceID, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status := http.StatusBadRequest
+		response := gin.H{"error": err.Error()}
+		if errors.Is(err, services.ErrPeriodReserved) {
+			// Let the client offer joining the waitlist for this period
+			status = http.StatusConflict
+			response["waitlist_available"] = true
+		}
+		c.JSON(status, response)
 		return
 	}
 
@@ -137,9 +194,49 @@ func (uc *UserController) CreateReservation(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"message":     "Reservación creada exitosamente",
 		"reservation": reservation,
+		"quote":       quote,
 	})
 }
 
+// QuoteReservation returns the exact credit cost of a reservation before the
+// user commits to it
+func (uc *UserController) QuoteReservation(c *gin.Context) {
+	var req CreateReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime, endTime := toLocalReservationTimes(req.StartTime, req.EndTime)
+
+	quote, err := uc.reservationService.QuoteReservation(req.SpaceID, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quote": quote})
+}
+
+// toLocalReservationTimes converts times to local timezone (GMT-6). Times that
+// come as UTC are interpreted as local wall-clock times instead.
+func toLocalReservationTimes(start, end time.Time) (time.Time, time.Time) {
+	loc, err := time.LoadLocation("America/Mexico_City")
+	if err != nil {
+		loc = time.Local
+	}
+
+	// If the times came as UTC but should be local, adjust them
+	if start.Location() == time.UTC {
+		start = time.Date(start.Year(), start.Month(), start.Day(),
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+		end = time.Date(end.Year(), end.Month(), end.Day(),
+			end.Hour(), end.Minute(), end.Second(), end.Nanosecond(), loc)
+	}
+
+	return start.In(loc), end.In(loc)
+}
+
 func (uc *UserController) GetReservations(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
@@ -179,22 +276,14 @@ func (uc *UserController) GetReservations(c *gin.Context) {
 func (uc *UserController) CancelReservation(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	type CancelRequest struct {
-		CreditsToRefund *int `json:"credits_to_refund"` // Use pointer to check if value was provided
-	}
-
 	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reservation ID"})
 		return
 	}
 
-	var req CancelRequest
-	// Bind JSON, but ignore errors if body is empty for backward compatibility
-	_ = c.ShouldBindJSON(&req)
-
-	// The service will handle the logic if CreditsToRefund is nil
-	err = uc.reservationService.CancelReservation(uint(reservationID), userID.(uint), req.CreditsToRefund)
+	// The refund is computed by the service from the cancellation policy
+	cancellation, err := uc.reservationService.CancelReservation(uint(reservationID), userID.(uint))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -223,7 +312,30 @@ func (uc *UserController) CancelReservation(c *gin.Context) {
 		config.WSHub.BroadcastMessage(websocket.EventReservationCancelled, event)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Reservación cancelada exitosamente"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Reservación cancelada exitosamente",
+		"cancellation": cancellation,
+	})
+}
+
+// GetCancellationQuote shows how many credits would be refunded if the
+// reservation were cancelled now
+func (uc *UserController) GetCancellationQuote(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reservation ID"})
+		return
+	}
+
+	quote, err := uc.reservationService.QuoteCancellation(uint(reservationID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quote": quote})
 }
 
 func (uc *UserController) GetSpaces(c *gin.Context) {
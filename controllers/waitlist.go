@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/services"
+	"github.com/gin-gonic/gin"
+)
+
+type WaitlistController struct {
+	waitlistService *services.WaitlistService
+}
+
+func NewWaitlistController() *WaitlistController {
+	return &WaitlistController{
+		waitlistService: services.NewWaitlistService(),
+	}
+}
+
+type JoinWaitlistRequest struct {
+	SpaceID   uint      `json:"space_id" binding:"required"`
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	AutoBook  bool      `json:"auto_book"` // Book automatically if enough credits
+}
+
+func (wc *WaitlistController) JoinWaitlist(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req JoinWaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime, endTime := toLocalReservationTimes(req.StartTime, req.EndTime)
+
+	entry, err := wc.waitlistService.JoinWaitlist(userID.(uint), req.SpaceID, startTime, endTime, req.AutoBook)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Agregado a la lista de espera",
+		"entry":   entry,
+	})
+}
+
+func (wc *WaitlistController) GetWaitlist(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	entries, err := wc.waitlistService.GetUserEntries(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener la lista de espera"})
+		return
+	}
+
+	if entries == nil {
+		entries = []models.WaitlistEntry{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+func (wc *WaitlistController) LeaveWaitlist(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	entryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de solicitud inválido"})
+		return
+	}
+
+	if err := wc.waitlistService.LeaveWaitlist(uint(entryID), userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Eliminado de la lista de espera"})
+}
+
+func (wc *WaitlistController) AcceptOffer(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	entryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de solicitud inválido"})
+		return
+	}
+
+	reservation, err := wc.waitlistService.AcceptOffer(uint(entryID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	broadcastCalendarRefresh("waitlist:booked")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Reservación creada desde la lista de espera",
+		"reservation": reservation,
+	})
+}
+
+// GetActiveWaitlist lists every waiting or offered entry (admin-only)
+func (wc *WaitlistController) GetActiveWaitlist(c *gin.Context) {
+	entries, err := wc.waitlistService.GetActiveEntries()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener la lista de espera"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/IkingariSolorzano/omma-be/config"
 	"github.com/IkingariSolorzano/omma-be/routes"
+	"github.com/IkingariSolorzano/omma-be/services"
 	"github.com/joho/godotenv"
 )
 
@@ -32,8 +33,14 @@ func main() {
 	config.InitializeWebSocketHub()
 	log.Println("WebSocket hub started")
 
-	// Setup routes with WebSocket hub
-	r := routes.SetupRoutes(config.WSHub)
+	// Start background maintenance jobs (credit expiry, reservation
+	// completion, stale approvals, waitlist offers)
+	scheduler := services.NewSchedulerService(services.DefaultJobs()...)
+	scheduler.Start()
+	log.Println("Background job scheduler started")
+
+	// Setup routes with WebSocket hub and the scheduler for manual job runs
+	r := routes.SetupRoutes(config.WSHub, scheduler)
 
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
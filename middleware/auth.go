@@ -56,6 +56,31 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// WebSocketAuth identifies the user of a WebSocket connection from the token
+// query parameter, since browsers cannot send headers with the upgrade. A
+// connection without a valid token is still accepted as anonymous.
+func WebSocketAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			c.Next()
+			return
+		}
+
+		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+			return []byte(os.Getenv("JWT_SECRET")), nil
+		})
+		if err == nil && token.Valid {
+			if claims, ok := token.Claims.(*Claims); ok {
+				c.Set("user_id", claims.UserID)
+				c.Set("user_email", claims.Email)
+				c.Set("user_role", claims.Role)
+			}
+		}
+		c.Next()
+	}
+}
+
 func AdminOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("user_role")
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// responseRecorder keeps a copy of what the handler writes
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// IdempotencyStore claims keys and keeps their responses. It is implemented
+// by services.IdempotencyService, which cannot be imported from here.
+type IdempotencyStore interface {
+	Begin(userID uint, key, method, path, requestHash string) (*models.IdempotencyKey, bool, error)
+	Complete(recordID uint, status int, body string) error
+	Release(recordID uint) error
+}
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key header, so a double submit does not repeat a payment,
+// credit grant or booking. Requests without the header run as usual. It must
+// run after AuthMiddleware, since keys belong to the authenticated user.
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "La clave de idempotencia es demasiado larga"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer la solicitud"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		hash.Write(body)
+
+		userID, _ := c.Get("user_id")
+		record, replay, err := store.Begin(userID.(uint), key, c.Request.Method, c.Request.URL.Path, hex.EncodeToString(hash.Sum(nil)))
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrIdempotencyKeyReused):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			case errors.Is(err, models.ErrIdempotencyKeyInProcess):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar la clave de idempotencia"})
+			}
+			c.Abort()
+			return
+		}
+
+		if replay {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.ResponseStatus, "application/json; charset=utf-8", []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// Server errors and panics free the key so that a retry runs the
+		// request again; any other response is what retries get back
+		completed := false
+		defer func() {
+			if !completed {
+				if err := store.Release(record.ID); err != nil {
+					log.Printf("Error al liberar la clave de idempotencia %d: %v", record.ID, err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		if err := store.Complete(record.ID, status, recorder.body.String()); err != nil {
+			log.Printf("Error al guardar la respuesta de la clave de idempotencia %d: %v", record.ID, err)
+			return
+		}
+		completed = true
+	}
+}
@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(upAddReservationOverlapConstraint, downAddReservationOverlapConstraint)
+}
+
+func upAddReservationOverlapConstraint(tx *sql.Tx) error {
+	// btree_gist lets the exclusion constraint combine space_id (=) with the
+	// time range overlap operator (&&) in a single GiST index
+	if _, err := tx.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist"); err != nil {
+		return fmt.Errorf("failed to create btree_gist extension: %w", err)
+	}
+
+	// The constraint cannot be created while overlapping reservations exist,
+	// report them so they can be resolved by hand
+	rows, err := tx.Query(`
+		SELECT a.id, b.id
+		FROM reservations a
+		JOIN reservations b ON a.space_id = b.space_id AND a.id < b.id
+		WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL
+			AND a.status IN ('pending', 'confirmed') AND b.status IN ('pending', 'confirmed')
+			AND tstzrange(a.start_time, a.end_time, '[)') && tstzrange(b.start_time, b.end_time, '[)')
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to check overlapping reservations: %w", err)
+	}
+	var overlaps []string
+	for rows.Next() {
+		var first, second uint
+		if err := rows.Scan(&first, &second); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read overlapping reservations: %w", err)
+		}
+		overlaps = append(overlaps, fmt.Sprintf("%d/%d", first, second))
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("failed to read overlapping reservations: %w", err)
+	}
+	rows.Close()
+	if len(overlaps) > 0 {
+		return fmt.Errorf("overlapping active reservations must be cancelled before adding the constraint: %v", overlaps)
+	}
+
+	// Active (pending or confirmed) reservations of the same space can never
+	// overlap. Ranges are half-open so back-to-back bookings are allowed.
+	query := `
+		ALTER TABLE reservations
+		ADD CONSTRAINT reservations_no_overlap
+		EXCLUDE USING gist (
+			space_id WITH =,
+			tstzrange(start_time, end_time, '[)') WITH &&
+		)
+		WHERE (status IN ('pending', 'confirmed') AND deleted_at IS NULL)
+	`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to add reservation overlap constraint: %w", err)
+	}
+
+	return nil
+}
+
+func downAddReservationOverlapConstraint(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_no_overlap")
+	if err != nil {
+		return fmt.Errorf("failed to drop reservation overlap constraint: %w", err)
+	}
+
+	return nil
+}
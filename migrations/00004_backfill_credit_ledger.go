@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(upBackfillCreditLedger, downBackfillCreditLedger)
+}
+
+func upBackfillCreditLedger(tx *sql.Tx) error {
+	// Rows written before the ledger only covered some grants and refunds, so
+	// they are kept for reference but left out of balances
+	if _, err := tx.Exec(`UPDATE credit_transactions SET type = 'legacy'`); err != nil {
+		return fmt.Errorf("failed to mark legacy credit transactions: %w", err)
+	}
+
+	// Open the ledger with the current balance of every spendable lot. Expired
+	// lots start at zero; reactivating them posts their credits back.
+	query := `
+		INSERT INTO credit_transactions (created_at, user_id, amount, type, reason, credit_id)
+		SELECT NOW(), user_id, amount, 'opening_balance', 'Saldo inicial del libro de créditos', id
+		FROM credits
+		WHERE deleted_at IS NULL AND is_active = true AND amount > 0
+	`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to post opening balances: %w", err)
+	}
+
+	return nil
+}
+
+func downBackfillCreditLedger(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DELETE FROM credit_transactions WHERE type = 'opening_balance'`); err != nil {
+		return fmt.Errorf("failed to remove opening balances: %w", err)
+	}
+
+	// Before the ledger every transaction was written as a refund
+	if _, err := tx.Exec(`UPDATE credit_transactions SET type = 'refund' WHERE type = 'legacy'`); err != nil {
+		return fmt.Errorf("failed to restore legacy credit transactions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(upHoldPendingReservationCredits, downHoldPendingReservationCredits)
+}
+
+func upHoldPendingReservationCredits(tx *sql.Tx) error {
+	// Reservations already waiting for approval get the hold they would have
+	// placed when they were created, so approving them captures it as usual
+	query := `
+		INSERT INTO credit_holds (user_id, reservation_id, amount, status, created_at, updated_at)
+		SELECT r.user_id, r.id, r.credits_used, 'active', NOW(), NOW()
+		FROM reservations r
+		WHERE r.status = 'pending' AND r.user_id IS NOT NULL AND r.credits_used > 0
+		  AND r.deleted_at IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM credit_holds h
+			WHERE h.reservation_id = r.id AND h.status = 'active'
+		  )
+	`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to hold credits of pending reservations: %w", err)
+	}
+
+	return nil
+}
+
+func downHoldPendingReservationCredits(tx *sql.Tx) error {
+	// Backfilled holds cannot be told apart from the ones placed afterwards and
+	// are still valid, so they are kept
+	return nil
+}
@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(upConvertMoneyToCents, downConvertMoneyToCents)
+}
+
+// moneyColumn is a decimal amount replaced by an integer amount in cents.
+// AutoMigrate has already added the cents column when this runs.
+type moneyColumn struct {
+	table string
+	from  string
+	to    string
+	// exact columns hold real amounts, which must not have fractions of a
+	// cent; the others are unit costs and are rounded
+	exact bool
+}
+
+var moneyColumns = []moneyColumn{
+	{"payments", "amount", "amount_cents", true},
+	{"payments", "credit_cost", "credit_cost_cents", false},
+	{"credit_packages", "price", "price_cents", true},
+	{"membership_plans", "monthly_price", "monthly_price_cents", true},
+	{"membership_periods", "amount", "amount_cents", true},
+}
+
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+		)
+	`, table, column).Scan(&exists)
+	return exists, err
+}
+
+func upConvertMoneyToCents(tx *sql.Tx) error {
+	for _, col := range moneyColumns {
+		// Fresh databases never had the decimal column
+		exists, err := columnExists(tx, col.table, col.from)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s.%s: %w", col.table, col.from, err)
+		}
+		if !exists {
+			continue
+		}
+
+		// Rounding each row must not change the total, otherwise historical
+		// reports would no longer add up
+		if col.exact {
+			var inexact int
+			query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s * 100 <> ROUND(%s * 100)`, col.table, col.from, col.from)
+			if err := tx.QueryRow(query).Scan(&inexact); err != nil {
+				return fmt.Errorf("failed to check %s.%s: %w", col.table, col.from, err)
+			}
+			if inexact > 0 {
+				return fmt.Errorf("%d rows of %s have fractions of a cent in %s and must be fixed by hand", inexact, col.table, col.from)
+			}
+		}
+
+		query := fmt.Sprintf(`UPDATE %s SET %s = ROUND(%s * 100)`, col.table, col.to, col.from)
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to convert %s.%s to cents: %w", col.table, col.from, err)
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, col.table, col.from)); err != nil {
+			return fmt.Errorf("failed to drop %s.%s: %w", col.table, col.from, err)
+		}
+	}
+
+	return nil
+}
+
+func downConvertMoneyToCents(tx *sql.Tx) error {
+	for _, col := range moneyColumns {
+		exists, err := columnExists(tx, col.table, col.to)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s.%s: %w", col.table, col.to, err)
+		}
+		if !exists {
+			continue
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s decimal NOT NULL DEFAULT 0`, col.table, col.from)); err != nil {
+			return fmt.Errorf("failed to restore %s.%s: %w", col.table, col.from, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = %s / 100.0`, col.table, col.from, col.to)); err != nil {
+			return fmt.Errorf("failed to convert %s.%s back from cents: %w", col.table, col.to, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, col.table, col.to)); err != nil {
+			return fmt.Errorf("failed to drop %s.%s: %w", col.table, col.to, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(upAllowOnlinePaymentsWithoutAdmin, downAllowOnlinePaymentsWithoutAdmin)
+}
+
+func upAllowOnlinePaymentsWithoutAdmin(tx *sql.Tx) error {
+	// Payments confirmed by the online gateway are not registered by an admin
+	if _, err := tx.Exec(`ALTER TABLE payments ALTER COLUMN admin_id DROP NOT NULL`); err != nil {
+		return fmt.Errorf("failed to make payments.admin_id nullable: %w", err)
+	}
+
+	return nil
+}
+
+func downAllowOnlinePaymentsWithoutAdmin(tx *sql.Tx) error {
+	var online int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM payments WHERE admin_id IS NULL`).Scan(&online); err != nil {
+		return fmt.Errorf("failed to count payments without admin: %w", err)
+	}
+	if online > 0 {
+		return fmt.Errorf("%d payments have no admin and must be assigned one before reverting", online)
+	}
+
+	if _, err := tx.Exec(`ALTER TABLE payments ALTER COLUMN admin_id SET NOT NULL`); err != nil {
+		return fmt.Errorf("failed to make payments.admin_id required: %w", err)
+	}
+
+	return nil
+}
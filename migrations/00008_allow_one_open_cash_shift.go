@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(upAllowOneOpenCashShift, downAllowOneOpenCashShift)
+}
+
+func upAllowOneOpenCashShift(tx *sql.Tx) error {
+	// The front desk has a single register, so only one shift can be open
+	if _, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_cash_shifts_one_open ON cash_shifts (status) WHERE status = 'open'`); err != nil {
+		return fmt.Errorf("failed to create single open cash shift index: %w", err)
+	}
+
+	return nil
+}
+
+func downAllowOneOpenCashShift(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DROP INDEX IF EXISTS idx_cash_shifts_one_open`); err != nil {
+		return fmt.Errorf("failed to drop single open cash shift index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(upAllowCancellationsWithoutUser, downAllowCancellationsWithoutUser)
+}
+
+func upAllowCancellationsWithoutUser(tx *sql.Tx) error {
+	// Reservations of external clients have no user to record the
+	// cancellation against
+	if _, err := tx.Exec(`ALTER TABLE cancellations ALTER COLUMN user_id DROP NOT NULL`); err != nil {
+		return fmt.Errorf("failed to make cancellations.user_id nullable: %w", err)
+	}
+
+	return nil
+}
+
+func downAllowCancellationsWithoutUser(tx *sql.Tx) error {
+	var external int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM cancellations WHERE user_id IS NULL`).Scan(&external); err != nil {
+		return fmt.Errorf("failed to count cancellations without user: %w", err)
+	}
+	if external > 0 {
+		return fmt.Errorf("%d cancellations have no user and must be removed before reverting", external)
+	}
+
+	if _, err := tx.Exec(`ALTER TABLE cancellations ALTER COLUMN user_id SET NOT NULL`); err != nil {
+		return fmt.Errorf("failed to make cancellations.user_id required: %w", err)
+	}
+
+	return nil
+}
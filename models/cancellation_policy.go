@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CancellationPolicy defines how many credits are refunded when a reservation is
+// cancelled, depending on how far in advance it happens. A policy without SpaceID
+// is the default for every space that has no policy of its own.
+type CancellationPolicy struct {
+	ID        uint                     `json:"id" gorm:"primaryKey"`
+	Name      string                   `json:"name" gorm:"not null"`
+	SpaceID   *uint                    `json:"space_id" gorm:"index"`
+	Space     *Space                   `json:"space,omitempty"`
+	IsActive  bool                     `json:"is_active"`
+	Tiers     []CancellationPolicyTier `json:"tiers" gorm:"foreignKey:PolicyID"`
+	CreatedAt time.Time                `json:"created_at"`
+	UpdatedAt time.Time                `json:"updated_at"`
+	DeletedAt gorm.DeletedAt           `json:"-" gorm:"index"`
+}
+
+// CancellationPolicyTier refunds RefundPercent of the credits used when the
+// reservation is cancelled at least MinHours before it starts
+type CancellationPolicyTier struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	PolicyID      uint      `json:"policy_id" gorm:"not null;index"`
+	MinHours      float64   `json:"min_hours" gorm:"not null"`
+	RefundPercent int       `json:"refund_percent" gorm:"not null"` // 0-100
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CashShiftStatus string
+
+const (
+	CashShiftOpen   CashShiftStatus = "open"
+	CashShiftClosed CashShiftStatus = "closed"
+)
+
+// CashPaymentMethod is the payment method whose total is counted together
+// with the opening cash in the drawer
+const CashPaymentMethod = "cash"
+
+// ErrPaymentShiftClosed is returned when something tries to change or remove
+// a payment of a closed cash shift
+var ErrPaymentShiftClosed = errors.New("El pago pertenece a un corte de caja cerrado y no se puede modificar")
+
+// ErrNoOpenCashShift is returned when an admin takes cash while the register
+// is closed
+var ErrNoOpenCashShift = errors.New("No hay un turno de caja abierto; abra la caja antes de registrar pagos en efectivo")
+
+// CashShift is a front desk shift, from opening the register to the corte de
+// caja. Payments registered by an admin while it is open belong to it.
+type CashShift struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	Status      CashShiftStatus  `json:"status" gorm:"not null;default:'open';index"`
+	OpenedBy    uint             `json:"opened_by" gorm:"not null"`
+	Opener      *User            `json:"opener,omitempty" gorm:"foreignKey:OpenedBy"`
+	OpenedAt    time.Time        `json:"opened_at" gorm:"not null"`
+	OpeningCash Money            `json:"opening_cash" gorm:"column:opening_cash_cents;not null;default:0"`
+	Currency    string           `json:"currency" gorm:"size:3;not null;default:'MXN'"`
+	ClosedBy    *uint            `json:"closed_by"`
+	Closer      *User            `json:"closer,omitempty" gorm:"foreignKey:ClosedBy"`
+	ClosedAt    *time.Time       `json:"closed_at"`
+	Expected    Money            `json:"expected" gorm:"column:expected_cents;not null;default:0"` // Opening cash plus every payment, set at close
+	Counted     Money            `json:"counted" gorm:"column:counted_cents;not null;default:0"`
+	Difference  Money            `json:"difference" gorm:"column:difference_cents;not null;default:0"` // Counted minus expected; negative is a shortage
+	Notes       string           `json:"notes"`
+	Totals      []CashShiftTotal `json:"totals,omitempty" gorm:"foreignKey:ShiftID"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// CashShiftTotal is what the system expected and what the admin counted for
+// one payment method when the shift was closed
+type CashShiftTotal struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	ShiftID       uint   `json:"shift_id" gorm:"not null;uniqueIndex:idx_cash_shift_method"`
+	PaymentMethod string `json:"payment_method" gorm:"not null;uniqueIndex:idx_cash_shift_method"`
+	Payments      int    `json:"payments"`
+	Expected      Money  `json:"expected" gorm:"column:expected_cents;not null"` // Includes the opening cash for cash
+	Counted       Money  `json:"counted" gorm:"column:counted_cents;not null"`
+	Difference    Money  `json:"difference" gorm:"column:difference_cents;not null"`
+}
+
+// BeforeCreate puts a payment registered at the front desk in the open cash
+// shift. Without one, cash is rejected so it cannot escape a corte de caja;
+// refunds, voids and payments by other methods are recorded outside any
+// shift. The shift is share-locked so it cannot close before the payment is
+// committed and counted.
+func (p *Payment) BeforeCreate(tx *gorm.DB) error {
+	if p.AdminID == nil || p.CashShiftID != nil {
+		return nil
+	}
+
+	var shift CashShift
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("status = ?", CashShiftOpen).
+		Limit(1).
+		Find(&shift).Error
+	if err != nil {
+		return err
+	}
+	if shift.ID == 0 {
+		if p.RefundOfID == nil && strings.EqualFold(strings.TrimSpace(p.PaymentMethod), CashPaymentMethod) {
+			return ErrNoOpenCashShift
+		}
+		return nil
+	}
+	p.CashShiftID = &shift.ID
+	return nil
+}
+
+// refundColumns are the only columns of a payment of a closed shift that can
+// still be updated, to record a later refund against it
+var refundColumns = map[string]bool{"refunded_cents": true, "status": true, "updated_at": true}
+
+// BeforeUpdate locks the payments of a closed shift. Only an update of the
+// refund columns, recording a later refund against the payment, is allowed;
+// anything else, a Save included, is checked.
+func (p *Payment) BeforeUpdate(tx *gorm.DB) error {
+	if p.onlyRefundColumns(tx) {
+		return nil
+	}
+	return p.checkShiftOpen(tx)
+}
+
+func (p *Payment) onlyRefundColumns(tx *gorm.DB) bool {
+	updates, ok := tx.Statement.Dest.(map[string]interface{})
+	if !ok || len(updates) == 0 {
+		return false
+	}
+	for name := range updates {
+		column := name
+		if tx.Statement.Schema != nil {
+			if field := tx.Statement.Schema.LookUpField(name); field != nil {
+				column = field.DBName
+			}
+		}
+		if !refundColumns[column] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Payment) BeforeDelete(tx *gorm.DB) error {
+	return p.checkShiftOpen(tx)
+}
+
+func (p *Payment) checkShiftOpen(tx *gorm.DB) error {
+	if p.ID == 0 {
+		return nil
+	}
+
+	var closed int64
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Table("payments").
+		Joins("JOIN cash_shifts ON cash_shifts.id = payments.cash_shift_id").
+		Where("payments.id = ? AND cash_shifts.status = ?", p.ID, CashShiftClosed).
+		Count(&closed).Error
+	if err != nil {
+		return err
+	}
+	if closed > 0 {
+		return ErrPaymentShiftClosed
+	}
+	return nil
+}
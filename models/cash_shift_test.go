@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds statements without connecting to a database
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return db
+}
+
+// Only recording a refund may touch a payment without checking its shift
+func TestPaymentOnlyRefundColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		dest interface{}
+		want bool
+	}{
+		{"refund status", map[string]interface{}{"refunded_cents": Money(500), "status": PaymentPartiallyRefunded}, true},
+		{"refund status by field name", map[string]interface{}{"RefundedAmount": Money(500), "Status": PaymentRefunded}, true},
+		{"single status update", map[string]interface{}{"status": PaymentVoided}, true},
+		{"amount", map[string]interface{}{"amount_cents": Money(100)}, false},
+		{"refund and method", map[string]interface{}{"refunded_cents": Money(500), "payment_method": "card"}, false},
+		{"empty map", map[string]interface{}{}, false},
+		{"save", &Payment{ID: 1, Amount: 100}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &Payment{ID: 1}
+			tx := dryRunDB(t).Model(payment)
+			if err := tx.Statement.Parse(payment); err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			tx.Statement.Dest = tt.dest
+
+			if got := payment.onlyRefundColumns(tx); got != tt.want {
+				t.Fatalf("onlyRefundColumns(%v) = %v, want %v", tt.dest, got, tt.want)
+			}
+		})
+	}
+}
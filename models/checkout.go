@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+type CheckoutStatus string
+
+const (
+	CheckoutPending CheckoutStatus = "pending"
+	CheckoutPaid    CheckoutStatus = "paid"
+	CheckoutFailed  CheckoutStatus = "failed"
+	CheckoutExpired CheckoutStatus = "expired"
+)
+
+// Checkout is a credit purchase a professional started with the online
+// payment gateway. What it buys is fixed when it is created, so a package
+// that changes or stops selling before the gateway confirms the payment is
+// still delivered as it was offered.
+type Checkout struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	UserID       uint           `json:"user_id" gorm:"not null;index"`
+	User         User           `json:"user,omitempty"`
+	PackageID    *uint          `json:"package_id"`
+	Package      *CreditPackage `json:"package,omitempty"`
+	Credits      int            `json:"credits" gorm:"not null"` // Credits granted once paid, bonus included
+	ValidityDays int            `json:"validity_days" gorm:"not null"`
+	Amount       Money          `json:"amount" gorm:"column:amount_cents;not null"`
+	Currency     string         `json:"currency" gorm:"size:3;not null;default:'MXN'"`
+	Provider     string         `json:"provider" gorm:"not null;uniqueIndex:idx_checkout_provider_ref"`
+	ProviderRef  *string        `json:"provider_ref" gorm:"uniqueIndex:idx_checkout_provider_ref"` // Checkout ID at the provider
+	CheckoutURL  string         `json:"checkout_url"`
+	Status       CheckoutStatus `json:"status" gorm:"not null;default:'pending';index"`
+	PaymentID    *uint          `json:"payment_id"` // Set once paid
+	Payment      *Payment       `json:"payment,omitempty"`
+	CompletedAt  *time.Time     `json:"completed_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// PaymentWebhookEvent is a notification received from the payment gateway.
+// Providers resend events until they are acknowledged, so each one is stored
+// once and a repeated delivery is acknowledged without being applied again.
+type PaymentWebhookEvent struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Provider    string     `json:"provider" gorm:"not null;uniqueIndex:idx_webhook_provider_event"`
+	EventID     string     `json:"event_id" gorm:"not null;uniqueIndex:idx_webhook_provider_event"`
+	CheckoutID  *uint      `json:"checkout_id" gorm:"index"`
+	Status      string     `json:"status"` // Checkout status reported by the event
+	Payload     string     `json:"payload" gorm:"type:text"`
+	ProcessedAt *time.Time `json:"processed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -9,12 +10,30 @@ import (
 type TransactionType string
 
 const (
-	TransactionTypePurchase   TransactionType = "purchase"
-	TransactionTypeRefund     TransactionType = "refund"
-	TransactionTypeDeduction  TransactionType = "deduction"
-	TransactionTypeCorrection TransactionType = "correction"
+	TransactionTypePurchase     TransactionType = "purchase"   // Lot granted by a payment
+	TransactionTypeGrant        TransactionType = "grant"      // Lot granted by an admin
+	TransactionTypeMembership   TransactionType = "membership" // Lot granted by a membership period
+	TransactionTypeRollover     TransactionType = "rollover"   // Unused membership credits carried into the next period
+	TransactionTypePromo        TransactionType = "promo"      // Lot granted by a promo code or gift voucher
+	TransactionTypeClawback     TransactionType = "clawback"   // Unspent credits taken back when their payment is refunded
+	TransactionTypeRefund       TransactionType = "refund"     // Credits returned for a reservation
+	TransactionTypeDeduction    TransactionType = "deduction"  // Credits charged for a reservation
+	TransactionTypePenalty      TransactionType = "penalty"    // Credits charged for a penalty
+	TransactionTypeAdjustment   TransactionType = "adjustment" // Manual deduction by an admin
+	TransactionTypeTransferIn   TransactionType = "transfer_in"
+	TransactionTypeTransferOut  TransactionType = "transfer_out"
+	TransactionTypeExpiration   TransactionType = "expiration"
+	TransactionTypeReactivation TransactionType = "reactivation"
+	TransactionTypeExtension    TransactionType = "extension" // Expiry change only, amount is 0
+	TransactionTypeCorrection   TransactionType = "correction"
+	TransactionTypeOpening      TransactionType = "opening_balance" // Lot balances when the ledger started
+	TransactionTypeLegacy       TransactionType = "legacy"          // Recorded before the ledger, not part of balances
 )
 
+// ErrLedgerAppendOnly is returned when something tries to change or remove a
+// ledger entry
+var ErrLedgerAppendOnly = errors.New("Los movimientos de créditos no se pueden modificar ni eliminar")
+
 type Credit struct {
 	ID           uint           `gorm:"primarykey" json:"id"`
 	CreatedAt    time.Time      `json:"created_at"`
@@ -28,15 +47,54 @@ type Credit struct {
 	IsActive     bool           `json:"is_active"`
 }
 
+// CreditTransaction is an entry of the append-only credit ledger. Every change
+// to a credit lot posts one entry per lot with the signed amount, so the sum
+// of a user's entries up to a date is their balance at that date.
 type CreditTransaction struct {
 	ID            uint            `gorm:"primarykey" json:"id"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UserID        uint            `json:"user_id"`
+	CreatedAt     time.Time       `json:"created_at" gorm:"index"`
+	UserID        uint            `json:"user_id" gorm:"index"`
 	User          User            `json:"-"`
 	Amount        int             `json:"amount"`
 	Type          TransactionType `json:"type"`
 	Reason        string          `json:"reason"`
 	Notes         string          `json:"notes"`
+	CreditID      *uint           `json:"credit_id,omitempty" gorm:"index"`
+	Credit        *Credit         `json:"-"`
+	AdminID       *uint           `json:"admin_id,omitempty"`
+	Admin         *User           `json:"admin,omitempty" gorm:"foreignKey:AdminID"`
 	ReservationID *uint           `json:"reservation_id,omitempty"`
 	Reservation   *Reservation    `json:"-"`
+	PaymentID     *uint           `json:"payment_id,omitempty"`
+}
+
+// BeforeUpdate keeps the ledger append-only; mistakes are fixed by posting a
+// correction entry
+func (t *CreditTransaction) BeforeUpdate(tx *gorm.DB) error {
+	return ErrLedgerAppendOnly
+}
+
+func (t *CreditTransaction) BeforeDelete(tx *gorm.DB) error {
+	return ErrLedgerAppendOnly
+}
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "active"
+	HoldCaptured HoldStatus = "captured" // Converted into a deduction on approval
+	HoldReleased HoldStatus = "released" // Given back on rejection or cancellation
+)
+
+// CreditHold sets aside the credits of a reservation pending approval. Held
+// credits stay in their lots but are not available for anything else.
+type CreditHold struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	ReservationID uint       `json:"reservation_id" gorm:"not null;index"`
+	Amount        int        `json:"amount" gorm:"not null"`
+	Status        HoldStatus `json:"status" gorm:"default:'active';index"`
+	ResolvedAt    *time.Time `json:"resolved_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
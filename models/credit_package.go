@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreditPackage is a bundle of credits sold at a fixed price, e.g. 10 credits
+// for 90 pesos. Bonus credits are granted on top of the paid ones and the
+// whole lot expires ValidityDays after the purchase.
+type CreditPackage struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"not null"`
+	Description  string         `json:"description"`
+	Price        Money          `json:"price" gorm:"column:price_cents;not null;default:0"`
+	Currency     string         `json:"currency" gorm:"size:3;not null;default:'MXN'"`
+	Credits      int            `json:"credits" gorm:"not null"`
+	BonusCredits int            `json:"bonus_credits" gorm:"default:0"`
+	ValidityDays int            `json:"validity_days" gorm:"not null"`
+	ActiveFrom   *time.Time     `json:"active_from"` // Optional sale window
+	ActiveUntil  *time.Time     `json:"active_until"`
+	IsActive     bool           `json:"is_active"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TotalCredits is what a purchase of the package grants
+func (p *CreditPackage) TotalCredits() int {
+	return p.Credits + p.BonusCredits
+}
+
+// IsAvailable reports whether the package can be sold at the given moment
+func (p *CreditPackage) IsAvailable(at time.Time) bool {
+	if !p.IsActive {
+		return false
+	}
+	if p.ActiveFrom != nil && at.Before(*p.ActiveFrom) {
+		return false
+	}
+	if p.ActiveUntil != nil && at.After(*p.ActiveUntil) {
+		return false
+	}
+	return true
+}
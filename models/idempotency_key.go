@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyProcessing IdempotencyStatus = "processing"
+	IdempotencyCompleted  IdempotencyStatus = "completed"
+)
+
+var (
+	ErrIdempotencyKeyReused    = errors.New("La clave de idempotencia ya se usó con una solicitud distinta")
+	ErrIdempotencyKeyInProcess = errors.New("La solicitud con esta clave de idempotencia aún se está procesando")
+)
+
+// IdempotencyKey stores the first response to a request sent with an
+// Idempotency-Key header so that retries get the same response instead of
+// repeating the operation. Keys are scoped to the user who sent them.
+type IdempotencyKey struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	UserID         uint              `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key            string            `json:"key" gorm:"not null;size:255;uniqueIndex:idx_idempotency_user_key"`
+	Method         string            `json:"method" gorm:"not null"`
+	Path           string            `json:"path" gorm:"not null"`
+	RequestHash    string            `json:"request_hash" gorm:"not null"` // SHA-256 of method, path and body
+	Status         IdempotencyStatus `json:"status" gorm:"not null"`
+	ResponseStatus int               `json:"response_status"`
+	ResponseBody   string            `json:"response_body" gorm:"type:text"`
+	ExpiresAt      time.Time         `json:"expires_at" gorm:"not null;index"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
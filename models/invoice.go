@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// TaxData is the fiscal information a CFDI needs about its receiver
+type TaxData struct {
+	RFC       string `json:"rfc" gorm:"size:13"`
+	LegalName string `json:"legal_name"`             // Name exactly as registered with the SAT
+	Regime    string `json:"regime" gorm:"size:3"`   // c_RegimenFiscal, e.g. "612"
+	ZipCode   string `json:"zip_code" gorm:"size:5"` // Código postal of the domicilio fiscal
+	CFDIUse   string `json:"cfdi_use" gorm:"size:4"` // c_UsoCFDI, e.g. "G03"
+}
+
+// IsComplete reports whether every field needed to invoice is filled in
+func (t TaxData) IsComplete() bool {
+	return t.RFC != "" && t.LegalName != "" && t.Regime != "" && t.ZipCode != "" && t.CFDIUse != ""
+}
+
+type InvoiceStatus string
+
+const (
+	InvoiceStamped InvoiceStatus = "stamped"
+	InvoiceFailed  InvoiceStatus = "failed" // The PAC rejected it; it can be requested again
+)
+
+// Invoice is the CFDI 4.0 issued for a payment. The receiver's tax data is
+// copied when it is generated, so later profile changes do not alter it.
+type Invoice struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	PaymentID   uint          `json:"payment_id" gorm:"not null;uniqueIndex"`
+	Payment     *Payment      `json:"payment,omitempty"`
+	UserID      uint          `json:"user_id" gorm:"not null;index"`
+	User        *User         `json:"user,omitempty"`
+	Status      InvoiceStatus `json:"status" gorm:"not null"`
+	Series      string        `json:"series"`
+	Folio       string        `json:"folio"`
+	UUID        string        `json:"uuid" gorm:"index"` // Folio fiscal assigned by the PAC
+	Receiver    TaxData       `json:"receiver" gorm:"embedded;embeddedPrefix:receiver_"`
+	Subtotal    Money         `json:"subtotal" gorm:"column:subtotal_cents;not null"`
+	VAT         Money         `json:"vat" gorm:"column:vat_cents;not null"`
+	Total       Money         `json:"total" gorm:"column:total_cents;not null"`
+	Currency    string        `json:"currency" gorm:"size:3;not null;default:'MXN'"`
+	PaymentForm string        `json:"payment_form" gorm:"size:2"` // c_FormaPago
+	PACProvider string        `json:"pac_provider"`
+	Error       string        `json:"error"` // Why the PAC rejected it
+	// CancellationRequired is set when the payment was refunded or voided
+	// after stamping; the CFDI has to be cancelled at the SAT or offset with
+	// a credit note (CFDI de egreso)
+	CancellationRequired bool       `json:"cancellation_required" gorm:"not null;default:false;index"`
+	CancellationReason   string     `json:"cancellation_reason"`
+	XML                  string     `json:"-" gorm:"type:text"`
+	PDF                  []byte     `json:"-"`
+	StampedAt            *time.Time `json:"stamped_at"`
+	CreatedBy            uint       `json:"created_by"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
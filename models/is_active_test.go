@@ -0,0 +1,40 @@
+package models
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+// A record created inactive must be stored inactive. GORM leaves a zero value
+// out of the INSERT when its field has a default, so with a default:true tag
+// the database default would win over false.
+func TestIsActiveHasNoDefault(t *testing.T) {
+	tests := []struct {
+		name  string
+		model interface{}
+	}{
+		{"cancellation policy", &CancellationPolicy{}},
+		{"pricing rule", &SpacePricingRule{}},
+		{"credit package", &CreditPackage{}},
+		{"membership plan", &MembershipPlan{}},
+		{"promo code", &PromoCode{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := schema.Parse(tt.model, &sync.Map{}, schema.NamingStrategy{})
+			if err != nil {
+				t.Fatalf("schema.Parse: %v", err)
+			}
+			field := parsed.LookUpField("is_active")
+			if field == nil {
+				t.Fatal("no is_active column")
+			}
+			if field.HasDefaultValue {
+				t.Fatalf("is_active has default %q, so false would not be inserted", field.DefaultValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+type JobRunStatus string
+
+const (
+	JobRunning   JobRunStatus = "running"
+	JobSucceeded JobRunStatus = "succeeded"
+	JobFailed    JobRunStatus = "failed"
+)
+
+// JobRun records one execution of a scheduled background job
+type JobRun struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	JobName    string       `json:"job_name" gorm:"not null;index"`
+	Instance   string       `json:"instance"` // Host and process that ran the job
+	Status     JobRunStatus `json:"status" gorm:"not null"`
+	StartedAt  time.Time    `json:"started_at" gorm:"not null;index"`
+	FinishedAt *time.Time   `json:"finished_at"`
+	Affected   int64        `json:"affected"` // Rows changed by the job
+	Error      string       `json:"error"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// JobLock is a lease that lets only one instance run a job at a time
+type JobLock struct {
+	JobName     string    `json:"job_name" gorm:"primaryKey"`
+	LockedBy    string    `json:"locked_by"`
+	LockedUntil time.Time `json:"locked_until" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
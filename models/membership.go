@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MembershipPlan is a monthly subscription: a fee that includes a number of
+// credits every period
+type MembershipPlan struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Name           string         `json:"name" gorm:"not null"`
+	Description    string         `json:"description"`
+	MonthlyPrice   Money          `json:"monthly_price" gorm:"column:monthly_price_cents;not null;default:0"`
+	Currency       string         `json:"currency" gorm:"size:3;not null;default:'MXN'"`
+	MonthlyCredits int            `json:"monthly_credits" gorm:"not null"`
+	RolloverCap    int            `json:"rollover_cap" gorm:"default:0"` // Unused credits carried into the next period, at most
+	IsActive       bool           `json:"is_active"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+type MembershipStatus string
+
+const (
+	MembershipActive    MembershipStatus = "active"
+	MembershipPastDue   MembershipStatus = "past_due" // A period was not paid by its due date
+	MembershipCancelled MembershipStatus = "cancelled"
+)
+
+// Membership subscribes a professional to a plan. Periods start on the
+// billing day of each month.
+type Membership struct {
+	ID                 uint               `json:"id" gorm:"primaryKey"`
+	UserID             uint               `json:"user_id" gorm:"not null;index"`
+	User               User               `json:"user,omitempty"`
+	PlanID             uint               `json:"plan_id" gorm:"not null"`
+	Plan               MembershipPlan     `json:"plan,omitempty"`
+	BillingDay         int                `json:"billing_day" gorm:"not null"` // 1-28
+	Status             MembershipStatus   `json:"status" gorm:"default:'active'"`
+	CurrentPeriodStart time.Time          `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time          `json:"current_period_end"`
+	CancelledAt        *time.Time         `json:"cancelled_at"`
+	CancelledBy        *uint              `json:"cancelled_by"`
+	CreatedBy          uint               `json:"created_by"`
+	Periods            []MembershipPeriod `json:"periods,omitempty"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// MembershipPeriod is one billing month of a membership: the lot granted for
+// it, what was carried over or expired at its end, and its payment
+type MembershipPeriod struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	MembershipID   uint       `json:"membership_id" gorm:"not null;index"`
+	PeriodStart    time.Time  `json:"period_start"`
+	PeriodEnd      time.Time  `json:"period_end"`
+	CreditID       *uint      `json:"credit_id"` // Lot granted for the period
+	CreditsGranted int        `json:"credits_granted"`
+	RolledOver     int        `json:"rolled_over"`     // Credits carried in from the previous period
+	ExpiredCredits int        `json:"expired_credits"` // Unused credits expired at the end of the period
+	Amount         Money      `json:"amount" gorm:"column:amount_cents;not null;default:0"`
+	Currency       string     `json:"currency" gorm:"size:3;not null;default:'MXN'"`
+	DueDate        time.Time  `json:"due_date"`
+	PaymentID      *uint      `json:"payment_id"`
+	PaidAt         *time.Time `json:"paid_at"`
+	FlaggedAt      *time.Time `json:"flagged_at"` // Set when the period went unpaid past its due date
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
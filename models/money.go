@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the ISO 4217 code of every amount the API handles
+const DefaultCurrency = "MXN"
+
+// ErrInvalidMoney is returned for amounts that are not a decimal number with
+// at most two decimals
+var ErrInvalidMoney = errors.New("Monto inválido, use un número decimal con máximo dos decimales")
+
+// Money is an amount in minor units (cents). It is stored as an integer and
+// read and written in JSON as a decimal string such as "99.99".
+type Money int64
+
+// ParseMoney reads a decimal amount like "99.99", "100" or "-5.5" without
+// going through floating point
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, hasFraction := strings.Cut(value, ".")
+	if !isDigits(whole) || len(fraction) > 2 || (hasFraction && !isDigits(fraction)) {
+		return 0, ErrInvalidMoney
+	}
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/100-1 {
+		return 0, ErrInvalidMoney
+	}
+	cents, _ := strconv.ParseInt(fraction, 10, 64)
+
+	amount := Money(units*100 + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the amount with two decimals
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+// UnmarshalJSON accepts a decimal string and, for older clients, a plain JSON
+// number, which is read from its text rather than as a float
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	amount, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Times is the amount multiplied by a whole quantity
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Per divides the amount evenly over quantity, rounding to the nearest cent.
+// It is used for unit costs; totals are never rebuilt from it.
+func (m Money) Per(quantity int) Money {
+	if quantity <= 0 {
+		return 0
+	}
+	q := int64(quantity)
+	cents := int64(m)
+	if cents < 0 {
+		return -Money((-cents + q/2) / q)
+	}
+	return Money((cents + q/2) / q)
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Money
+		err   bool
+	}{
+		{"99.99", 9999, false},
+		{"100", 10000, false},
+		{"-5.5", -550, false},
+		{" 0.05 ", 5, false},
+		{"0", 0, false},
+		{"1.", 0, true},
+		{"1.234", 0, true},
+		{".50", 0, true},
+		{"", 0, true},
+		{"-", 0, true},
+		{"1,50", 0, true},
+		{"abc", 0, true},
+		{"1e3", 0, true},
+		{"+1", 0, true},
+		{"99999999999999999999", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseMoney(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseMoney(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyPer(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Money
+		quantity int
+		want     Money
+	}{
+		{"even", 1000, 10, 100},
+		{"rounds down", 1000, 3, 333},
+		{"rounds half up", 1000, 8, 125},
+		{"rounds up", 200, 3, 67},
+		{"negative", -1000, 3, -333},
+		{"negative rounds away from zero", -200, 3, -67},
+		{"zero quantity", 1000, 0, 0},
+		{"negative quantity", 1000, -2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.Per(tt.quantity); got != tt.want {
+				t.Fatalf("%d.Per(%d) = %d, want %d", tt.amount, tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+	}{
+		{9999, `"99.99"`},
+		{10000, `"100.00"`},
+		{5, `"0.05"`},
+		{0, `"0.00"`},
+		{-550, `"-5.50"`},
+		{-5, `"-0.05"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			data, err := json.Marshal(tt.amount)
+			if err != nil {
+				t.Fatalf("json.Marshal(%d): %v", tt.amount, err)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("json.Marshal(%d) = %s, want %s", tt.amount, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want Money
+		err  bool
+	}{
+		{`"99.99"`, 9999, false},
+		{`"-5.5"`, -550, false},
+		{`99.99`, 9999, false},
+		{`100`, 10000, false},
+		{`0.1`, 10, false},
+		{`"1.999"`, 0, true},
+		{`1.999`, 0, true},
+		{`"abc"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("json.Unmarshal(%s) = %d, want error", tt.data, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("json.Unmarshal(%s): %v", tt.data, err)
+			}
+			if got != tt.want {
+				t.Fatalf("json.Unmarshal(%s) = %d, want %d", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+// null leaves the amount as it was, like for any other JSON type
+func TestMoneyUnmarshalJSONNull(t *testing.T) {
+	got := Money(1234)
+	if err := json.Unmarshal([]byte(`null`), &got); err != nil {
+		t.Fatalf("json.Unmarshal(null): %v", err)
+	}
+	if got != 1234 {
+		t.Fatalf("json.Unmarshal(null) changed the amount to %d", got)
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// NoShowPolicy controls how confirmed reservations without a check-in are
+// handled. There is a single policy row; while it is disabled past reservations
+// are simply completed.
+type NoShowPolicy struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Enabled        bool      `json:"enabled"`
+	GraceMinutes   int       `json:"grace_minutes"`   // Wait after the end before flagging
+	PenaltyCredits int       `json:"penalty_credits"` // 0 flags without penalizing
+	UpdatedBy      *uint     `json:"updated_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+type AppealStatus string
+
+const (
+	AppealPending  AppealStatus = "pending"
+	AppealApproved AppealStatus = "approved"
+	AppealDenied   AppealStatus = "denied"
+)
+
+// PenaltyAppeal is a professional's request to have a penalty forgiven
+type PenaltyAppeal struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	PenaltyID  uint         `json:"penalty_id" gorm:"not null;index"`
+	Penalty    Penalty      `json:"penalty,omitempty"`
+	UserID     uint         `json:"user_id" gorm:"not null;index"`
+	User       User         `json:"user,omitempty"`
+	Message    string       `json:"message" gorm:"not null"`
+	Status     AppealStatus `json:"status" gorm:"default:'pending'"`
+	ReviewedBy *uint        `json:"reviewed_by"`
+	ReviewNote string       `json:"review_note"`
+	ReviewedAt *time.Time   `json:"reviewed_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// PenaltyPolicy decides when unpaid penalties block new reservations. There is
+// a single policy row.
+type PenaltyPolicy struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	BlockEnabled   bool      `json:"block_enabled"`
+	BlockThreshold int       `json:"block_threshold"` // Unpaid credits allowed before blocking
+	UpdatedBy      *uint     `json:"updated_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
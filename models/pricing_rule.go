@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SpacePricingRule adjusts the price of a space during a weekly time window,
+// optionally limited to a date range. Either Multiplier or FixedCredits is set:
+// the multiplier scales the space's CostCredits, the fixed cost replaces it.
+type SpacePricingRule struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	SpaceID      uint           `json:"space_id" gorm:"not null;index"`
+	Space        Space          `json:"space,omitempty"`
+	Name         string         `json:"name" gorm:"not null"`        // e.g. "Hora pico entre semana"
+	DayOfWeek    int            `json:"day_of_week" gorm:"not null"` // 0=Sunday, 1=Monday, etc.
+	StartTime    string         `json:"start_time" gorm:"not null"`  // Format: "18:00"
+	EndTime      string         `json:"end_time" gorm:"not null"`    // Format: "21:00"
+	StartDate    *time.Time     `json:"start_date"`                  // Optional date range
+	EndDate      *time.Time     `json:"end_date"`
+	Multiplier   *float64       `json:"multiplier"`
+	FixedCredits *int           `json:"fixed_credits"`
+	Priority     int            `json:"priority" gorm:"default:0"` // Higher wins when rules overlap
+	IsActive     bool           `json:"is_active"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PromoCodeKind string
+
+const (
+	PromoCodeCredits PromoCodeKind = "credits" // Redeemed on its own for a fixed amount of credits
+	PromoCodeBonus   PromoCodeKind = "bonus"   // Applied to a payment for a percentage of extra credits
+)
+
+// PromoCode is a promotion or gift voucher redeemable for credits. Gift
+// vouchers are single-use credit codes bought by a professional for someone
+// else.
+type PromoCode struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Code            string         `json:"code" gorm:"uniqueIndex;not null"`
+	Description     string         `json:"description"`
+	Kind            PromoCodeKind  `json:"kind" gorm:"not null"`
+	Credits         int            `json:"credits"`         // Credits granted by a credits code
+	BonusPercent    float64        `json:"bonus_percent"`   // Extra credits over the paid ones for a bonus code
+	ValidityDays    int            `json:"validity_days"`   // Validity of the lot granted by a credits code
+	MaxRedemptions  int            `json:"max_redemptions"` // 0 means unlimited, 1 is a single-use code
+	PerUserLimit    int            `json:"per_user_limit"`  // 0 means unlimited
+	RedemptionCount int            `json:"redemption_count" gorm:"default:0"`
+	ExpiresAt       *time.Time     `json:"expires_at"`
+	IsActive        bool           `json:"is_active"`
+	PurchasedBy     *uint          `json:"purchased_by"` // Buyer of a gift voucher
+	PaymentID       *uint          `json:"payment_id"`   // Payment that bought a gift voucher
+	CreatedBy       uint           `json:"created_by"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// PromoRedemption is one use of a promo code and the lot it granted
+type PromoRedemption struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	PromoCodeID uint       `json:"promo_code_id" gorm:"not null;index"`
+	PromoCode   PromoCode  `json:"promo_code,omitempty"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	User        User       `json:"user,omitempty"`
+	Credits     int        `json:"credits"`
+	CreditID    *uint      `json:"credit_id"`
+	PaymentID   *uint      `json:"payment_id"`  // Payment a bonus code was applied to
+	ReversedAt  *time.Time `json:"reversed_at"` // Set when that payment was fully refunded
+	CreatedAt   time.Time  `json:"created_at"`
+}
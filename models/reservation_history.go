@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// ReservationHistory records one status transition of a reservation. The first
+// entry of every reservation has an empty FromStatus.
+type ReservationHistory struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	ReservationID uint              `json:"reservation_id" gorm:"not null;index"`
+	FromStatus    ReservationStatus `json:"from_status"`
+	ToStatus      ReservationStatus `json:"to_status" gorm:"not null"`
+	ActorID       *uint             `json:"actor_id"` // nil for transitions made by the system
+	Actor         *User             `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Reason        string            `json:"reason"`
+	CreditDelta   int               `json:"credit_delta"` // Credits charged (+) or returned (-) to the user
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+func (ReservationHistory) TableName() string {
+	return "reservation_history"
+}
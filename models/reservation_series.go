@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecurrenceFrequency string
+
+const (
+	FrequencyWeekly   RecurrenceFrequency = "weekly"
+	FrequencyBiweekly RecurrenceFrequency = "biweekly"
+	FrequencyMonthly  RecurrenceFrequency = "monthly"
+)
+
+type SeriesStatus string
+
+const (
+	SeriesActive    SeriesStatus = "active"
+	SeriesCancelled SeriesStatus = "cancelled"
+)
+
+// ReservationSeries groups the reservations generated from a recurrence rule.
+// The first occurrence's StartTime/EndTime define the time of day and duration
+// of every generated occurrence.
+type ReservationSeries struct {
+	ID          uint                `json:"id" gorm:"primaryKey"`
+	UserID      uint                `json:"user_id" gorm:"not null;index"`
+	User        User                `json:"user,omitempty"`
+	SpaceID     uint                `json:"space_id" gorm:"not null"`
+	Space       Space               `json:"space,omitempty"`
+	Frequency   RecurrenceFrequency `json:"frequency" gorm:"not null"`
+	StartTime   time.Time           `json:"start_time" gorm:"not null"` // First occurrence
+	EndTime     time.Time           `json:"end_time" gorm:"not null"`
+	UntilDate   *time.Time          `json:"until_date"`  // Last date (inclusive) an occurrence may start
+	Occurrences *int                `json:"occurrences"` // Maximum number of occurrences
+	Status      SeriesStatus        `json:"status" gorm:"default:'active'"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt      `json:"-" gorm:"index"`
+
+	// Relations
+	Reservations []Reservation `json:"reservations,omitempty" gorm:"foreignKey:SeriesID"`
+}
+
+func (ReservationSeries) TableName() string {
+	return "reservation_series"
+}
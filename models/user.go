@@ -29,11 +29,30 @@ type User struct {
 	Description  string `json:"description"`
 	ProfileImage string `json:"profile_image"`
 
+	// Tax data for invoices
+	TaxData TaxData `json:"tax_data" gorm:"embedded;embeddedPrefix:tax_"`
+
 	// Relations
 	Credits      []Credit      `json:"credits,omitempty"`
 	Reservations []Reservation `json:"reservations,omitempty"`
 }
 
+type PricingMode string
+
+const (
+	PricingFlat   PricingMode = "flat"   // CostCredits per reservation
+	PricingHourly PricingMode = "hourly" // CostCredits per hour
+	PricingBlock  PricingMode = "block"  // CostCredits per block of BlockMinutes
+)
+
+type RoundingMode string
+
+const (
+	RoundUp      RoundingMode = "up"
+	RoundDown    RoundingMode = "down"
+	RoundNearest RoundingMode = "nearest"
+)
+
 type Space struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	Name        string         `json:"name" gorm:"not null"`
@@ -41,13 +60,21 @@ type Space struct {
 	Capacity    int            `json:"capacity" gorm:"default:1"`
 	CostCredits int            `json:"cost_credits" gorm:"default:6"` // Usually 6 credits (60-100 pesos)
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
+
+	// Pricing: CostCredits is charged per reservation, hour or block depending on the mode
+	PricingMode        PricingMode  `json:"pricing_mode" gorm:"default:'flat'"`
+	BlockMinutes       int          `json:"block_minutes" gorm:"default:60"`       // Block size for block pricing
+	MinBillableMinutes int          `json:"min_billable_minutes" gorm:"default:0"` // Shorter bookings are billed as this
+	RoundingMode       RoundingMode `json:"rounding_mode" gorm:"default:'up'"`     // How partial hours/blocks are billed
+
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
-	Reservations []Reservation `json:"reservations,omitempty"`
-	Schedules    []Schedule    `json:"schedules,omitempty"`
+	Reservations []Reservation      `json:"reservations,omitempty"`
+	Schedules    []Schedule         `json:"schedules,omitempty"`
+	PricingRules []SpacePricingRule `json:"pricing_rules,omitempty"`
 }
 
 type Schedule struct {
@@ -70,6 +97,8 @@ const (
 	StatusConfirmed ReservationStatus = "confirmed"
 	StatusCancelled ReservationStatus = "cancelled"
 	StatusCompleted ReservationStatus = "completed"
+	StatusRejected  ReservationStatus = "rejected"
+	StatusNoShow    ReservationStatus = "no_show"
 )
 
 type Reservation struct {
@@ -87,9 +116,19 @@ type Reservation struct {
 	RequiresApproval bool             `json:"requires_approval" gorm:"default:false"`
 	ApprovedBy      *uint             `json:"approved_by"`
 	ApprovedAt      *time.Time        `json:"approved_at"`
+	RejectedBy      *uint             `json:"rejected_by"`
+	RejectedAt      *time.Time        `json:"rejected_at"`
+	RejectionReason string            `json:"rejection_reason"`
 	CreatedBy       *uint             `json:"created_by"`                     // Admin who created the reservation
 	CreatedByUser   *User             `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`      // Relation to the admin who created it
 	Notes           string            `json:"notes"`                          // Additional notes from admin
+	SeriesID        *uint             `json:"series_id"`                      // Recurring series this occurrence belongs to
+	Series          *ReservationSeries `json:"series,omitempty"`
+	CheckedInAt     *time.Time        `json:"checked_in_at"`                  // When the professional showed up
+	CheckedInBy     *uint             `json:"checked_in_by"`                  // Admin or professional who checked in
+	CheckInCode     string            `json:"-"`                              // Time-limited code for self check-in
+	CheckInCodeExpiresAt *time.Time   `json:"-"`
+	CheckInCodeAttempts int           `json:"-" gorm:"not null;default:0"` // Wrong codes entered since the code was generated
 	CreatedAt       time.Time         `json:"created_at"`
 	UpdatedAt       time.Time         `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt    `json:"-" gorm:"index"`
@@ -126,6 +165,14 @@ type PenaltyStatus string
 const (
 	PenaltyPending PenaltyStatus = "pending"
 	PenaltyPaid    PenaltyStatus = "paid"
+	PenaltyWaived  PenaltyStatus = "waived" // Forgiven after an approved appeal
+)
+
+type PenaltySettlement string
+
+const (
+	SettledWithCredits PenaltySettlement = "credits"
+	SettledWithPayment PenaltySettlement = "payment"
 )
 
 type Penalty struct {
@@ -137,23 +184,56 @@ type Penalty struct {
 	Amount        int           `json:"amount" gorm:"not null"` // Credits deducted (2-4 credits)
 	Status        PenaltyStatus `json:"status" gorm:"default:'pending'"`
 	Reason        string        `json:"reason"`
+	SettledVia    PenaltySettlement `json:"settled_via"`
+	SettledAt     *time.Time    `json:"settled_at"`
+	SettledBy     *uint         `json:"settled_by"`
+	PaymentID     *uint         `json:"payment_id"` // Set when settled by payment
 	CreatedAt     time.Time     `json:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+type PaymentType string
+
+const (
+	PaymentTypePayment PaymentType = "payment"
+	PaymentTypeRefund  PaymentType = "refund" // Full or partial reversal of a payment, with negative amounts
+	PaymentTypeVoid    PaymentType = "void"   // Reversal of a payment registered by mistake
+)
+
+type PaymentStatus string
+
+const (
+	PaymentCompleted         PaymentStatus = "completed"
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentRefunded          PaymentStatus = "refunded"
+	PaymentVoided            PaymentStatus = "voided"
+)
+
 type Payment struct {
 	ID              uint           `json:"id" gorm:"primaryKey"`
+	Type            PaymentType    `json:"type" gorm:"default:'payment'"`
+	Status          PaymentStatus  `json:"status" gorm:"default:'completed'"`
 	UserID          uint           `json:"user_id" gorm:"not null"`
 	User            User           `json:"user,omitempty"`
-	AdminID         uint           `json:"admin_id" gorm:"not null"`
-	Admin           User           `json:"admin,omitempty" gorm:"foreignKey:AdminID"`
-	Amount          float64        `json:"amount" gorm:"not null"` // Money paid
+	AdminID         *uint          `json:"admin_id"` // Unset for payments made online through the gateway
+	Admin           *User          `json:"admin,omitempty" gorm:"foreignKey:AdminID"`
+	Amount          Money          `json:"amount" gorm:"column:amount_cents;not null;default:0"` // Money paid, in cents
+	Currency        string         `json:"currency" gorm:"size:3;not null;default:'MXN'"`
 	CreditsGranted  int            `json:"credits_granted" gorm:"not null"`
-	CreditCost      float64        `json:"credit_cost" gorm:"not null"` // Cost per credit
+	CreditCost      Money          `json:"credit_cost" gorm:"column:credit_cost_cents;not null;default:0"` // Cost per credit, rounded to the cent
 	PaymentMethod   string         `json:"payment_method"` // "transfer", "cash", "card"
 	Reference       string         `json:"reference"` // Transaction reference
 	Notes           string         `json:"notes"`
+	PenaltyID       *uint          `json:"penalty_id"` // Set when the payment settles a penalty
+	PackageID       *uint          `json:"package_id"` // Set when the payment buys a credit package
+	MembershipID    *uint          `json:"membership_id"` // Set when the payment pays a membership period
+	RefundOfID      *uint          `json:"refund_of_id" gorm:"index"` // Payment reversed by a refund or void entry
+	RefundedAmount  Money          `json:"refunded_amount" gorm:"column:refunded_cents;not null;default:0"`
+	Reason          string         `json:"reason"` // Why a refund or void was issued
+	CashShiftID     *uint          `json:"cash_shift_id" gorm:"index"` // Front desk shift the payment was registered in
+	Package         *CreditPackage `json:"package,omitempty"`
+	Invoice         *Invoice       `json:"invoice,omitempty"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
@@ -169,8 +249,8 @@ const (
 
 type Cancellation struct {
 	ID               uint               `json:"id" gorm:"primaryKey"`
-	UserID           uint               `json:"user_id" gorm:"not null"`
-	User             User               `json:"user,omitempty"`
+	UserID           *uint              `json:"user_id"` // Nil for reservations of external clients
+	User             *User              `json:"user,omitempty"`
 	ReservationID    uint               `json:"reservation_id" gorm:"not null"`
 	Reservation      Reservation        `json:"reservation,omitempty"`
 	CancelledAt      time.Time          `json:"cancelled_at" gorm:"not null"`
@@ -181,6 +261,9 @@ type Cancellation struct {
 	Reason           string             `json:"reason"`
 	Notes            string             `json:"notes"`
 	CancelledBy      *uint              `json:"cancelled_by"`
+	PolicyID         *uint              `json:"policy_id"`      // Cancellation policy applied (nil = built-in default)
+	PolicyTierID     *uint              `json:"policy_tier_id"` // Tier applied within the policy
+	RefundPercent    int                `json:"refund_percent" gorm:"default:0"`
 	CreatedAt        time.Time          `json:"created_at"`
 	UpdatedAt        time.Time          `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt     `json:"-" gorm:"index"`
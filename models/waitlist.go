@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WaitlistStatus string
+
+const (
+	WaitlistWaiting   WaitlistStatus = "waiting"
+	WaitlistOffered   WaitlistStatus = "offered"
+	WaitlistBooked    WaitlistStatus = "booked"
+	WaitlistExpired   WaitlistStatus = "expired"
+	WaitlistCancelled WaitlistStatus = "cancelled"
+)
+
+// WaitlistEntry is a professional waiting for a time window of a fully booked space
+type WaitlistEntry struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	UserID         uint           `json:"user_id" gorm:"not null;index"`
+	User           User           `json:"user,omitempty"`
+	SpaceID        uint           `json:"space_id" gorm:"not null;index"`
+	Space          Space          `json:"space,omitempty"`
+	StartTime      time.Time      `json:"start_time" gorm:"not null"`
+	EndTime        time.Time      `json:"end_time" gorm:"not null"`
+	AutoBook       bool           `json:"auto_book" gorm:"default:false"` // Book automatically when the slot frees up
+	Status         WaitlistStatus `json:"status" gorm:"default:'waiting';index"`
+	OfferedAt      *time.Time     `json:"offered_at"`
+	OfferExpiresAt *time.Time     `json:"offer_expires_at"`
+	ReservationID  *uint          `json:"reservation_id"` // Reservation created when the entry was booked
+	Reservation    *Reservation   `json:"reservation,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -5,20 +5,21 @@ import (
 
 	"github.com/IkingariSolorzano/omma-be/controllers"
 	"github.com/IkingariSolorzano/omma-be/middleware"
+	"github.com/IkingariSolorzano/omma-be/services"
 	"github.com/IkingariSolorzano/omma-be/websocket"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(hub *websocket.Hub) *gin.Engine {
+func SetupRoutes(hub *websocket.Hub, scheduler *services.SchedulerService) *gin.Engine {
 	r := gin.Default()
 
 	// Configure CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:4200", "http://localhost:3000", "http://127.0.0.1:4200", "https://ikingarisolorzano.com", "https://www.ikingarisolorzano.com", "http://ikingarisolorzano.com.mx", "http://www.ikingarisolorzano.com.mx"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -30,6 +31,24 @@ func SetupRoutes(hub *websocket.Hub) *gin.Engine {
 	dashboardController := controllers.NewDashboardController()
 	calendarController := controllers.NewCalendarController()
 	paymentController := controllers.NewPaymentController()
+	seriesController := controllers.NewReservationSeriesController()
+	waitlistController := controllers.NewWaitlistController()
+	policyController := controllers.NewCancellationPolicyController()
+	pricingController := controllers.NewPricingController()
+	jobController := controllers.NewJobController(scheduler)
+	checkInController := controllers.NewCheckInController()
+	penaltyController := controllers.NewPenaltyController()
+	reconciliationController := controllers.NewReconciliationController()
+	packageController := controllers.NewCreditPackageController()
+	membershipController := controllers.NewMembershipController()
+	promoCodeController := controllers.NewPromoCodeController()
+	checkoutController := controllers.NewCheckoutController()
+	invoiceController := controllers.NewInvoiceController()
+	cashShiftController := controllers.NewCashShiftController()
+
+	// Mutating money, credit and reservation endpoints replay their first
+	// response when retried with the same Idempotency-Key
+	idempotent := middleware.Idempotency(services.NewIdempotencyService())
 
 	// Public routes
 	public := r.Group("/api/v1")
@@ -38,9 +57,12 @@ func SetupRoutes(hub *websocket.Hub) *gin.Engine {
 		public.POST("/auth/register", authController.Register)
 		public.GET("/professionals", userController.GetProfessionalDirectory)
 		public.GET("/closed-dates", controllers.GetPublicClosedDates)
+		if checkoutController.Enabled() {
+			public.POST("/webhooks/payments", checkoutController.HandleWebhook)
+		}
 		
 		// WebSocket route (public but will validate token internally)
-		public.GET("/ws", websocket.HandleWebSocket(hub))
+		public.GET("/ws", middleware.WebSocketAuth(), websocket.HandleWebSocket(hub))
 	}
 
 	// Protected routes
@@ -52,12 +74,42 @@ func SetupRoutes(hub *websocket.Hub) *gin.Engine {
 		protected.PUT("/profile", userController.UpdateProfile)
 		protected.POST("/profile/picture", userController.UploadProfilePicture)
 		protected.PUT("/profile/password", userController.ChangePassword)
+		protected.PUT("/profile/tax-data", invoiceController.UpdateMyTaxData)
 		protected.GET("/credits", userController.GetCredits)
+		protected.GET("/credits/ledger", userController.GetCreditLedger)
+		protected.GET("/credit-packages", packageController.GetAvailablePackages)
+		protected.GET("/memberships", membershipController.GetMyMemberships)
+		protected.POST("/promo-codes/redeem", idempotent, promoCodeController.RedeemCode)
+		if checkoutController.Enabled() {
+			protected.GET("/checkouts", checkoutController.GetMyCheckouts)
+			protected.POST("/checkouts", idempotent, checkoutController.StartCheckout)
+			protected.GET("/checkouts/:id", checkoutController.GetCheckout)
+		}
+		protected.GET("/payments", paymentController.GetMyPayments)
+		protected.POST("/payments/:id/invoice", idempotent, invoiceController.CreateInvoice)
+		protected.GET("/invoices", invoiceController.GetInvoices)
+		protected.GET("/invoices/:id/xml", invoiceController.DownloadXML)
+		protected.GET("/invoices/:id/pdf", invoiceController.DownloadPDF)
 		protected.GET("/spaces", userController.GetSpaces)
 		protected.GET("/schedules", adminController.GetSchedules)
 		protected.GET("/reservations", userController.GetReservations)
-		protected.POST("/reservations", userController.CreateReservation)
-		protected.DELETE("/reservations/:id", userController.CancelReservation)
+		protected.POST("/reservations", idempotent, userController.CreateReservation)
+		protected.POST("/reservations/quote", userController.QuoteReservation)
+		protected.DELETE("/reservations/:id", idempotent, userController.CancelReservation)
+		protected.GET("/reservations/:id/cancellation-quote", userController.GetCancellationQuote)
+		protected.POST("/reservations/:id/check-in", checkInController.CheckIn)
+		protected.GET("/reservation-series", seriesController.GetSeries)
+		protected.POST("/reservation-series", idempotent, seriesController.CreateSeries)
+		protected.GET("/reservation-series/:id", seriesController.GetSeriesDetails)
+		protected.PUT("/reservation-series/:id", idempotent, seriesController.UpdateSeries)
+		protected.PUT("/reservation-series/:id/cancel", idempotent, seriesController.CancelSeries)
+		protected.GET("/waitlist", waitlistController.GetWaitlist)
+		protected.POST("/waitlist", waitlistController.JoinWaitlist)
+		protected.DELETE("/waitlist/:id", waitlistController.LeaveWaitlist)
+		protected.PUT("/waitlist/:id/accept", idempotent, waitlistController.AcceptOffer)
+		protected.GET("/penalties", penaltyController.GetMyPenalties)
+		protected.POST("/penalties/:id/settle", idempotent, penaltyController.SettleMyPenalty)
+		protected.POST("/penalties/:id/appeal", penaltyController.AppealPenalty)
 		protected.GET("/business-hours", adminController.GetBusinessHours)
 
 		// Calendar routes
@@ -78,31 +130,77 @@ func SetupRoutes(hub *websocket.Hub) *gin.Engine {
 		admin.POST("/users", adminController.CreateUser)
 		admin.GET("/users", adminController.GetUsers)
 		admin.GET("/users/:id/credit-lots", adminController.GetUserCreditLots)
+		admin.GET("/users/:id/credit-ledger", adminController.GetUserCreditLedger)
 		admin.PUT("/users/:id", adminController.UpdateUser)
 		admin.PUT("/users/:id/password", adminController.ChangeUserPassword)
+		admin.PUT("/users/:id/tax-data", invoiceController.UpdateUserTaxData)
 		admin.PATCH("/users/:id/toggle-status", adminController.ToggleUserStatus)
 
 		// Credit management
-		admin.POST("/credits", adminController.AddCredits)
-		admin.POST("/credits/extend", adminController.ExtendCreditExpiry)
-		admin.POST("/credits/reactivate", adminController.ReactivateExpiredCredits)
-		admin.POST("/credits/transfer", adminController.TransferCredits)
-		admin.POST("/credits/deduct", adminController.DeductCredits)
+		admin.POST("/credits", idempotent, adminController.AddCredits)
+		admin.POST("/credits/extend", idempotent, adminController.ExtendCreditExpiry)
+		admin.POST("/credits/reactivate", idempotent, adminController.ReactivateExpiredCredits)
+		admin.POST("/credits/transfer", idempotent, adminController.TransferCredits)
+		admin.POST("/credits/deduct", idempotent, adminController.DeductCredits)
+		admin.GET("/credits/reconciliation", reconciliationController.GetReconciliation)
+		admin.POST("/credits/reconciliation/corrections", idempotent, reconciliationController.PostCorrection)
 		// Credit lot (per-lot) management
-		admin.POST("/credit-lots/extend", adminController.ExtendCreditLot)
-		admin.POST("/credit-lots/reactivate", adminController.ReactivateCreditLot)
-		admin.POST("/credit-lots/transfer", adminController.TransferFromCreditLot)
-		admin.POST("/credit-lots/deduct", adminController.DeductFromCreditLot)
+		admin.POST("/credit-lots/extend", idempotent, adminController.ExtendCreditLot)
+		admin.POST("/credit-lots/reactivate", idempotent, adminController.ReactivateCreditLot)
+		admin.POST("/credit-lots/transfer", idempotent, adminController.TransferFromCreditLot)
+		admin.POST("/credit-lots/deduct", idempotent, adminController.DeductFromCreditLot)
 
 		// Payment management
-		admin.POST("/payments", paymentController.RegisterPayment)
+		admin.POST("/payments", idempotent, paymentController.RegisterPayment)
 		admin.GET("/payments", paymentController.GetPaymentHistory)
+		admin.POST("/payments/:id/refund", idempotent, paymentController.RefundPayment)
+		admin.POST("/payments/:id/void", idempotent, paymentController.VoidPayment)
+		admin.POST("/payments/:id/invoice", idempotent, invoiceController.CreateInvoice)
+		admin.GET("/invoices", invoiceController.GetInvoices)
+		admin.GET("/invoices/:id/xml", invoiceController.DownloadXML)
+		admin.GET("/invoices/:id/pdf", invoiceController.DownloadPDF)
+		if checkoutController.UsesFakeGateway() && gin.IsDebugging() {
+			admin.POST("/checkouts/:id/simulate", checkoutController.SimulateCheckout)
+		}
+
+		// Cash register shifts (corte de caja)
+		admin.POST("/cash-shifts", idempotent, cashShiftController.OpenShift)
+		admin.GET("/cash-shifts", cashShiftController.GetShifts)
+		admin.GET("/cash-shifts/current", cashShiftController.GetCurrentShift)
+		admin.GET("/cash-shifts/:id", cashShiftController.GetShift)
+		admin.POST("/cash-shifts/:id/close", idempotent, cashShiftController.CloseShift)
+		admin.GET("/cash-shifts/:id/export", cashShiftController.ExportShift)
+
+		admin.GET("/credit-packages", packageController.GetPackages)
+		admin.POST("/credit-packages", packageController.CreatePackage)
+		admin.PUT("/credit-packages/:id", packageController.UpdatePackage)
+		admin.DELETE("/credit-packages/:id", packageController.DeletePackage)
+
+		// Memberships
+		admin.GET("/membership-plans", membershipController.GetPlans)
+		admin.POST("/membership-plans", membershipController.CreatePlan)
+		admin.PUT("/membership-plans/:id", membershipController.UpdatePlan)
+		admin.GET("/memberships", membershipController.GetMemberships)
+		admin.POST("/memberships", idempotent, membershipController.CreateMembership)
+		admin.GET("/memberships/:id", membershipController.GetMembership)
+		admin.PUT("/memberships/:id/cancel", idempotent, membershipController.CancelMembership)
+
+		// Promo codes and gift vouchers
+		admin.GET("/promo-codes", promoCodeController.GetCodes)
+		admin.POST("/promo-codes", promoCodeController.CreateCode)
+		admin.PUT("/promo-codes/:id", promoCodeController.UpdateCode)
+		admin.GET("/promo-codes/redemptions", promoCodeController.GetRedemptionReport)
+		admin.POST("/gift-vouchers", idempotent, promoCodeController.SellVoucher)
 
 		// Space management
 		admin.POST("/spaces", adminController.CreateSpace)
 		admin.GET("/spaces", adminController.GetSpaces)
 		admin.PUT("/spaces/:id", adminController.UpdateSpace)
 		admin.DELETE("/spaces/:id", adminController.DeleteSpace)
+		admin.GET("/spaces/:id/pricing-rules", pricingController.GetRules)
+		admin.POST("/spaces/:id/pricing-rules", pricingController.CreateRule)
+		admin.PUT("/pricing-rules/:id", pricingController.UpdateRule)
+		admin.DELETE("/pricing-rules/:id", pricingController.DeleteRule)
 		admin.POST("/schedules", adminController.CreateSchedule)
 		admin.GET("/schedules", adminController.GetSchedules)
 		admin.PUT("/schedules/:id", adminController.UpdateSchedule)
@@ -112,9 +210,37 @@ func SetupRoutes(hub *websocket.Hub) *gin.Engine {
 		admin.GET("/reservations/pending", adminController.GetPendingReservations)
 		admin.GET("/reservations", adminController.GetAllReservations)
 		admin.GET("/reservations/:id", adminController.GetReservationDetails)
-		admin.PUT("/reservations/:id", adminController.UpdateReservation)
-		admin.PUT("/reservations/:id/approve", adminController.ApproveReservation)
-		admin.PUT("/reservations/:id/cancel", adminController.CancelReservation)
+		admin.GET("/reservations/:id/history", adminController.GetReservationHistory)
+		admin.POST("/reservations/:id/check-in-code", checkInController.GenerateCheckInCode)
+		admin.PUT("/reservations/:id/check-in", checkInController.AdminCheckIn)
+		admin.GET("/no-show-policy", checkInController.GetNoShowPolicy)
+		admin.PUT("/no-show-policy", checkInController.UpdateNoShowPolicy)
+		admin.PUT("/reservations/:id", idempotent, adminController.UpdateReservation)
+		admin.PUT("/reservations/:id/approve", idempotent, adminController.ApproveReservation)
+		admin.PUT("/reservations/:id/reject", idempotent, adminController.RejectReservation)
+		admin.POST("/reservations/batch/approve", idempotent, adminController.BatchApproveReservations)
+		admin.POST("/reservations/batch/reject", idempotent, adminController.BatchRejectReservations)
+		admin.PUT("/reservations/:id/cancel", idempotent, adminController.CancelReservation)
+		admin.GET("/waitlist", waitlistController.GetActiveWaitlist)
+
+		// Cancellation policies
+		admin.GET("/cancellation-policies", policyController.GetPolicies)
+		admin.POST("/cancellation-policies", policyController.CreatePolicy)
+		admin.PUT("/cancellation-policies/:id", policyController.UpdatePolicy)
+		admin.DELETE("/cancellation-policies/:id", policyController.DeletePolicy)
+
+		// Penalties
+		admin.GET("/penalties", penaltyController.GetPenalties)
+		admin.POST("/penalties/:id/settle", idempotent, penaltyController.SettlePenalty)
+		admin.GET("/penalty-appeals", penaltyController.GetAppeals)
+		admin.PUT("/penalty-appeals/:id", penaltyController.ReviewAppeal)
+		admin.GET("/penalty-policy", penaltyController.GetPenaltyPolicy)
+		admin.PUT("/penalty-policy", penaltyController.UpdatePenaltyPolicy)
+
+		// Background jobs
+		admin.GET("/jobs", jobController.GetJobs)
+		admin.GET("/jobs/:name/runs", jobController.GetJobRuns)
+		admin.POST("/jobs/:name/run", jobController.RunJob)
 
 		// Business Hours management
 		admin.GET("/business-hours", adminController.GetBusinessHours)
@@ -128,7 +254,7 @@ func SetupRoutes(hub *websocket.Hub) *gin.Engine {
 		admin.DELETE("/closed-dates/:id", adminController.DeleteClosedDate)
 
 		// External Client Reservations
-		admin.POST("/reservations/external", adminController.CreateExternalReservation)
+		admin.POST("/reservations/external", idempotent, adminController.CreateExternalReservation)
 		
 		// External Client Management
 		admin.GET("/external-clients/search", adminController.SearchExternalClients)
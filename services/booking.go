@@ -0,0 +1,348 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+// ErrBookingRulesFailed is returned when a booking breaks rules that an admin
+// could override; the BookingEvaluation lists which ones
+var ErrBookingRulesFailed = errors.New("La reserva no cumple las reglas de reservación")
+
+// ErrPeriodOffered is returned while a freed period is offered to someone on
+// the waitlist
+var ErrPeriodOffered = errors.New("El periodo está apartado para alguien de la lista de espera")
+
+// BookingRule identifies one of the checks run by the booking engine
+type BookingRule string
+
+const (
+	RuleTimeRange     BookingRule = "time_range"
+	RuleClosedDate    BookingRule = "closed_date"
+	RuleBusinessHours BookingRule = "business_hours"
+	RuleSchedule      BookingRule = "schedule"
+	RuleConflict      BookingRule = "conflict"
+	RuleWaitlistOffer BookingRule = "waitlist_offer"
+	RulePricing       BookingRule = "pricing"
+	RuleCredits       BookingRule = "credits"
+	RulePenalties     BookingRule = "penalties"
+)
+
+// RuleResult is the outcome of a single booking rule. Overridable rules only
+// turn a user booking into a special reservation that needs approval, and an
+// admin may book over them; the others always block the booking.
+type RuleResult struct {
+	Rule        BookingRule `json:"rule"`
+	Passed      bool        `json:"passed"`
+	Overridable bool        `json:"overridable"`
+	Message     string      `json:"message,omitempty"`
+}
+
+// BookingRequest describes a reservation to create or the new state of one
+// being changed
+type BookingRequest struct {
+	SpaceID   uint
+	StartTime time.Time
+	EndTime   time.Time
+	// UserID is the professional charged in credits; nil for external clients
+	UserID *uint
+	// ReservationID is the reservation being changed, so it is not reported
+	// as conflicting with itself and its charge counts toward the new price
+	ReservationID uint
+	// ChargedCredits is what the reservation being changed already paid or holds
+	ChargedCredits int
+}
+
+// BookingEvaluation is the full report of the booking engine for a request
+type BookingEvaluation struct {
+	Space            *models.Space `json:"-"`
+	Rules            []RuleResult  `json:"rules"`
+	Quote            *PriceQuote   `json:"quote,omitempty"`
+	RequiresApproval bool          `json:"requires_approval"`
+}
+
+// Failed returns the rules that did not pass
+func (e *BookingEvaluation) Failed() []RuleResult {
+	var failed []RuleResult
+	for _, rule := range e.Rules {
+		if !rule.Passed {
+			failed = append(failed, rule)
+		}
+	}
+	return failed
+}
+
+// Blocked returns the first failed rule that cannot be overridden, if any
+func (e *BookingEvaluation) Blocked() *RuleResult {
+	for i := range e.Rules {
+		if !e.Rules[i].Passed && !e.Rules[i].Overridable {
+			return &e.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Err maps a blocking failure to the error returned to the caller
+func (e *BookingEvaluation) Err() error {
+	blocked := e.Blocked()
+	if blocked == nil {
+		return nil
+	}
+	if blocked.Rule == RuleConflict {
+		return ErrPeriodReserved
+	}
+	return errors.New(blocked.Message)
+}
+
+// BookingService is the booking engine: every path that creates or moves a
+// reservation evaluates it here so hours, closed dates, schedules, conflicts,
+// pricing and credits are checked the same way
+type BookingService struct {
+	creditService  *CreditService
+	pricingService *PricingService
+	penaltyService *PenaltyService
+}
+
+func NewBookingService() *BookingService {
+	return &BookingService{
+		creditService:  NewCreditService(),
+		pricingService: NewPricingService(),
+		penaltyService: NewPenaltyService(),
+	}
+}
+
+// Evaluate runs every booking rule for the request. The returned error is only
+// set when the space does not exist; rule failures are reported in the result.
+func (s *BookingService) Evaluate(req BookingRequest) (*BookingEvaluation, error) {
+	var space models.Space
+	if err := config.DB.First(&space, req.SpaceID).Error; err != nil {
+		return nil, errors.New("Espacio no encontrado")
+	}
+
+	eval := &BookingEvaluation{Space: &space}
+
+	if !req.EndTime.After(req.StartTime) {
+		eval.add(RuleTimeRange, false, false, "La hora de inicio debe ser anterior a la hora de fin")
+		return eval, nil
+	}
+	eval.add(RuleTimeRange, true, false, "")
+
+	if s.isClosedDate(req.StartTime) {
+		eval.add(RuleClosedDate, false, true, "La fecha está marcada como cerrada")
+	} else {
+		eval.add(RuleClosedDate, true, true, "")
+	}
+
+	if !s.isWithinBusinessHours(req.StartTime, req.EndTime) {
+		eval.add(RuleBusinessHours, false, true, "Fuera del horario de atención")
+	} else {
+		eval.add(RuleBusinessHours, true, true, "")
+	}
+
+	if !s.isWithinSchedule(req.SpaceID, req.StartTime, req.EndTime) {
+		eval.add(RuleSchedule, false, true, "Fuera del horario disponible del espacio")
+	} else {
+		eval.add(RuleSchedule, true, true, "")
+	}
+
+	if err := s.checkConflicts(req.SpaceID, req.StartTime, req.EndTime, req.ReservationID); err != nil {
+		eval.add(RuleConflict, false, false, err.Error())
+	} else {
+		eval.add(RuleConflict, true, false, "")
+	}
+
+	if err := s.checkOffers(req.SpaceID, req.StartTime, req.EndTime, req.UserID); err != nil {
+		eval.add(RuleWaitlistOffer, false, false, err.Error())
+	} else {
+		eval.add(RuleWaitlistOffer, true, false, "")
+	}
+
+	// Bookings outside the regular schedule are special reservations
+	for _, rule := range eval.Rules {
+		if !rule.Passed && rule.Overridable {
+			eval.RequiresApproval = true
+			break
+		}
+	}
+
+	quote, err := s.pricingService.Quote(&space, req.StartTime, req.EndTime, eval.RequiresApproval)
+	if err != nil {
+		eval.add(RulePricing, false, false, err.Error())
+		return eval, nil
+	}
+	eval.Quote = quote
+	eval.add(RulePricing, true, false, "")
+
+	if req.UserID == nil {
+		eval.add(RuleCredits, true, false, "No aplica para clientes externos")
+		return eval, nil
+	}
+
+	// Unpaid penalties only block new reservations, not changes to existing ones
+	if req.ReservationID == 0 {
+		if err := s.penaltyService.CheckBlocked(*req.UserID); err != nil {
+			eval.add(RulePenalties, false, false, err.Error())
+		} else {
+			eval.add(RulePenalties, true, false, "")
+		}
+	}
+
+	needed := quote.TotalCredits - req.ChargedCredits
+	if needed > 0 {
+		available, err := s.creditService.GetAvailableCredits(*req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if available < needed {
+			eval.add(RuleCredits, false, false, "Creditos insuficientes")
+			return eval, nil
+		}
+	}
+	eval.add(RuleCredits, true, false, "")
+
+	return eval, nil
+}
+
+// Check evaluates the request and fails unless every rule that cannot be
+// overridden passed. When override is false, overridable failures are also
+// rejected with ErrBookingRulesFailed; the evaluation is returned either way.
+func (s *BookingService) Check(req BookingRequest, override bool) (*BookingEvaluation, error) {
+	eval, err := s.Evaluate(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := eval.Err(); err != nil {
+		return eval, err
+	}
+	if !override && len(eval.Failed()) > 0 {
+		return eval, ErrBookingRulesFailed
+	}
+	return eval, nil
+}
+
+func (e *BookingEvaluation) add(rule BookingRule, passed, overridable bool, message string) {
+	e.Rules = append(e.Rules, RuleResult{
+		Rule:        rule,
+		Passed:      passed,
+		Overridable: overridable,
+		Message:     message,
+	})
+}
+
+// checkConflicts reports an active reservation of the space overlapping the
+// period. The overlap constraint is the final guard against concurrent bookings.
+func (s *BookingService) checkConflicts(spaceID uint, startTime, endTime time.Time, excludeID uint) error {
+	var count int64
+	query := config.DB.Model(&models.Reservation{}).
+		Where("space_id = ? AND status IN (?, ?) AND start_time < ? AND end_time > ?",
+			spaceID, models.StatusPending, models.StatusConfirmed, endTime, startTime)
+
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+
+	query.Count(&count)
+
+	if count > 0 {
+		return ErrPeriodReserved
+	}
+
+	return nil
+}
+
+// checkOffers reports a waitlist offer that holds an overlapping window of
+// the space for someone other than userID until it expires
+func (s *BookingService) checkOffers(spaceID uint, startTime, endTime time.Time, userID *uint) error {
+	var count int64
+	query := config.DB.Model(&models.WaitlistEntry{}).
+		Where("space_id = ? AND status = ? AND offer_expires_at > ? AND start_time < ? AND end_time > ?",
+			spaceID, models.WaitlistOffered, time.Now(), endTime, startTime)
+
+	if userID != nil {
+		query = query.Where("user_id != ?", *userID)
+	}
+
+	query.Count(&count)
+
+	if count > 0 {
+		return ErrPeriodOffered
+	}
+
+	return nil
+}
+
+// isClosedDate checks if the given date is marked as closed
+func (s *BookingService) isClosedDate(date time.Time) bool {
+	// Convert to local timezone for date comparison
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+
+	localDate := date.In(loc)
+	var count int64
+	config.DB.Model(&models.ClosedDate{}).
+		Where("date = ? AND is_active = ?", localDate.Format("2006-01-02"), true).
+		Count(&count)
+	return count > 0
+}
+
+// isWithinBusinessHours checks if the reservation time is within business hours
+func (s *BookingService) isWithinBusinessHours(startTime, endTime time.Time) bool {
+	// Convert to local timezone for business hours validation
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		// Fallback to system timezone if location loading fails
+		loc = time.Local
+	}
+
+	localStartTime := startTime.In(loc)
+	localEndTime := endTime.In(loc)
+	dayOfWeek := int(localStartTime.Weekday())
+
+	var businessHour models.BusinessHour
+	err = config.DB.Where("day_of_week = ?", dayOfWeek).First(&businessHour).Error
+
+	if err != nil {
+		return false
+	}
+
+	if businessHour.IsClosed {
+		return false
+	}
+
+	startTimeStr := localStartTime.Format("15:04")
+	endTimeStr := localEndTime.Format("15:04")
+
+	// Check if reservation time is within business hours
+	return startTimeStr >= businessHour.StartTime && endTimeStr <= businessHour.EndTime
+}
+
+// isWithinSchedule checks the reservation against the space-specific schedules
+func (s *BookingService) isWithinSchedule(spaceID uint, startTime, endTime time.Time) bool {
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+
+	localStartTime := startTime.In(loc)
+	localEndTime := endTime.In(loc)
+
+	var schedules []models.Schedule
+	dayOfWeek := int(localStartTime.Weekday())
+	config.DB.Where("space_id = ? AND day_of_week = ? AND is_active = ?", spaceID, dayOfWeek, true).Find(&schedules)
+
+	// Use local times for schedule comparisons
+	startTimeStr := localStartTime.Format("15:04")
+	endTimeStr := localEndTime.Format("15:04")
+
+	for _, schedule := range schedules {
+		if startTimeStr >= schedule.StartTime && endTimeStr <= schedule.EndTime {
+			return true
+		}
+	}
+	return false
+}
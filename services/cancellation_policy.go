@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// defaultCancellationTiers applies when no policy has been configured:
+// full refund at 24 hours or more, nothing below that
+var defaultCancellationTiers = []models.CancellationPolicyTier{
+	{MinHours: 24, RefundPercent: 100},
+	{MinHours: 0, RefundPercent: 0},
+}
+
+// RefundQuote is the outcome of applying a cancellation policy to a reservation
+type RefundQuote struct {
+	PolicyID         *uint   `json:"policy_id"`
+	PolicyTierID     *uint   `json:"policy_tier_id"`
+	HoursBeforeStart float64 `json:"hours_before_start"`
+	RefundPercent    int     `json:"refund_percent"`
+	RefundCredits    int     `json:"refund_credits"`
+	WithheldCredits  int     `json:"withheld_credits"`
+}
+
+type CancellationPolicyService struct{}
+
+func NewCancellationPolicyService() *CancellationPolicyService {
+	return &CancellationPolicyService{}
+}
+
+func (s *CancellationPolicyService) GetPolicies() ([]models.CancellationPolicy, error) {
+	var policies []models.CancellationPolicy
+	err := config.DB.Preload("Space").
+		Preload("Tiers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("min_hours DESC")
+		}).
+		Order("space_id NULLS FIRST, created_at DESC").
+		Find(&policies).Error
+
+	return policies, err
+}
+
+func (s *CancellationPolicyService) CreatePolicy(name string, spaceID *uint, isActive bool, tiers []models.CancellationPolicyTier) (*models.CancellationPolicy, error) {
+	if err := s.validateTiers(tiers); err != nil {
+		return nil, err
+	}
+	if spaceID != nil {
+		var space models.Space
+		if err := config.DB.First(&space, *spaceID).Error; err != nil {
+			return nil, errors.New("Espacio no encontrado")
+		}
+	}
+
+	policy := models.CancellationPolicy{
+		Name:     name,
+		SpaceID:  spaceID,
+		IsActive: isActive,
+		Tiers:    tiers,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if isActive {
+			if err := s.deactivateScope(tx, spaceID, 0); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&policy).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// UpdatePolicy replaces the policy's name, activation and tiers
+func (s *CancellationPolicyService) UpdatePolicy(policyID uint, name string, isActive bool, tiers []models.CancellationPolicyTier) (*models.CancellationPolicy, error) {
+	if err := s.validateTiers(tiers); err != nil {
+		return nil, err
+	}
+
+	var policy models.CancellationPolicy
+	if err := config.DB.First(&policy, policyID).Error; err != nil {
+		return nil, errors.New("Política de cancelación no encontrada")
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if isActive {
+			if err := s.deactivateScope(tx, policy.SpaceID, policy.ID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&policy).Updates(map[string]interface{}{
+			"name":      name,
+			"is_active": isActive,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("policy_id = ?", policy.ID).Delete(&models.CancellationPolicyTier{}).Error; err != nil {
+			return err
+		}
+		for i := range tiers {
+			tiers[i].ID = 0
+			tiers[i].PolicyID = policy.ID
+		}
+		return tx.Create(&tiers).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policy.Name = name
+	policy.IsActive = isActive
+	policy.Tiers = tiers
+	return &policy, nil
+}
+
+func (s *CancellationPolicyService) DeletePolicy(policyID uint) error {
+	result := config.DB.Delete(&models.CancellationPolicy{}, policyID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Política de cancelación no encontrada")
+	}
+	return nil
+}
+
+// ResolvePolicy returns the active policy for a space, falling back to the
+// active default policy. Returns nil when neither exists.
+func (s *CancellationPolicyService) ResolvePolicy(spaceID uint) *models.CancellationPolicy {
+	var policy models.CancellationPolicy
+	err := config.DB.Preload("Tiers").
+		Where("is_active = ? AND (space_id = ? OR space_id IS NULL)", true, spaceID).
+		Order("space_id NULLS LAST").
+		First(&policy).Error
+	if err != nil {
+		return nil
+	}
+	return &policy
+}
+
+// QuoteRefund computes the credits refunded if the reservation is cancelled at
+// the given moment
+func (s *CancellationPolicyService) QuoteRefund(reservation *models.Reservation, at time.Time) RefundQuote {
+	return quoteRefund(s.ResolvePolicy(reservation.SpaceID), reservation, at)
+}
+
+// quoteRefund applies the tiers of the policy, or the default tiers when there
+// is no policy or it has none
+func quoteRefund(policy *models.CancellationPolicy, reservation *models.Reservation, at time.Time) RefundQuote {
+	quote := RefundQuote{
+		HoursBeforeStart: reservation.StartTime.Sub(at).Hours(),
+	}
+
+	tiers := defaultCancellationTiers
+	if policy != nil && len(policy.Tiers) > 0 {
+		quote.PolicyID = &policy.ID
+		tiers = policy.Tiers
+	}
+
+	// Highest threshold first so the first match is the most generous tier reached
+	sorted := make([]models.CancellationPolicyTier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinHours > sorted[j].MinHours })
+
+	for i := range sorted {
+		if quote.HoursBeforeStart >= sorted[i].MinHours {
+			quote.RefundPercent = sorted[i].RefundPercent
+			if sorted[i].ID != 0 {
+				quote.PolicyTierID = &sorted[i].ID
+			}
+			break
+		}
+	}
+
+	quote.RefundCredits = reservation.CreditsUsed * quote.RefundPercent / 100
+	quote.WithheldCredits = reservation.CreditsUsed - quote.RefundCredits
+	return quote
+}
+
+func (s *CancellationPolicyService) validateTiers(tiers []models.CancellationPolicyTier) error {
+	if len(tiers) == 0 {
+		return errors.New("La política debe tener al menos un nivel")
+	}
+
+	seen := make(map[float64]bool)
+	for _, tier := range tiers {
+		if tier.MinHours < 0 {
+			return errors.New("Las horas mínimas de un nivel no pueden ser negativas")
+		}
+		if tier.RefundPercent < 0 || tier.RefundPercent > 100 {
+			return errors.New("El porcentaje de reembolso debe estar entre 0 y 100")
+		}
+		if seen[tier.MinHours] {
+			return errors.New("No puede haber dos niveles con las mismas horas mínimas")
+		}
+		seen[tier.MinHours] = true
+	}
+	return nil
+}
+
+// deactivateScope deactivates the other active policies that share the same
+// space (or the default scope), so only one applies at a time
+func (s *CancellationPolicyService) deactivateScope(tx *gorm.DB, spaceID *uint, exceptID uint) error {
+	query := tx.Model(&models.CancellationPolicy{}).Where("is_active = ? AND id != ?", true, exceptID)
+	if spaceID != nil {
+		query = query.Where("space_id = ?", *spaceID)
+	} else {
+		query = query.Where("space_id IS NULL")
+	}
+	return query.Update("is_active", false).Error
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+func TestQuoteRefund(t *testing.T) {
+	policyTiers := []models.CancellationPolicyTier{
+		{ID: 11, MinHours: 24, RefundPercent: 50},
+		{ID: 10, MinHours: 48, RefundPercent: 100},
+		{ID: 12, MinHours: 0, RefundPercent: 0},
+	}
+	noFloorTiers := []models.CancellationPolicyTier{
+		{ID: 20, MinHours: 12, RefundPercent: 100},
+	}
+
+	tests := []struct {
+		name         string
+		tiers        []models.CancellationPolicyTier
+		hoursBefore  float64
+		creditsUsed  int
+		wantPolicy   bool
+		wantTierID   uint
+		wantPercent  int
+		wantRefund   int
+		wantWithheld int
+	}{
+		{"default, well ahead", nil, 72, 10, false, 0, 100, 10, 0},
+		{"default, exactly 24 hours", nil, 24, 10, false, 0, 100, 10, 0},
+		{"default, under 24 hours", nil, 23.5, 10, false, 0, 0, 0, 10},
+		{"default, after the start", nil, -1, 10, false, 0, 0, 0, 10},
+		{"policy without tiers uses the default", []models.CancellationPolicyTier{}, 30, 10, false, 0, 100, 10, 0},
+		{"policy, highest tier", policyTiers, 50, 10, true, 10, 100, 10, 0},
+		{"policy, middle tier", policyTiers, 30, 10, true, 11, 50, 5, 5},
+		{"policy, rounds the refund down", policyTiers, 30, 3, true, 11, 50, 1, 2},
+		{"policy, lowest tier", policyTiers, 2, 10, true, 12, 0, 0, 10},
+		{"policy, below every tier", noFloorTiers, 6, 10, true, 0, 0, 0, 10},
+	}
+
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var policy *models.CancellationPolicy
+			if tt.tiers != nil {
+				policy = &models.CancellationPolicy{ID: 3, Name: "Estándar", IsActive: true, Tiers: tt.tiers}
+			}
+			reservation := &models.Reservation{
+				SpaceID:     1,
+				StartTime:   at.Add(time.Duration(tt.hoursBefore * float64(time.Hour))),
+				CreditsUsed: tt.creditsUsed,
+			}
+			quote := quoteRefund(policy, reservation, at)
+
+			if (quote.PolicyID != nil) != tt.wantPolicy {
+				t.Fatalf("PolicyID = %v, want a policy: %v", quote.PolicyID, tt.wantPolicy)
+			}
+			var tierID uint
+			if quote.PolicyTierID != nil {
+				tierID = *quote.PolicyTierID
+			}
+			if tierID != tt.wantTierID {
+				t.Fatalf("PolicyTierID = %d, want %d", tierID, tt.wantTierID)
+			}
+			if quote.HoursBeforeStart != tt.hoursBefore {
+				t.Fatalf("HoursBeforeStart = %v, want %v", quote.HoursBeforeStart, tt.hoursBefore)
+			}
+			if quote.RefundPercent != tt.wantPercent || quote.RefundCredits != tt.wantRefund || quote.WithheldCredits != tt.wantWithheld {
+				t.Fatalf("quote = %d%%, %d refunded, %d withheld; want %d%%, %d, %d",
+					quote.RefundPercent, quote.RefundCredits, quote.WithheldCredits,
+					tt.wantPercent, tt.wantRefund, tt.wantWithheld)
+			}
+		})
+	}
+}
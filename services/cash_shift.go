@@ -0,0 +1,360 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// CashShiftAdminTotal is what one admin took in with one payment method
+// during a shift
+type CashShiftAdminTotal struct {
+	AdminID       uint         `json:"admin_id"`
+	AdminName     string       `json:"admin_name"`
+	PaymentMethod string       `json:"payment_method"`
+	Payments      int          `json:"payments"`
+	Amount        models.Money `json:"amount"`
+}
+
+// CashShiftSummary is a shift with its payments totalled by method and by
+// admin. While the shift is open the totals only carry the expected amounts.
+type CashShiftSummary struct {
+	Shift    *models.CashShift       `json:"shift"`
+	Methods  []models.CashShiftTotal `json:"methods"`
+	Admins   []CashShiftAdminTotal   `json:"admins"`
+	Expected models.Money            `json:"expected"`
+}
+
+type CashShiftService struct{}
+
+func NewCashShiftService() *CashShiftService {
+	return &CashShiftService{}
+}
+
+// OpenShift opens the register with the cash left in the drawer. Payments
+// admins register from now on belong to the shift until it is closed.
+func (s *CashShiftService) OpenShift(adminID uint, openingCash models.Money, notes string) (*models.CashShift, error) {
+	if openingCash < 0 {
+		return nil, errors.New("El fondo inicial no puede ser negativo")
+	}
+
+	shift := models.CashShift{
+		Status:      models.CashShiftOpen,
+		OpenedBy:    adminID,
+		OpenedAt:    time.Now(),
+		OpeningCash: openingCash,
+		Currency:    models.DefaultCurrency,
+		Notes:       notes,
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var open int64
+		if err := tx.Model(&models.CashShift{}).Where("status = ?", models.CashShiftOpen).Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return errors.New("Ya hay un turno de caja abierto")
+		}
+		return tx.Create(&shift).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &shift, nil
+}
+
+// GetCurrentShift returns the open shift with what it has taken in so far
+func (s *CashShiftService) GetCurrentShift() (*CashShiftSummary, error) {
+	var shift models.CashShift
+	if err := config.DB.Preload("Opener").Where("status = ?", models.CashShiftOpen).First(&shift).Error; err != nil {
+		return nil, errors.New("No hay un turno de caja abierto")
+	}
+	return s.summary(config.DB, &shift)
+}
+
+// GetShifts lists the shifts, the most recent first
+func (s *CashShiftService) GetShifts() ([]models.CashShift, error) {
+	var shifts []models.CashShift
+	err := config.DB.Preload("Opener").Preload("Closer").
+		Order("opened_at DESC").
+		Find(&shifts).Error
+	return shifts, err
+}
+
+func (s *CashShiftService) GetShift(shiftID uint) (*CashShiftSummary, error) {
+	var shift models.CashShift
+	if err := config.DB.Preload("Opener").Preload("Closer").First(&shift, shiftID).Error; err != nil {
+		return nil, errors.New("Turno de caja no encontrado")
+	}
+	return s.summary(config.DB, &shift)
+}
+
+// CloseShift does the corte de caja: counted has what the admin counted for
+// each payment method, and the difference with what the system expected is
+// recorded per method. Once closed the shift's payments can no longer be
+// changed.
+func (s *CashShiftService) CloseShift(shiftID, adminID uint, counted map[string]models.Money, notes string) (*CashShiftSummary, error) {
+	normalized := make(map[string]models.Money, len(counted))
+	for method, amount := range counted {
+		if amount < 0 {
+			return nil, errors.New("Los montos contados no pueden ser negativos")
+		}
+		normalized[strings.ToLower(strings.TrimSpace(method))] += amount
+	}
+
+	var result *CashShiftSummary
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the shift waits for payments being registered in it, which
+		// hold it share-locked until they commit
+		var shift models.CashShift
+		if err := forUpdate(tx).First(&shift, shiftID).Error; err != nil {
+			return errors.New("Turno de caja no encontrado")
+		}
+		if shift.Status != models.CashShiftOpen {
+			return errors.New("El turno de caja ya está cerrado")
+		}
+
+		summary, err := s.summary(tx, &shift)
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool, len(summary.Methods))
+		for i := range summary.Methods {
+			total := &summary.Methods[i]
+			amount, ok := normalized[total.PaymentMethod]
+			if !ok {
+				return fmt.Errorf("Falta el monto contado de %s", total.PaymentMethod)
+			}
+			total.Counted = amount
+			total.Difference = amount - total.Expected
+			seen[total.PaymentMethod] = true
+		}
+		// Money counted for a method with no payments is all surplus
+		for method, amount := range normalized {
+			if !seen[method] && amount != 0 {
+				summary.Methods = append(summary.Methods, models.CashShiftTotal{
+					ShiftID:       shift.ID,
+					PaymentMethod: method,
+					Counted:       amount,
+					Difference:    amount,
+				})
+			}
+		}
+		sort.Slice(summary.Methods, func(i, j int) bool {
+			return summary.Methods[i].PaymentMethod < summary.Methods[j].PaymentMethod
+		})
+
+		var countedTotal models.Money
+		for i := range summary.Methods {
+			countedTotal += summary.Methods[i].Counted
+			if err := tx.Create(&summary.Methods[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":           models.CashShiftClosed,
+			"closed_by":        adminID,
+			"closed_at":        now,
+			"expected_cents":   summary.Expected,
+			"counted_cents":    countedTotal,
+			"difference_cents": countedTotal - summary.Expected,
+		}
+		if strings.TrimSpace(notes) != "" {
+			updates["notes"] = notes
+		}
+		if err := tx.Model(&shift).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Opener").Preload("Closer").First(&shift, shift.ID).Error; err != nil {
+			return err
+		}
+
+		summary.Shift = &shift
+		result = summary
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// summary totals the shift's payments by method and by admin. Refunds and
+// voids are negative payments, so they come out of the method they were
+// given back with. Cash always has a total, since the drawer holds the
+// opening cash.
+func (s *CashShiftService) summary(db *gorm.DB, shift *models.CashShift) (*CashShiftSummary, error) {
+	summary := &CashShiftSummary{Shift: shift, Methods: []models.CashShiftTotal{}, Admins: []CashShiftAdminTotal{}}
+
+	if shift.Status == models.CashShiftClosed {
+		if err := db.Where("shift_id = ?", shift.ID).Order("payment_method ASC").Find(&summary.Methods).Error; err != nil {
+			return nil, err
+		}
+		summary.Expected = shift.Expected
+	} else {
+		var rows []struct {
+			PaymentMethod string
+			Payments      int
+			Amount        models.Money
+		}
+		if err := db.Model(&models.Payment{}).
+			Select("LOWER(payment_method) AS payment_method, COUNT(*) AS payments, COALESCE(SUM(amount_cents), 0) AS amount").
+			Where("cash_shift_id = ?", shift.ID).
+			Group("LOWER(payment_method)").
+			Order("payment_method ASC").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+
+		hasCash := false
+		for _, row := range rows {
+			total := models.CashShiftTotal{
+				ShiftID:       shift.ID,
+				PaymentMethod: row.PaymentMethod,
+				Payments:      row.Payments,
+				Expected:      row.Amount,
+			}
+			if row.PaymentMethod == models.CashPaymentMethod {
+				total.Expected += shift.OpeningCash
+				hasCash = true
+			}
+			summary.Methods = append(summary.Methods, total)
+		}
+		if !hasCash {
+			summary.Methods = append(summary.Methods, models.CashShiftTotal{
+				ShiftID:       shift.ID,
+				PaymentMethod: models.CashPaymentMethod,
+				Expected:      shift.OpeningCash,
+			})
+			sort.Slice(summary.Methods, func(i, j int) bool {
+				return summary.Methods[i].PaymentMethod < summary.Methods[j].PaymentMethod
+			})
+		}
+		for _, total := range summary.Methods {
+			summary.Expected += total.Expected
+		}
+	}
+
+	if err := db.Model(&models.Payment{}).
+		Select("payments.admin_id, users.name AS admin_name, LOWER(payments.payment_method) AS payment_method, COUNT(*) AS payments, COALESCE(SUM(payments.amount_cents), 0) AS amount").
+		Joins("JOIN users ON users.id = payments.admin_id").
+		Where("payments.cash_shift_id = ?", shift.ID).
+		Group("payments.admin_id, users.name, LOWER(payments.payment_method)").
+		Order("admin_name ASC, payment_method ASC").
+		Scan(&summary.Admins).Error; err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// ExportShift writes a closed shift as CSV: the corte de caja, the totals by
+// method and by admin, and every payment in it
+func (s *CashShiftService) ExportShift(shiftID uint) ([]byte, error) {
+	summary, err := s.GetShift(shiftID)
+	if err != nil {
+		return nil, err
+	}
+	shift := summary.Shift
+	if shift.Status != models.CashShiftClosed {
+		return nil, errors.New("Solo se pueden exportar turnos de caja cerrados")
+	}
+
+	var payments []models.Payment
+	if err := config.DB.Preload("User").Preload("Admin").
+		Where("cash_shift_id = ?", shift.ID).
+		Order("created_at ASC").
+		Find(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+	const layout = "2006-01-02 15:04:05"
+
+	openedBy, closedBy, closedAt := "", "", ""
+	if shift.Opener != nil {
+		openedBy = shift.Opener.Name
+	}
+	if shift.Closer != nil {
+		closedBy = shift.Closer.Name
+	}
+	if shift.ClosedAt != nil {
+		closedAt = shift.ClosedAt.In(loc).Format(layout)
+	}
+
+	records := [][]string{
+		{"Turno", strconv.FormatUint(uint64(shift.ID), 10)},
+		{"Abierto por", openedBy},
+		{"Apertura", shift.OpenedAt.In(loc).Format(layout)},
+		{"Cerrado por", closedBy},
+		{"Cierre", closedAt},
+		{"Moneda", shift.Currency},
+		{"Fondo inicial", shift.OpeningCash.String()},
+		{"Esperado", shift.Expected.String()},
+		{"Contado", shift.Counted.String()},
+		{"Diferencia", shift.Difference.String()},
+		{"Notas", shift.Notes},
+		{},
+		{"Forma de pago", "Pagos", "Esperado", "Contado", "Diferencia"},
+	}
+	for _, total := range summary.Methods {
+		records = append(records, []string{
+			total.PaymentMethod,
+			strconv.Itoa(total.Payments),
+			total.Expected.String(),
+			total.Counted.String(),
+			total.Difference.String(),
+		})
+	}
+
+	records = append(records, []string{}, []string{"Administrador", "Forma de pago", "Pagos", "Monto"})
+	for _, total := range summary.Admins {
+		records = append(records, []string{
+			total.AdminName,
+			total.PaymentMethod,
+			strconv.Itoa(total.Payments),
+			total.Amount.String(),
+		})
+	}
+
+	records = append(records, []string{}, []string{"Pago", "Fecha", "Usuario", "Administrador", "Tipo", "Forma de pago", "Referencia", "Monto"})
+	for _, payment := range payments {
+		admin := ""
+		if payment.Admin != nil {
+			admin = payment.Admin.Name
+		}
+		records = append(records, []string{
+			strconv.FormatUint(uint64(payment.ID), 10),
+			payment.CreatedAt.In(loc).Format(layout),
+			payment.User.Name,
+			admin,
+			string(payment.Type),
+			payment.PaymentMethod,
+			payment.Reference,
+			payment.Amount.String(),
+		})
+	}
+
+	var out bytes.Buffer
+	writer := csv.NewWriter(&out)
+	if err := writer.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+// takeTestPayment registers a front desk payment of amount at 100.00 a credit
+func takeTestPayment(t *testing.T, userID, adminID uint, amount models.Money, method string) (*models.Payment, error) {
+	t.Helper()
+	t.Setenv("CREDIT_UNIT_PRICE", "100.00")
+	return NewPaymentService().RegisterPayment(PaymentInput{
+		UserID:        userID,
+		AdminID:       adminID,
+		Amount:        amount,
+		PaymentMethod: method,
+	})
+}
+
+func TestCashRequiresOpenShift(t *testing.T) {
+	db := useTestDB(t)
+	admin := createTestUser(t, db, "Admin")
+	ana := createTestUser(t, db, "Ana")
+
+	if _, err := takeTestPayment(t, ana.ID, admin.ID, 10000, " Cash "); !errors.Is(err, models.ErrNoOpenCashShift) {
+		t.Fatalf("cash without a shift: RegisterPayment() = %v, want %v", err, models.ErrNoOpenCashShift)
+	}
+	requireBalance(t, ana.ID, 0)
+
+	payment, err := takeTestPayment(t, ana.ID, admin.ID, 10000, "transfer")
+	if err != nil {
+		t.Fatalf("transfer without a shift: RegisterPayment() = %v", err)
+	}
+	if payment.CashShiftID != nil {
+		t.Fatalf("transfer was put in shift %d with no shift open", *payment.CashShiftID)
+	}
+	// A refund of it does not need a shift either
+	if _, err := NewPaymentService().RefundPayment(payment.ID, admin.ID, 0, "Duplicado"); err != nil {
+		t.Fatalf("refund without a shift: RefundPayment() = %v", err)
+	}
+}
+
+// The corte de caja expects the opening cash plus every payment net of
+// refunds, per method, and records what was counted against it
+func TestCloseShiftTotals(t *testing.T) {
+	db := useTestDB(t)
+	alma := createTestUser(t, db, "Alma")
+	bruno := createTestUser(t, db, "Bruno")
+	ana := createTestUser(t, db, "Ana")
+	service := NewCashShiftService()
+
+	shift, err := service.OpenShift(alma.ID, 50000, "")
+	if err != nil {
+		t.Fatalf("OpenShift: %v", err)
+	}
+	if _, err := service.OpenShift(bruno.ID, 0, ""); err == nil {
+		t.Fatal("OpenShift() opened a second shift")
+	}
+
+	cash, err := takeTestPayment(t, ana.ID, alma.ID, 30000, "cash")
+	if err != nil {
+		t.Fatalf("RegisterPayment: %v", err)
+	}
+	if _, err := takeTestPayment(t, ana.ID, bruno.ID, 20000, "card"); err != nil {
+		t.Fatalf("RegisterPayment: %v", err)
+	}
+	if _, err := takeTestPayment(t, ana.ID, bruno.ID, 10000, "Cash"); err != nil {
+		t.Fatalf("RegisterPayment: %v", err)
+	}
+	if _, err := NewPaymentService().RefundPayment(cash.ID, alma.ID, 10000, "Un crédito de más"); err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+
+	if _, err := service.CloseShift(shift.ID, alma.ID, map[string]models.Money{"cash": 80000}, ""); err == nil {
+		t.Fatal("CloseShift() closed without the card count")
+	}
+
+	summary, err := service.CloseShift(shift.ID, alma.ID, map[string]models.Money{
+		"Cash":     79000,
+		"card":     20000,
+		"transfer": 5000,
+	}, "Faltan 10 pesos")
+	if err != nil {
+		t.Fatalf("CloseShift: %v", err)
+	}
+
+	want := []models.CashShiftTotal{
+		{PaymentMethod: "card", Payments: 1, Expected: 20000, Counted: 20000, Difference: 0},
+		{PaymentMethod: "cash", Payments: 3, Expected: 80000, Counted: 79000, Difference: -1000},
+		{PaymentMethod: "transfer", Payments: 0, Expected: 0, Counted: 5000, Difference: 5000},
+	}
+	if len(summary.Methods) != len(want) {
+		t.Fatalf("got %d method totals, want %d", len(summary.Methods), len(want))
+	}
+	for i, total := range summary.Methods {
+		w := want[i]
+		if total.PaymentMethod != w.PaymentMethod || total.Payments != w.Payments || total.Expected != w.Expected ||
+			total.Counted != w.Counted || total.Difference != w.Difference {
+			t.Fatalf("%s total = %d payments, expected %s, counted %s, difference %s; want %d, %s, %s, %s",
+				total.PaymentMethod, total.Payments, total.Expected, total.Counted, total.Difference,
+				w.Payments, w.Expected, w.Counted, w.Difference)
+		}
+	}
+
+	closed := summary.Shift
+	if closed.Status != models.CashShiftClosed || closed.Expected != 100000 || closed.Counted != 104000 || closed.Difference != 4000 {
+		t.Fatalf("shift = %s, expected %s, counted %s, difference %s; want closed, 1000.00, 1040.00, 40.00",
+			closed.Status, closed.Expected, closed.Counted, closed.Difference)
+	}
+
+	// The stored totals are what the shift reports from now on
+	stored, err := service.GetShift(shift.ID)
+	if err != nil {
+		t.Fatalf("GetShift: %v", err)
+	}
+	if len(stored.Methods) != len(want) || stored.Expected != closed.Expected {
+		t.Fatalf("stored shift has %d totals expecting %s, want %d expecting %s",
+			len(stored.Methods), stored.Expected, len(want), closed.Expected)
+	}
+
+	if _, err := service.CloseShift(shift.ID, alma.ID, map[string]models.Money{"cash": 0, "card": 0}, ""); err == nil {
+		t.Fatal("CloseShift() closed a shift twice")
+	}
+	cash.Reference = "Cambiada"
+	if err := db.Save(cash).Error; !errors.Is(err, models.ErrPaymentShiftClosed) {
+		t.Fatalf("changing a payment of a closed shift: Save() = %v, want %v", err, models.ErrPaymentShiftClosed)
+	}
+}
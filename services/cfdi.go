@@ -0,0 +1,258 @@
+package services
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+const (
+	cfdiNamespace      = "http://www.sat.gob.mx/cfd/4"
+	cfdiSchemaLocation = "http://www.sat.gob.mx/cfd/4 http://www.sat.gob.mx/sitio_internet/cfd/4/cfdv40.xsd"
+	cfdiVATRate        = "0.160000"
+	cfdiVATTax         = "002" // c_Impuesto for IVA
+)
+
+var (
+	rfcPattern     = regexp.MustCompile(`^[A-ZÑ&]{3,4}[0-9]{6}[A-Z0-9]{3}$`)
+	zipCodePattern = regexp.MustCompile(`^[0-9]{5}$`)
+)
+
+// taxRegimes is the SAT c_RegimenFiscal catalog
+var taxRegimes = map[string]bool{
+	"601": true, "603": true, "605": true, "606": true, "607": true, "608": true,
+	"610": true, "611": true, "612": true, "614": true, "615": true, "616": true,
+	"620": true, "621": true, "622": true, "623": true, "624": true, "625": true,
+	"626": true,
+}
+
+// cfdiUses is the SAT c_UsoCFDI catalog
+var cfdiUses = map[string]bool{
+	"G01": true, "G02": true, "G03": true,
+	"I01": true, "I02": true, "I03": true, "I04": true, "I05": true, "I06": true, "I07": true, "I08": true,
+	"D01": true, "D02": true, "D03": true, "D04": true, "D05": true, "D06": true, "D07": true, "D08": true, "D09": true, "D10": true,
+	"S01": true, "CP01": true, "CN01": true,
+}
+
+// paymentForms maps the payment methods used at the front desk and online to
+// the SAT c_FormaPago catalog
+var paymentForms = map[string]string{
+	"cash":          "01",
+	"efectivo":      "01",
+	"transfer":      "03",
+	"transferencia": "03",
+	"card":          "04",
+	"tarjeta":       "04",
+	"online":        "04",
+}
+
+// normalizeTaxData trims and upper-cases the fields and checks them against
+// the SAT formats and catalogs
+func normalizeTaxData(data models.TaxData) (models.TaxData, error) {
+	data.RFC = strings.ToUpper(strings.TrimSpace(data.RFC))
+	data.LegalName = strings.ToUpper(strings.TrimSpace(data.LegalName))
+	data.Regime = strings.TrimSpace(data.Regime)
+	data.ZipCode = strings.TrimSpace(data.ZipCode)
+	data.CFDIUse = strings.ToUpper(strings.TrimSpace(data.CFDIUse))
+
+	if !rfcPattern.MatchString(data.RFC) {
+		return data, errors.New("RFC inválido")
+	}
+	if data.LegalName == "" {
+		return data, errors.New("La razón social es requerida")
+	}
+	if !taxRegimes[data.Regime] {
+		return data, errors.New("Régimen fiscal inválido")
+	}
+	if !zipCodePattern.MatchString(data.ZipCode) {
+		return data, errors.New("El código postal debe tener 5 dígitos")
+	}
+	if !cfdiUses[data.CFDIUse] {
+		return data, errors.New("Uso de CFDI inválido")
+	}
+	return data, nil
+}
+
+// splitVAT breaks a price that includes 16% VAT into its base and tax, so
+// that base plus tax is exactly what was paid
+func splitVAT(total models.Money) (subtotal, vat models.Money) {
+	subtotal = models.Money((int64(total)*100 + 58) / 116)
+	return subtotal, total - subtotal
+}
+
+// invoiceIssuer is the business issuing the invoices, configured through the
+// INVOICE_* variables
+type invoiceIssuer struct {
+	RFC               string
+	Name              string
+	Regime            string
+	ZipCode           string // Lugar de expedición
+	Series            string
+	CertificateNumber string
+	ProductKey        string // c_ClaveProdServ of the credits sold
+	UnitKey           string // c_ClaveUnidad
+}
+
+func invoiceIssuerFromEnv() (*invoiceIssuer, error) {
+	issuer := &invoiceIssuer{
+		RFC:               strings.ToUpper(os.Getenv("INVOICE_ISSUER_RFC")),
+		Name:              strings.ToUpper(os.Getenv("INVOICE_ISSUER_NAME")),
+		Regime:            os.Getenv("INVOICE_ISSUER_REGIME"),
+		ZipCode:           os.Getenv("INVOICE_ISSUER_ZIP_CODE"),
+		Series:            os.Getenv("INVOICE_SERIES"),
+		CertificateNumber: os.Getenv("INVOICE_CERTIFICATE_NUMBER"),
+		ProductKey:        os.Getenv("INVOICE_PRODUCT_KEY"),
+		UnitKey:           "E48", // Unidad de servicio
+	}
+	if issuer.Series == "" {
+		issuer.Series = "A"
+	}
+	if issuer.ProductKey == "" {
+		issuer.ProductKey = "80131500" // Alquiler de propiedades comerciales
+	}
+	if !rfcPattern.MatchString(issuer.RFC) || issuer.Name == "" || !taxRegimes[issuer.Regime] || !zipCodePattern.MatchString(issuer.ZipCode) {
+		return nil, errors.New("Los datos fiscales del emisor no están configurados")
+	}
+	return issuer, nil
+}
+
+type cfdiComprobante struct {
+	XMLName           xml.Name       `xml:"cfdi:Comprobante"`
+	XMLNSCfdi         string         `xml:"xmlns:cfdi,attr"`
+	XMLNSXsi          string         `xml:"xmlns:xsi,attr"`
+	SchemaLocation    string         `xml:"xsi:schemaLocation,attr"`
+	Version           string         `xml:"Version,attr"`
+	Serie             string         `xml:"Serie,attr"`
+	Folio             string         `xml:"Folio,attr"`
+	Fecha             string         `xml:"Fecha,attr"`
+	Sello             string         `xml:"Sello,attr"`
+	FormaPago         string         `xml:"FormaPago,attr"`
+	NoCertificado     string         `xml:"NoCertificado,attr"`
+	Certificado       string         `xml:"Certificado,attr"`
+	SubTotal          string         `xml:"SubTotal,attr"`
+	Moneda            string         `xml:"Moneda,attr"`
+	Total             string         `xml:"Total,attr"`
+	TipoDeComprobante string         `xml:"TipoDeComprobante,attr"`
+	Exportacion       string         `xml:"Exportacion,attr"`
+	MetodoPago        string         `xml:"MetodoPago,attr"`
+	LugarExpedicion   string         `xml:"LugarExpedicion,attr"`
+	Emisor            cfdiEmisor     `xml:"cfdi:Emisor"`
+	Receptor          cfdiReceptor   `xml:"cfdi:Receptor"`
+	Conceptos         []cfdiConcepto `xml:"cfdi:Conceptos>cfdi:Concepto"`
+	Impuestos         cfdiImpuestos  `xml:"cfdi:Impuestos"`
+}
+
+type cfdiEmisor struct {
+	Rfc           string `xml:"Rfc,attr"`
+	Nombre        string `xml:"Nombre,attr"`
+	RegimenFiscal string `xml:"RegimenFiscal,attr"`
+}
+
+type cfdiReceptor struct {
+	Rfc                     string `xml:"Rfc,attr"`
+	Nombre                  string `xml:"Nombre,attr"`
+	DomicilioFiscalReceptor string `xml:"DomicilioFiscalReceptor,attr"`
+	RegimenFiscalReceptor   string `xml:"RegimenFiscalReceptor,attr"`
+	UsoCFDI                 string `xml:"UsoCFDI,attr"`
+}
+
+type cfdiConcepto struct {
+	ClaveProdServ    string                `xml:"ClaveProdServ,attr"`
+	NoIdentificacion string                `xml:"NoIdentificacion,attr,omitempty"`
+	Cantidad         string                `xml:"Cantidad,attr"`
+	ClaveUnidad      string                `xml:"ClaveUnidad,attr"`
+	Unidad           string                `xml:"Unidad,attr"`
+	Descripcion      string                `xml:"Descripcion,attr"`
+	ValorUnitario    string                `xml:"ValorUnitario,attr"`
+	Importe          string                `xml:"Importe,attr"`
+	ObjetoImp        string                `xml:"ObjetoImp,attr"`
+	Traslados        []cfdiConceptTraslado `xml:"cfdi:Impuestos>cfdi:Traslados>cfdi:Traslado"`
+}
+
+type cfdiConceptTraslado struct {
+	Base       string `xml:"Base,attr"`
+	Impuesto   string `xml:"Impuesto,attr"`
+	TipoFactor string `xml:"TipoFactor,attr"`
+	TasaOCuota string `xml:"TasaOCuota,attr"`
+	Importe    string `xml:"Importe,attr"`
+}
+
+type cfdiImpuestos struct {
+	TotalImpuestosTrasladados string                `xml:"TotalImpuestosTrasladados,attr"`
+	Traslados                 []cfdiConceptTraslado `xml:"cfdi:Traslados>cfdi:Traslado"`
+}
+
+// buildCFDI renders the unsigned CFDI 4.0 ingreso for an invoice. The
+// payment is invoiced as a single concept, paid in one exhibition (PUE).
+func buildCFDI(issuer *invoiceIssuer, invoice *models.Invoice, description string, issuedAt time.Time) ([]byte, error) {
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+
+	traslado := cfdiConceptTraslado{
+		Base:       invoice.Subtotal.String(),
+		Impuesto:   cfdiVATTax,
+		TipoFactor: "Tasa",
+		TasaOCuota: cfdiVATRate,
+		Importe:    invoice.VAT.String(),
+	}
+
+	comprobante := cfdiComprobante{
+		XMLNSCfdi:         cfdiNamespace,
+		XMLNSXsi:          "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation:    cfdiSchemaLocation,
+		Version:           "4.0",
+		Serie:             invoice.Series,
+		Folio:             invoice.Folio,
+		Fecha:             issuedAt.In(loc).Format("2006-01-02T15:04:05"),
+		FormaPago:         invoice.PaymentForm,
+		NoCertificado:     issuer.CertificateNumber,
+		SubTotal:          invoice.Subtotal.String(),
+		Moneda:            invoice.Currency,
+		Total:             invoice.Total.String(),
+		TipoDeComprobante: "I",
+		Exportacion:       "01",
+		MetodoPago:        "PUE",
+		LugarExpedicion:   issuer.ZipCode,
+		Emisor: cfdiEmisor{
+			Rfc:           issuer.RFC,
+			Nombre:        issuer.Name,
+			RegimenFiscal: issuer.Regime,
+		},
+		Receptor: cfdiReceptor{
+			Rfc:                     invoice.Receiver.RFC,
+			Nombre:                  invoice.Receiver.LegalName,
+			DomicilioFiscalReceptor: invoice.Receiver.ZipCode,
+			RegimenFiscalReceptor:   invoice.Receiver.Regime,
+			UsoCFDI:                 invoice.Receiver.CFDIUse,
+		},
+		Conceptos: []cfdiConcepto{{
+			ClaveProdServ:    issuer.ProductKey,
+			NoIdentificacion: "PAGO-" + invoice.Folio,
+			Cantidad:         "1",
+			ClaveUnidad:      issuer.UnitKey,
+			Unidad:           "Servicio",
+			Descripcion:      description,
+			ValorUnitario:    invoice.Subtotal.String(),
+			Importe:          invoice.Subtotal.String(),
+			ObjetoImp:        "02",
+			Traslados:        []cfdiConceptTraslado{traslado},
+		}},
+		Impuestos: cfdiImpuestos{
+			TotalImpuestosTrasladados: invoice.VAT.String(),
+			Traslados:                 []cfdiConceptTraslado{traslado},
+		},
+	}
+
+	body, err := xml.MarshalIndent(comprobante, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
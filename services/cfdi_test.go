@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+func TestSplitVAT(t *testing.T) {
+	tests := []struct {
+		total    models.Money
+		subtotal models.Money
+		vat      models.Money
+	}{
+		{11600, 10000, 1600},
+		{100000, 86207, 13793},
+		{9999, 8620, 1379},
+		{1, 1, 0},
+		{2, 2, 0},
+		{0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.total.String(), func(t *testing.T) {
+			subtotal, vat := splitVAT(tt.total)
+			if subtotal != tt.subtotal || vat != tt.vat {
+				t.Fatalf("splitVAT(%s) = %s, %s, want %s, %s", tt.total, subtotal, vat, tt.subtotal, tt.vat)
+			}
+		})
+	}
+}
+
+// The base and tax must always add up to what was paid, whatever the rounding
+func TestSplitVATAddsUp(t *testing.T) {
+	for total := models.Money(0); total <= 100000; total++ {
+		subtotal, vat := splitVAT(total)
+		if subtotal+vat != total {
+			t.Fatalf("splitVAT(%s) = %s + %s, does not add up", total, subtotal, vat)
+		}
+		if vat < 0 || subtotal < 0 {
+			t.Fatalf("splitVAT(%s) = %s, %s, want non-negative parts", total, subtotal, vat)
+		}
+	}
+}
+
+// parsedCFDI reads back the amounts of a rendered CFDI
+type parsedCFDI struct {
+	SubTotal  string `xml:"SubTotal,attr"`
+	Total     string `xml:"Total,attr"`
+	Moneda    string `xml:"Moneda,attr"`
+	Conceptos []struct {
+		ValorUnitario string           `xml:"ValorUnitario,attr"`
+		Importe       string           `xml:"Importe,attr"`
+		Traslados     []parsedTraslado `xml:"Impuestos>Traslados>Traslado"`
+	} `xml:"Conceptos>Concepto"`
+	Impuestos struct {
+		TotalImpuestosTrasladados string           `xml:"TotalImpuestosTrasladados,attr"`
+		Traslados                 []parsedTraslado `xml:"Traslados>Traslado"`
+	} `xml:"Impuestos"`
+}
+
+type parsedTraslado struct {
+	Base       string `xml:"Base,attr"`
+	TasaOCuota string `xml:"TasaOCuota,attr"`
+	Importe    string `xml:"Importe,attr"`
+}
+
+func parseAmount(t *testing.T, field, value string) models.Money {
+	t.Helper()
+	amount, err := models.ParseMoney(value)
+	if err != nil {
+		t.Fatalf("%s = %q: %v", field, value, err)
+	}
+	return amount
+}
+
+func TestBuildCFDITotals(t *testing.T) {
+	issuer := &invoiceIssuer{
+		RFC:        "EKU9003173C9",
+		Name:       "ESCUELA KEMPER URGATE",
+		Regime:     "601",
+		ZipCode:    "42501",
+		Series:     "A",
+		ProductKey: "80131500",
+		UnitKey:    "E48",
+	}
+
+	for _, total := range []models.Money{11600, 100000, 9999, 1, 35050} {
+		t.Run(total.String(), func(t *testing.T) {
+			subtotal, vat := splitVAT(total)
+			invoice := &models.Invoice{
+				Series:      "A",
+				Folio:       "1",
+				Subtotal:    subtotal,
+				VAT:         vat,
+				Total:       total,
+				Currency:    models.DefaultCurrency,
+				PaymentForm: "01",
+				Receiver: models.TaxData{
+					RFC:       "XAXX010101000",
+					LegalName: "PUBLICO EN GENERAL",
+					Regime:    "616",
+					ZipCode:   "42501",
+					CFDIUse:   "S01",
+				},
+			}
+
+			body, err := buildCFDI(issuer, invoice, "Compra de créditos", time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("buildCFDI: %v", err)
+			}
+
+			var cfdi parsedCFDI
+			if err := xml.Unmarshal(body, &cfdi); err != nil {
+				t.Fatalf("xml.Unmarshal: %v\n%s", err, body)
+			}
+
+			if got := parseAmount(t, "Total", cfdi.Total); got != total {
+				t.Fatalf("Total = %s, want %s", got, total)
+			}
+			gotSubtotal := parseAmount(t, "SubTotal", cfdi.SubTotal)
+			gotVAT := parseAmount(t, "TotalImpuestosTrasladados", cfdi.Impuestos.TotalImpuestosTrasladados)
+			if gotSubtotal+gotVAT != total {
+				t.Fatalf("SubTotal %s + IVA %s != Total %s", gotSubtotal, gotVAT, total)
+			}
+			if cfdi.Moneda != models.DefaultCurrency {
+				t.Fatalf("Moneda = %q, want %q", cfdi.Moneda, models.DefaultCurrency)
+			}
+
+			if len(cfdi.Conceptos) != 1 {
+				t.Fatalf("got %d conceptos, want 1", len(cfdi.Conceptos))
+			}
+			concepto := cfdi.Conceptos[0]
+			if parseAmount(t, "Importe", concepto.Importe) != gotSubtotal ||
+				parseAmount(t, "ValorUnitario", concepto.ValorUnitario) != gotSubtotal {
+				t.Fatalf("concepto %s x %s does not match SubTotal %s", concepto.ValorUnitario, concepto.Importe, gotSubtotal)
+			}
+
+			traslados := append(concepto.Traslados, cfdi.Impuestos.Traslados...)
+			if len(traslados) != 2 {
+				t.Fatalf("got %d traslados, want one per concepto and one in the summary", len(traslados))
+			}
+			for _, traslado := range traslados {
+				if parseAmount(t, "Base", traslado.Base) != gotSubtotal || parseAmount(t, "Importe", traslado.Importe) != gotVAT {
+					t.Fatalf("traslado base %s, importe %s, want %s, %s", traslado.Base, traslado.Importe, gotSubtotal, gotVAT)
+				}
+				if traslado.TasaOCuota != cfdiVATRate {
+					t.Fatalf("TasaOCuota = %q, want %q", traslado.TasaOCuota, cfdiVATRate)
+				}
+			}
+		})
+	}
+}
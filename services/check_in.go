@@ -0,0 +1,166 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// checkInOpensBefore is how early before the start a reservation accepts check-in
+const checkInOpensBefore = 30 * time.Minute
+
+// maxCheckInCodeAttempts is how many wrong codes a reservation takes before
+// its code is invalidated and a new one has to be generated
+const maxCheckInCodeAttempts = 5
+
+type CheckInService struct{}
+
+func NewCheckInService() *CheckInService {
+	return &CheckInService{}
+}
+
+// checkInCodeTTL is how long a generated check-in code stays valid,
+// configurable through CHECK_IN_CODE_MINUTES
+func checkInCodeTTL() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("CHECK_IN_CODE_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// GenerateCode creates a fresh six digit code the front desk gives to the
+// professional so they can check in from the app. Any previous code is replaced.
+func (s *CheckInService) GenerateCode(reservationID uint) (string, time.Time, error) {
+	reservation, err := s.checkInable(reservationID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	expiresAt := time.Now().Add(checkInCodeTTL())
+
+	if err := config.DB.Model(reservation).Updates(map[string]interface{}{
+		"check_in_code":            code,
+		"check_in_code_expires_at": expiresAt,
+		"check_in_code_attempts":   0,
+	}).Error; err != nil {
+		return "", time.Time{}, err
+	}
+
+	return code, expiresAt, nil
+}
+
+// CheckInWithCode checks in the professional's own reservation using the code
+// generated at the front desk. Every wrong code counts against the reservation,
+// and after maxCheckInCodeAttempts the code is invalidated.
+func (s *CheckInService) CheckInWithCode(reservationID, userID uint, code string) (*models.Reservation, error) {
+	reservation, err := s.checkInable(reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.UserID == nil || *reservation.UserID != userID {
+		return nil, errors.New("Reservación no encontrada")
+	}
+
+	var codeErr error
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so concurrent guesses are all counted
+		var current models.Reservation
+		if err := forUpdate(tx).First(&current, reservation.ID).Error; err != nil {
+			return errors.New("Reservación no encontrada")
+		}
+
+		if current.CheckInCode == "" {
+			codeErr = errors.New("Código de registro inválido")
+			return nil
+		}
+		if subtle.ConstantTimeCompare([]byte(current.CheckInCode), []byte(code)) != 1 {
+			attempts := current.CheckInCodeAttempts + 1
+			updates := map[string]interface{}{"check_in_code_attempts": attempts}
+			codeErr = errors.New("Código de registro inválido")
+			if attempts >= maxCheckInCodeAttempts {
+				updates["check_in_code"] = ""
+				updates["check_in_code_expires_at"] = nil
+				codeErr = errors.New("Demasiados intentos fallidos, solicite un nuevo código de registro")
+			}
+			return tx.Model(&current).Updates(updates).Error
+		}
+		if current.CheckInCodeExpiresAt == nil || time.Now().After(*current.CheckInCodeExpiresAt) {
+			codeErr = errors.New("El código de registro expiró, solicite uno nuevo")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if codeErr != nil {
+		return nil, codeErr
+	}
+
+	return s.checkIn(reservation, userID)
+}
+
+// AdminCheckIn records the professional's arrival directly
+func (s *CheckInService) AdminCheckIn(reservationID, adminID uint) (*models.Reservation, error) {
+	reservation, err := s.checkInable(reservationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkIn(reservation, adminID)
+}
+
+func (s *CheckInService) checkIn(reservation *models.Reservation, actorID uint) (*models.Reservation, error) {
+	now := time.Now()
+	if err := config.DB.Model(reservation).Updates(map[string]interface{}{
+		"checked_in_at":            now,
+		"checked_in_by":            actorID,
+		"check_in_code":            "",
+		"check_in_code_expires_at": nil,
+		"check_in_code_attempts":   0,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	reservation.CheckedInAt = &now
+	reservation.CheckedInBy = &actorID
+	return reservation, nil
+}
+
+// checkInable loads a confirmed reservation that is within its check-in window
+// and not checked in yet
+func (s *CheckInService) checkInable(reservationID uint) (*models.Reservation, error) {
+	var reservation models.Reservation
+	if err := config.DB.Preload("Space").First(&reservation, reservationID).Error; err != nil {
+		return nil, errors.New("Reservación no encontrada")
+	}
+
+	if reservation.Status != models.StatusConfirmed {
+		return nil, errors.New("Solo las reservaciones confirmadas admiten registro de llegada")
+	}
+	if reservation.CheckedInAt != nil {
+		return nil, errors.New("La llegada ya fue registrada")
+	}
+
+	now := time.Now()
+	if now.Before(reservation.StartTime.Add(-checkInOpensBefore)) {
+		return nil, errors.New("El registro de llegada abre 30 minutos antes del inicio")
+	}
+	if !now.Before(reservation.EndTime) {
+		return nil, errors.New("La reservación ya terminó")
+	}
+
+	return &reservation, nil
+}
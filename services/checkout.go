@@ -0,0 +1,319 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// onlinePaymentMethod is the payment method of payments made through the
+// gateway
+const onlinePaymentMethod = "online"
+
+// checkoutReconcileAfter is how long a checkout waits for its webhook before
+// the reconciliation job asks the gateway for its status
+const checkoutReconcileAfter = 10 * time.Minute
+
+var errGatewayNotConfigured = errors.New("El pago en línea no está disponible")
+
+type CheckoutService struct {
+	gateway        PaymentGateway
+	paymentService *PaymentService
+	creditService  *CreditService
+}
+
+func NewCheckoutService() *CheckoutService {
+	return &CheckoutService{
+		gateway:        NewPaymentGateway(),
+		paymentService: NewPaymentService(),
+		creditService:  NewCreditService(),
+	}
+}
+
+// Enabled reports whether a payment gateway is configured
+func (s *CheckoutService) Enabled() bool {
+	return s.gateway != nil
+}
+
+// FakeGateway returns the gateway when it is the fake one used in development
+func (s *CheckoutService) FakeGateway() (*FakeGateway, bool) {
+	fake, ok := s.gateway.(*FakeGateway)
+	return fake, ok
+}
+
+// StartCheckout opens a checkout at the gateway for a package or, without
+// one, for amount at the unit price
+func (s *CheckoutService) StartCheckout(userID uint, packageID *uint, amount models.Money) (*models.Checkout, error) {
+	if s.gateway == nil {
+		return nil, errGatewayNotConfigured
+	}
+
+	purchase, err := s.paymentService.resolvePurchase(packageID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	checkout := models.Checkout{
+		UserID:       userID,
+		PackageID:    packageID,
+		Credits:      purchase.Credits,
+		ValidityDays: purchase.ValidityDays,
+		Amount:       purchase.Amount,
+		Currency:     purchase.Currency,
+		Provider:     s.gateway.Name(),
+		Status:       models.CheckoutPending,
+	}
+	if err := config.DB.Create(&checkout).Error; err != nil {
+		return nil, err
+	}
+
+	opened, err := s.gateway.CreateCheckout(&checkout)
+	if err != nil {
+		log.Printf("Error al crear el checkout %d en la pasarela: %v", checkout.ID, err)
+		config.DB.Model(&checkout).Update("status", models.CheckoutFailed)
+		return nil, errors.New("No se pudo iniciar el pago en línea")
+	}
+
+	checkout.ProviderRef = &opened.Ref
+	checkout.CheckoutURL = opened.URL
+	if err := config.DB.Model(&checkout).Updates(map[string]interface{}{
+		"provider_ref": opened.Ref,
+		"checkout_url": opened.URL,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	checkout.Package = purchase.Package
+	return &checkout, nil
+}
+
+func (s *CheckoutService) GetUserCheckouts(userID uint) ([]models.Checkout, error) {
+	var checkouts []models.Checkout
+	err := config.DB.Preload("Package", withDeleted).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&checkouts).Error
+	return checkouts, err
+}
+
+// GetCheckout returns a checkout, which with userID set must be one of the
+// user's. A pending checkout is checked with the gateway first, in case its
+// webhook has not arrived.
+func (s *CheckoutService) GetCheckout(checkoutID, userID uint) (*models.Checkout, error) {
+	query := config.DB.Where("id = ?", checkoutID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var checkout models.Checkout
+	if err := query.First(&checkout).Error; err != nil {
+		return nil, errors.New("Pago en línea no encontrado")
+	}
+
+	if checkout.Status == models.CheckoutPending {
+		if err := s.refresh(&checkout); err != nil {
+			log.Printf("Error al consultar el checkout %d en la pasarela: %v", checkout.ID, err)
+		}
+	}
+
+	if err := config.DB.Preload("Package", withDeleted).Preload("Payment").First(&checkout, checkout.ID).Error; err != nil {
+		return nil, err
+	}
+	return &checkout, nil
+}
+
+// HandleWebhook verifies and applies a notification from the gateway. A
+// delivery of an event that was already applied is acknowledged and ignored.
+func (s *CheckoutService) HandleWebhook(payload []byte, header http.Header) error {
+	if s.gateway == nil {
+		return errGatewayNotConfigured
+	}
+
+	event, err := s.gateway.ParseWebhook(payload, header)
+	if err != nil {
+		return err
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		record := models.PaymentWebhookEvent{
+			Provider:    s.gateway.Name(),
+			EventID:     event.EventID,
+			Status:      string(event.Status),
+			Payload:     string(payload),
+			ProcessedAt: &now,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		checkout, err := s.apply(tx, event)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&record).Update("checkout_id", checkout.ID).Error
+	})
+}
+
+// ReconcileCheckouts asks the gateway for the status of checkouts whose
+// webhook has not arrived, so a lost notification does not leave a paid
+// purchase without its credits
+func (s *CheckoutService) ReconcileCheckouts() (int64, error) {
+	if s.gateway == nil {
+		return 0, nil
+	}
+
+	var checkouts []models.Checkout
+	if err := config.DB.Where("status = ? AND provider = ? AND provider_ref IS NOT NULL AND created_at <= ?",
+		models.CheckoutPending, s.gateway.Name(), time.Now().Add(-checkoutReconcileAfter)).
+		Find(&checkouts).Error; err != nil {
+		return 0, err
+	}
+
+	var settled int64
+	for i := range checkouts {
+		if err := s.refresh(&checkouts[i]); err != nil {
+			log.Printf("Error al conciliar el checkout %d: %v", checkouts[i].ID, err)
+			continue
+		}
+		if checkouts[i].Status != models.CheckoutPending {
+			settled++
+		}
+	}
+	return settled, nil
+}
+
+// refresh queries the gateway for a pending checkout and applies its status
+func (s *CheckoutService) refresh(checkout *models.Checkout) error {
+	if s.gateway == nil || checkout.ProviderRef == nil || checkout.Provider != s.gateway.Name() {
+		return nil
+	}
+
+	event, err := s.gateway.GetStatus(*checkout.ProviderRef)
+	if err != nil {
+		return err
+	}
+	if event.Status == models.CheckoutPending {
+		return nil
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		applied, err := s.apply(tx, event)
+		if err != nil {
+			return err
+		}
+		checkout.Status = applied.Status
+		return nil
+	})
+}
+
+// apply moves a checkout to the status reported by the gateway. A
+// paid checkout registers the payment, with no admin, and grants its lot. The
+// checkout row is locked, so a webhook and a status query racing each other
+// pay it only once.
+func (s *CheckoutService) apply(tx *gorm.DB, event *GatewayEvent) (*models.Checkout, error) {
+	var checkout models.Checkout
+	if err := forUpdate(tx).Where("provider = ? AND provider_ref = ?", s.gateway.Name(), event.CheckoutRef).
+		First(&checkout).Error; err != nil {
+		return nil, errors.New("Pago en línea no encontrado")
+	}
+	// A payment the provider confirms after reporting the checkout failed or
+	// expired is still delivered; anything else only moves pending checkouts
+	if checkout.Status == models.CheckoutPaid ||
+		(checkout.Status != models.CheckoutPending && event.Status != models.CheckoutPaid) {
+		return &checkout, nil
+	}
+
+	now := time.Now()
+	switch event.Status {
+	case models.CheckoutPaid:
+		if event.Amount != checkout.Amount || event.Currency != checkout.Currency {
+			return nil, fmt.Errorf("El monto pagado (%s %s) no coincide con el del pago en línea %d", event.Amount, event.Currency, checkout.ID)
+		}
+
+		payment := models.Payment{
+			UserID:         checkout.UserID,
+			Amount:         checkout.Amount,
+			Currency:       checkout.Currency,
+			CreditsGranted: checkout.Credits,
+			CreditCost:     checkout.Amount.Per(checkout.Credits),
+			PaymentMethod:  onlinePaymentMethod,
+			Reference:      event.Reference,
+			Notes:          "Pago en línea " + *checkout.ProviderRef,
+			PackageID:      checkout.PackageID,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return nil, err
+		}
+
+		reason := "Compra de créditos en línea"
+		if checkout.PackageID != nil {
+			var pkg models.CreditPackage
+			if err := tx.Unscoped().First(&pkg, *checkout.PackageID).Error; err == nil {
+				reason = "Compra en línea del paquete " + pkg.Name
+			}
+		}
+		if _, err := s.creditService.grantLot(tx, checkout.UserID, checkout.Credits, now.AddDate(0, 0, checkout.ValidityDays), CreditEntry{
+			Type:      models.TransactionTypePurchase,
+			Reason:    reason,
+			Notes:     event.Reference,
+			PaymentID: &payment.ID,
+		}); err != nil {
+			return nil, err
+		}
+
+		checkout.PaymentID = &payment.ID
+	case models.CheckoutFailed, models.CheckoutExpired:
+		// Nothing was charged, only the status changes
+	default:
+		return &checkout, nil
+	}
+
+	checkout.Status = event.Status
+	checkout.CompletedAt = &now
+	if err := tx.Model(&checkout).Updates(map[string]interface{}{
+		"status":       checkout.Status,
+		"payment_id":   checkout.PaymentID,
+		"completed_at": checkout.CompletedAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &checkout, nil
+}
+
+// SimulatePayment settles a checkout at the fake gateway and delivers the
+// resulting webhook, for trying the online flow in development
+func (s *CheckoutService) SimulatePayment(checkoutID uint, status models.CheckoutStatus) (*models.Checkout, error) {
+	fake, ok := s.FakeGateway()
+	if !ok {
+		return nil, errGatewayNotConfigured
+	}
+
+	var checkout models.Checkout
+	if err := config.DB.First(&checkout, checkoutID).Error; err != nil {
+		return nil, errors.New("Pago en línea no encontrado")
+	}
+	if checkout.ProviderRef == nil {
+		return nil, errors.New("El pago en línea no se inició en la pasarela")
+	}
+
+	payload, header, err := fake.SimulatePayment(*checkout.ProviderRef, status)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.HandleWebhook(payload, header); err != nil {
+		return nil, err
+	}
+	return s.GetCheckout(checkoutID, 0)
+}
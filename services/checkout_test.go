@@ -0,0 +1,150 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+const testWebhookSecret = "test-secret"
+
+func fakeWebhookPayload(t *testing.T, event fakeGatewayEvent) []byte {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return payload
+}
+
+func signedHeader(gateway *FakeGateway, payload []byte) http.Header {
+	header := http.Header{}
+	header.Set(fakeSignatureHeader, gateway.Sign(payload))
+	return header
+}
+
+func TestFakeGatewayParseWebhook(t *testing.T) {
+	gateway := NewFakeGateway(testWebhookSecret)
+	payload := fakeWebhookPayload(t, fakeGatewayEvent{
+		ID:        "evt_1",
+		Checkout:  "fake_cs_1",
+		Status:    models.CheckoutPaid,
+		Amount:    10000,
+		Currency:  models.DefaultCurrency,
+		Reference: "fake_pay_1",
+	})
+
+	event, err := gateway.ParseWebhook(payload, signedHeader(gateway, payload))
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+	want := GatewayEvent{
+		EventID:     "evt_1",
+		CheckoutRef: "fake_cs_1",
+		Status:      models.CheckoutPaid,
+		Amount:      10000,
+		Currency:    models.DefaultCurrency,
+		Reference:   "fake_pay_1",
+	}
+	if *event != want {
+		t.Fatalf("ParseWebhook() = %+v, want %+v", *event, want)
+	}
+}
+
+func TestFakeGatewayParseWebhookRejectsInvalidSignature(t *testing.T) {
+	gateway := NewFakeGateway(testWebhookSecret)
+	payload := fakeWebhookPayload(t, fakeGatewayEvent{ID: "evt_1", Checkout: "fake_cs_1", Status: models.CheckoutFailed})
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  http.Header
+	}{
+		{"missing signature", payload, http.Header{}},
+		{"not hex", payload, http.Header{fakeSignatureHeader: {"zz"}}},
+		{"other secret", payload, signedHeader(NewFakeGateway("other-secret"), payload)},
+		{"tampered payload", []byte(strings.Replace(string(payload), "failed", "paid", 1)), signedHeader(gateway, payload)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := gateway.ParseWebhook(tt.payload, tt.header); !errors.Is(err, ErrInvalidWebhookSignature) {
+				t.Fatalf("ParseWebhook() = %v, want %v", err, ErrInvalidWebhookSignature)
+			}
+		})
+	}
+}
+
+// A signed but malformed event is not a signature problem
+func TestFakeGatewayParseWebhookRejectsIncompleteEvent(t *testing.T) {
+	gateway := NewFakeGateway(testWebhookSecret)
+
+	for _, payload := range [][]byte{
+		[]byte("not json"),
+		fakeWebhookPayload(t, fakeGatewayEvent{Checkout: "fake_cs_1", Status: models.CheckoutPaid}),
+		fakeWebhookPayload(t, fakeGatewayEvent{ID: "evt_1", Status: models.CheckoutPaid}),
+	} {
+		_, err := gateway.ParseWebhook(payload, signedHeader(gateway, payload))
+		if err == nil || errors.Is(err, ErrInvalidWebhookSignature) {
+			t.Fatalf("ParseWebhook(%s) = %v, want an invalid event error", payload, err)
+		}
+	}
+}
+
+// A provider resends an event until it is acknowledged, sometimes several at
+// once; only the first delivery may grant the credits
+func TestHandleWebhookAppliesDuplicateOnce(t *testing.T) {
+	db := useTestDB(t)
+	t.Setenv("CREDIT_UNIT_PRICE", "100.00")
+	ana := createTestUser(t, db, "Ana")
+
+	gateway := NewFakeGateway(testWebhookSecret)
+	service := &CheckoutService{gateway: gateway, paymentService: NewPaymentService(), creditService: NewCreditService()}
+	checkout, err := service.StartCheckout(ana.ID, nil, 50000)
+	if err != nil {
+		t.Fatalf("StartCheckout: %v", err)
+	}
+	payload, header, err := gateway.SimulatePayment(*checkout.ProviderRef, models.CheckoutPaid)
+	if err != nil {
+		t.Fatalf("SimulatePayment: %v", err)
+	}
+
+	const deliveries = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, deliveries)
+	for i := 0; i < deliveries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- service.HandleWebhook(payload, header)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("HandleWebhook: %v", err)
+		}
+	}
+
+	var events, payments int64
+	db.Model(&models.PaymentWebhookEvent{}).Count(&events)
+	db.Model(&models.Payment{}).Where("user_id = ?", ana.ID).Count(&payments)
+	if events != 1 || payments != 1 {
+		t.Fatalf("recorded %d events and %d payments, want 1 and 1", events, payments)
+	}
+	requireBalance(t, ana.ID, 5)
+
+	paid, err := service.GetCheckout(checkout.ID, ana.ID)
+	if err != nil {
+		t.Fatalf("GetCheckout: %v", err)
+	}
+	if paid.Status != models.CheckoutPaid || paid.PaymentID == nil {
+		t.Fatalf("checkout status = %s, payment = %v; want paid with a payment", paid.Status, paid.PaymentID)
+	}
+}
@@ -2,67 +2,161 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/IkingariSolorzano/omma-be/config"
 	"github.com/IkingariSolorzano/omma-be/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// defaultCreditValidityDays is how long a newly granted lot stays spendable
+const defaultCreditValidityDays = 30
+
 type CreditService struct{}
 
 func NewCreditService() *CreditService {
 	return &CreditService{}
 }
 
-func (s *CreditService) AddCredits(userID uint, amount int, reason string, reservationId uint, notes string) (*models.Credit, error) {
-	if amount <= 0 {
-		return nil, errors.New("El monto de créditos debe ser positivo")
+// CreditEntry describes why credits moved and who moved them. It is copied to
+// every ledger entry the movement posts.
+type CreditEntry struct {
+	Type          models.TransactionType
+	Reason        string
+	Notes         string
+	AdminID       *uint
+	ReservationID *uint
+	PaymentID     *uint
+}
+
+// forUpdate locks the lots read through it until the surrounding transaction
+// ends, so concurrent movements on the same lots run one after the other
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// post appends a ledger entry for a change of amount credits in a lot
+func (s *CreditService) post(tx *gorm.DB, userID, creditID uint, amount int, entry CreditEntry) error {
+	return tx.Create(&models.CreditTransaction{
+		UserID:        userID,
+		Amount:        amount,
+		Type:          entry.Type,
+		Reason:        entry.Reason,
+		Notes:         entry.Notes,
+		CreditID:      &creditID,
+		AdminID:       entry.AdminID,
+		ReservationID: entry.ReservationID,
+		PaymentID:     entry.PaymentID,
+	}).Error
+}
+
+// grantLot creates a new credit lot inside tx and posts it to the ledger
+func (s *CreditService) grantLot(tx *gorm.DB, userID uint, amount int, expiry time.Time, entry CreditEntry) (*models.Credit, error) {
+	credit := models.Credit{
+		UserID:       userID,
+		Amount:       amount,
+		PurchaseDate: time.Now(),
+		ExpiryDate:   expiry,
+		IsActive:     true,
+	}
+	if err := tx.Create(&credit).Error; err != nil {
+		return nil, err
 	}
 
-	var credit models.Credit
-	err := config.DB.Transaction(func(tx *gorm.DB) error {
-		credit = models.Credit{
-			UserID:       userID,
-			Amount:       amount,
-			PurchaseDate: time.Now(),
-			ExpiryDate:   time.Now().AddDate(0, 0, 30), // 30 days from now
-			IsActive:     true,
-		}
+	if err := s.post(tx, userID, credit.ID, amount, entry); err != nil {
+		return nil, err
+	}
+	return &credit, nil
+}
 
-		if err := tx.Create(&credit).Error; err != nil {
-			return err
-		}
+// deductFIFO takes amount credits from the user's active lots inside tx,
+// soonest to expire first, posting one ledger entry per lot touched. The lots
+// are locked first so the balance cannot be spent twice by concurrent calls.
+func (s *CreditService) deductFIFO(tx *gorm.DB, userID uint, amount int, entry CreditEntry) error {
+	var credits []models.Credit
+	if err := forUpdate(tx).Where("user_id = ? AND is_active = ? AND expiry_date > ?", userID, true, time.Now()).
+		Order("expiry_date ASC").
+		Find(&credits).Error; err != nil {
+		return err
+	}
 
-		transaction := models.CreditTransaction{
-			UserID:        userID,
-			Amount:        amount,
-			Type:          models.TransactionTypeRefund,
-			Reason:        reason,
-			Notes:         notes,
-		}
+	totalAvailable := 0
+	for _, credit := range credits {
+		totalAvailable += credit.Amount
+	}
+	held, err := s.heldCredits(tx, userID)
+	if err != nil {
+		return err
+	}
+	if totalAvailable-held < amount {
+		return errors.New("Créditos insuficientes")
+	}
 
-		if reservationId > 0 {
-			transaction.ReservationID = &reservationId
+	remaining := amount
+	for i := range credits {
+		if remaining <= 0 {
+			break
 		}
 
-		if err := tx.Create(&transaction).Error; err != nil {
+		taken := credits[i].Amount
+		if taken > remaining {
+			taken = remaining
+		}
+		if taken == 0 {
+			continue
+		}
+		if err := s.takeFromLot(tx, &credits[i], taken, entry); err != nil {
 			return err
 		}
+		remaining -= taken
+	}
 
-		return nil
-	})
+	return nil
+}
+
+// takeFromLot removes amount credits from a lot inside tx, deactivating it
+// when it runs out, and posts the ledger entry
+func (s *CreditService) takeFromLot(tx *gorm.DB, credit *models.Credit, amount int, entry CreditEntry) error {
+	credit.Amount -= amount
+	if credit.Amount == 0 {
+		credit.IsActive = false
+	}
+	if err := tx.Save(credit).Error; err != nil {
+		return err
+	}
+	return s.post(tx, credit.UserID, credit.ID, -amount, entry)
+}
 
+// AddCredits grants a new lot to the user. entry.Type defaults to a refund,
+// which is what reservations use it for. It runs inside db, so pass the
+// caller's transaction to commit the grant together with the caller's changes,
+// or config.DB to run it on its own.
+func (s *CreditService) AddCredits(db *gorm.DB, userID uint, amount int, entry CreditEntry) (*models.Credit, error) {
+	if amount <= 0 {
+		return nil, errors.New("El monto de créditos debe ser positivo")
+	}
+	if entry.Type == "" {
+		entry.Type = models.TransactionTypeRefund
+	}
+
+	var credit *models.Credit
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		credit, err = s.grantLot(tx, userID, amount, time.Now().AddDate(0, 0, defaultCreditValidityDays), entry)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &credit, nil
+	return credit, nil
 }
 
 func (s *CreditService) GetActiveCredits(userID uint) (int, error) {
 	var totalCredits int64
-	
+
 	err := config.DB.Model(&models.Credit{}).
 		Where("user_id = ? AND is_active = ? AND expiry_date > ?", userID, true, time.Now()).
 		Select("COALESCE(SUM(amount), 0)").
@@ -75,59 +169,175 @@ func (s *CreditService) GetActiveCredits(userID uint) (int, error) {
 	return int(totalCredits), nil
 }
 
-func (s *CreditService) DeductCredits(userID uint, amount int) error {
+// DeductCredits charges the user, oldest lots first. entry.Type defaults to a
+// reservation deduction. Like AddCredits it runs inside db; either every lot
+// is deducted or none is.
+func (s *CreditService) DeductCredits(db *gorm.DB, userID uint, amount int, entry CreditEntry) error {
 	if amount <= 0 {
 		return errors.New("El monto de la deducción debe ser positivo")
 	}
+	if entry.Type == "" {
+		entry.Type = models.TransactionTypeDeduction
+	}
 
-	// Get active credits ordered by expiry date (FIFO)
-	var credits []models.Credit
-	err := config.DB.Where("user_id = ? AND is_active = ? AND expiry_date > ?", 
-		userID, true, time.Now()).
-		Order("expiry_date ASC").
-		Find(&credits).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		return s.deductFIFO(tx, userID, amount, entry)
+	})
+}
 
+// CreditBalance splits a user's spendable credits into those set aside for
+// reservations pending approval and those still available
+type CreditBalance struct {
+	Available int `json:"available"`
+	Held      int `json:"held"`
+	Total     int `json:"total"`
+}
+
+func (s *CreditService) GetBalance(userID uint) (*CreditBalance, error) {
+	total, err := s.GetActiveCredits(userID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	held, err := s.heldCredits(config.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &CreditBalance{Available: total - held, Held: held, Total: total}, nil
+}
+
+// GetAvailableCredits is the user's active credits minus the held ones
+func (s *CreditService) GetAvailableCredits(userID uint) (int, error) {
+	balance, err := s.GetBalance(userID)
+	if err != nil {
+		return 0, err
 	}
+	return balance.Available, nil
+}
 
-	totalAvailable := 0
+// GetActiveHolds lists the holds of the user's reservations pending approval
+func (s *CreditService) GetActiveHolds(userID uint) ([]models.CreditHold, error) {
+	holds := []models.CreditHold{}
+	err := config.DB.Where("user_id = ? AND status = ?", userID, models.HoldActive).
+		Order("created_at ASC").
+		Find(&holds).Error
+	return holds, err
+}
+
+// heldCredits sums the user's active holds
+func (s *CreditService) heldCredits(db *gorm.DB, userID uint) (int, error) {
+	var held int64
+	err := db.Model(&models.CreditHold{}).
+		Where("user_id = ? AND status = ?", userID, models.HoldActive).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&held).Error
+	return int(held), err
+}
+
+// lockedAvailable locks the user's lots and returns the credits available
+// outside of the active holds
+func (s *CreditService) lockedAvailable(tx *gorm.DB, userID uint) (int, error) {
+	var credits []models.Credit
+	if err := forUpdate(tx).Where("user_id = ? AND is_active = ? AND expiry_date > ?", userID, true, time.Now()).
+		Find(&credits).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0
 	for _, credit := range credits {
-		totalAvailable += credit.Amount
+		total += credit.Amount
+	}
+	held, err := s.heldCredits(tx, userID)
+	if err != nil {
+		return 0, err
 	}
+	return total - held, nil
+}
 
-	if totalAvailable < amount {
+// checkAvailable locks the user's lots and fails unless amount credits are
+// available outside of the active holds
+func (s *CreditService) checkAvailable(tx *gorm.DB, userID uint, amount int) error {
+	available, err := s.lockedAvailable(tx, userID)
+	if err != nil {
+		return err
+	}
+	if available < amount {
 		return errors.New("Créditos insuficientes")
 	}
+	return nil
+}
 
-	// Deduct credits starting from oldest
-	remaining := amount
-	for i := range credits {
-		if remaining <= 0 {
-			break
-		}
+// PlaceHold sets aside amount credits for a reservation pending approval. It
+// runs inside db like DeductCredits and fails when the credits are not
+// available. The held credits can still expire with their lots before the
+// reservation is approved.
+func (s *CreditService) PlaceHold(db *gorm.DB, userID, reservationID uint, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
 
-		if credits[i].Amount <= remaining {
-			// Use all credits from this record
-			remaining -= credits[i].Amount
-			credits[i].Amount = 0
-			credits[i].IsActive = false
-		} else {
-			// Partial deduction
-			credits[i].Amount -= remaining
-			remaining = 0
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkAvailable(tx, userID, amount); err != nil {
+			return err
 		}
+		return tx.Create(&models.CreditHold{
+			UserID:        userID,
+			ReservationID: reservationID,
+			Amount:        amount,
+			Status:        models.HoldActive,
+		}).Error
+	})
+}
+
+// ResolveHold closes the reservation's active hold, if any, as captured or
+// released. Capturing does not charge anything by itself: the caller deducts
+// the credits in the same transaction, after the hold stops counting.
+func (s *CreditService) ResolveHold(db *gorm.DB, reservationID uint, status models.HoldStatus) error {
+	return db.Model(&models.CreditHold{}).
+		Where("reservation_id = ? AND status = ?", reservationID, models.HoldActive).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"resolved_at": time.Now(),
+		}).Error
+}
 
-		config.DB.Save(&credits[i])
+// ExpireCredits deactivates every credit lot past its expiry date and returns
+// how many lots were expired
+func (s *CreditService) ExpireCredits() (int64, error) {
+	var credits []models.Credit
+	if err := config.DB.Where("expiry_date <= ? AND is_active = ?", time.Now(), true).
+		Find(&credits).Error; err != nil {
+		return 0, err
 	}
 
-	return nil
-}
+	var expired int64
+	for i := range credits {
+		credit := &credits[i]
+		deactivated := false
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.Credit{}).
+				Where("id = ? AND is_active = ?", credit.ID, true).
+				Update("is_active", false)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			deactivated = true
+			if credit.Amount == 0 {
+				return nil
+			}
+			return s.post(tx, credit.UserID, credit.ID, -credit.Amount, CreditEntry{
+				Type:   models.TransactionTypeExpiration,
+				Reason: "Créditos vencidos",
+			})
+		})
+		if err != nil {
+			return expired, err
+		}
+		if deactivated {
+			expired++
+		}
+	}
 
-func (s *CreditService) ExpireCredits() error {
-	return config.DB.Model(&models.Credit{}).
-		Where("expiry_date <= ? AND is_active = ?", time.Now(), true).
-		Update("is_active", false).Error
+	return expired, nil
 }
 
 func (s *CreditService) GetUserCredits(userID uint) ([]models.Credit, error) {
@@ -135,214 +345,266 @@ func (s *CreditService) GetUserCredits(userID uint) ([]models.Credit, error) {
 	err := config.DB.Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Find(&credits).Error
-	
+
 	return credits, err
 }
 
 func (s *CreditService) GetUserCreditCounts(userID uint) (int, int) {
 	var activeCredits, totalCredits int
-	
+
 	// Count active credits
 	config.DB.Model(&models.Credit{}).
 		Where("user_id = ? AND is_active = ? AND amount > 0", userID, true).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&activeCredits)
-	
+
 	// Count total credits
 	config.DB.Model(&models.Credit{}).
 		Where("user_id = ?", userID).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalCredits)
-	
+
 	return activeCredits, totalCredits
 }
 
 // ExtendExpiry extends the expiry date of all active credits for a user by the given number of days
-func (s *CreditService) ExtendExpiry(userID uint, days int) error {
-    if days <= 0 {
-        return errors.New("Los días a extender deben ser positivos")
-    }
-
-    var credits []models.Credit
-    if err := config.DB.Where("user_id = ? AND is_active = ? AND amount > 0", userID, true).
-        Find(&credits).Error; err != nil {
-        return err
-    }
-    for i := range credits {
-        credits[i].ExpiryDate = credits[i].ExpiryDate.AddDate(0, 0, days)
-        if err := config.DB.Save(&credits[i]).Error; err != nil {
-            return err
-        }
-    }
-    return nil
-}
-
-// ReactivateExpired reactivates all expired (inactive) credits with a new expiry date
-func (s *CreditService) ReactivateExpired(userID uint, newExpiry time.Time) (int64, error) {
-	tx := config.DB.Model(&models.Credit{}).
-		Where("user_id = ? AND is_active = ? AND amount > 0", userID, false).
-		Updates(map[string]interface{}{
-			"is_active":   true,
-			"expiry_date": newExpiry,
-		})
-	return tx.RowsAffected, tx.Error
-}
-
-// TransferCredits deducts from origin and creates a new credit lot for the destination user
-func (s *CreditService) TransferCredits(fromUserID, toUserID uint, amount int) error {
-    if amount <= 0 {
-        return errors.New("El monto debe ser positivo")
-    }
-    if fromUserID == toUserID {
-        return errors.New("No se puede transferir al mismo usuario")
-    }
+func (s *CreditService) ExtendExpiry(userID uint, days int, adminID uint) error {
+	if days <= 0 {
+		return errors.New("Los días a extender deben ser positivos")
+	}
 
 	return config.DB.Transaction(func(tx *gorm.DB) error {
-		// Deduct from origin
-		// Load active credits FIFO within the transaction context
 		var credits []models.Credit
-		if err := tx.Where("user_id = ? AND is_active = ? AND expiry_date > ?", fromUserID, true, time.Now()).
-			Order("expiry_date ASC").
+		if err := forUpdate(tx).Where("user_id = ? AND is_active = ? AND amount > 0", userID, true).
 			Find(&credits).Error; err != nil {
 			return err
 		}
-
-		totalAvailable := 0
-		for _, c := range credits {
-			totalAvailable += c.Amount
-		}
-		if totalAvailable < amount {
-			return errors.New("Creditos insuficientes para transferir")
+		for i := range credits {
+			if err := s.extendLot(tx, &credits[i], days, adminID); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+}
+
+func (s *CreditService) extendLot(tx *gorm.DB, credit *models.Credit, days int, adminID uint) error {
+	credit.ExpiryDate = credit.ExpiryDate.AddDate(0, 0, days)
+	if err := tx.Save(credit).Error; err != nil {
+		return err
+	}
+	return s.post(tx, credit.UserID, credit.ID, 0, CreditEntry{
+		Type:    models.TransactionTypeExtension,
+		Reason:  fmt.Sprintf("Vigencia extendida %d días", days),
+		Notes:   "Nueva fecha de expiración: " + credit.ExpiryDate.Format("2006-01-02"),
+		AdminID: &adminID,
+	})
+}
 
-		remaining := amount
+// ReactivateExpired reactivates all expired (inactive) credits with a new expiry date
+func (s *CreditService) ReactivateExpired(userID uint, newExpiry time.Time, adminID uint) (int64, error) {
+	var reactivated int64
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var credits []models.Credit
+		if err := forUpdate(tx).Where("user_id = ? AND is_active = ? AND amount > 0", userID, false).
+			Find(&credits).Error; err != nil {
+			return err
+		}
 		for i := range credits {
-			if remaining <= 0 {
-				break
-			}
-			if credits[i].Amount <= remaining {
-				remaining -= credits[i].Amount
-				credits[i].Amount = 0
-				credits[i].IsActive = false
-			} else {
-				credits[i].Amount -= remaining
-				remaining = 0
-			}
-			if err := tx.Save(&credits[i]).Error; err != nil {
+			if err := s.reactivateLot(tx, &credits[i], newExpiry, adminID); err != nil {
 				return err
 			}
 		}
+		reactivated = int64(len(credits))
+		return nil
+	})
+	return reactivated, err
+}
 
-		// Credit the destination user as a new lot with default 30 days expiry
-		newCredit := models.Credit{
-			UserID:       toUserID,
-			Amount:       amount,
-			PurchaseDate: time.Now(),
-			ExpiryDate:   time.Now().AddDate(0, 0, 30),
-			IsActive:     true,
-		}
-		if err := tx.Create(&newCredit).Error; err != nil {
+func (s *CreditService) reactivateLot(tx *gorm.DB, credit *models.Credit, newExpiry time.Time, adminID uint) error {
+	credit.IsActive = true
+	credit.ExpiryDate = newExpiry
+	if err := tx.Save(credit).Error; err != nil {
+		return err
+	}
+	return s.post(tx, credit.UserID, credit.ID, credit.Amount, CreditEntry{
+		Type:    models.TransactionTypeReactivation,
+		Reason:  "Créditos reactivados",
+		Notes:   "Nueva fecha de expiración: " + newExpiry.Format("2006-01-02"),
+		AdminID: &adminID,
+	})
+}
+
+// TransferCredits deducts from origin and creates a new credit lot for the destination user
+func (s *CreditService) TransferCredits(fromUserID, toUserID uint, amount int, adminID uint) error {
+	if amount <= 0 {
+		return errors.New("El monto debe ser positivo")
+	}
+	if fromUserID == toUserID {
+		return errors.New("No se puede transferir al mismo usuario")
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.deductFIFO(tx, fromUserID, amount, CreditEntry{
+			Type:    models.TransactionTypeTransferOut,
+			Reason:  "Transferencia de créditos",
+			Notes:   fmt.Sprintf("Transferidos al usuario ID: %d", toUserID),
+			AdminID: &adminID,
+		}); err != nil {
 			return err
 		}
-		return nil
+
+		// Credit the destination user as a new lot with default expiry
+		_, err := s.grantLot(tx, toUserID, amount, time.Now().AddDate(0, 0, defaultCreditValidityDays), CreditEntry{
+			Type:    models.TransactionTypeTransferIn,
+			Reason:  "Transferencia de créditos",
+			Notes:   fmt.Sprintf("Recibidos del usuario ID: %d", fromUserID),
+			AdminID: &adminID,
+		})
+		return err
 	})
 }
 
 // AdminDeduct allows an admin to deduct credits directly from a user
-func (s *CreditService) AdminDeduct(userID uint, amount int) error {
-    return s.DeductCredits(userID, amount)
+func (s *CreditService) AdminDeduct(userID uint, amount int, adminID uint) error {
+	return s.DeductCredits(config.DB, userID, amount, CreditEntry{
+		Type:    models.TransactionTypeAdjustment,
+		Reason:  "Créditos deducidos por administrador",
+		AdminID: &adminID,
+	})
 }
 
 // ExtendCreditLot extends expiry for a specific credit lot
-func (s *CreditService) ExtendCreditLot(creditID uint, days int) error {
-    if days <= 0 {
-        return errors.New("Los días a extender deben ser positivos")
-    }
-    var credit models.Credit
-    if err := config.DB.First(&credit, creditID).Error; err != nil {
-        return err
-    }
-    if !credit.IsActive || credit.Amount <= 0 {
-        return errors.New("El lote no está activo o no tiene créditos disponibles")
-    }
-    credit.ExpiryDate = credit.ExpiryDate.AddDate(0, 0, days)
-    return config.DB.Save(&credit).Error
+func (s *CreditService) ExtendCreditLot(creditID uint, days int, adminID uint) error {
+	if days <= 0 {
+		return errors.New("Los días a extender deben ser positivos")
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var credit models.Credit
+		if err := forUpdate(tx).First(&credit, creditID).Error; err != nil {
+			return err
+		}
+		if !credit.IsActive || credit.Amount <= 0 {
+			return errors.New("El lote no está activo o no tiene créditos disponibles")
+		}
+		return s.extendLot(tx, &credit, days, adminID)
+	})
 }
 
 // ReactivateCreditLot reactivates a specific expired credit lot with a new expiry date
-func (s *CreditService) ReactivateCreditLot(creditID uint, newExpiry time.Time) error {
-    var credit models.Credit
-    if err := config.DB.First(&credit, creditID).Error; err != nil {
-        return err
-    }
-    if credit.IsActive {
-        return errors.New("El lote ya está activo")
-    }
-    if credit.Amount <= 0 {
-        return errors.New("El lote no tiene créditos disponibles")
-    }
-    credit.IsActive = true
-    credit.ExpiryDate = newExpiry
-    return config.DB.Save(&credit).Error
+func (s *CreditService) ReactivateCreditLot(creditID uint, newExpiry time.Time, adminID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var credit models.Credit
+		if err := forUpdate(tx).First(&credit, creditID).Error; err != nil {
+			return err
+		}
+		if credit.IsActive {
+			return errors.New("El lote ya está activo")
+		}
+		if credit.Amount <= 0 {
+			return errors.New("El lote no tiene créditos disponibles")
+		}
+		return s.reactivateLot(tx, &credit, newExpiry, adminID)
+	})
+}
+
+// spendableLot loads a lot that has at least amount unexpired credits
+func (s *CreditService) spendableLot(tx *gorm.DB, creditID uint, amount int) (*models.Credit, error) {
+	var credit models.Credit
+	if err := forUpdate(tx).First(&credit, creditID).Error; err != nil {
+		return nil, err
+	}
+	if !credit.IsActive || credit.ExpiryDate.Before(time.Now()) {
+		return nil, errors.New("El lote no está activo o ya expiró")
+	}
+	if credit.Amount < amount {
+		return nil, errors.New("Créditos insuficientes en el lote")
+	}
+	if err := s.checkAvailable(tx, credit.UserID, amount); err != nil {
+		return nil, err
+	}
+	return &credit, nil
 }
 
 // AdminDeductFromLot deducts credits from a specific lot
-func (s *CreditService) AdminDeductFromLot(creditID uint, amount int) error {
-    if amount <= 0 {
-        return errors.New("El monto de la deducción debe ser positivo")
-    }
-    var credit models.Credit
-    if err := config.DB.First(&credit, creditID).Error; err != nil {
-        return err
-    }
-    if !credit.IsActive || credit.ExpiryDate.Before(time.Now()) {
-        return errors.New("El lote no está activo o ya expiró")
-    }
-    if credit.Amount < amount {
-        return errors.New("Créditos insuficientes en el lote")
-    }
-    credit.Amount -= amount
-    if credit.Amount == 0 {
-        credit.IsActive = false
-    }
-    return config.DB.Save(&credit).Error
+func (s *CreditService) AdminDeductFromLot(creditID uint, amount int, adminID uint) error {
+	if amount <= 0 {
+		return errors.New("El monto de la deducción debe ser positivo")
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		credit, err := s.spendableLot(tx, creditID, amount)
+		if err != nil {
+			return err
+		}
+		return s.takeFromLot(tx, credit, amount, CreditEntry{
+			Type:    models.TransactionTypeAdjustment,
+			Reason:  "Créditos deducidos del lote por administrador",
+			AdminID: &adminID,
+		})
+	})
 }
 
 // TransferFromLot transfers credits from a specific lot to another user
-func (s *CreditService) TransferFromLot(creditID, toUserID uint, amount int) error {
-    if amount <= 0 {
-        return errors.New("El monto debe ser positivo")
-    }
-    return config.DB.Transaction(func(tx *gorm.DB) error {
-        var credit models.Credit
-        if err := tx.First(&credit, creditID).Error; err != nil {
-            return err
-        }
-        if !credit.IsActive || credit.ExpiryDate.Before(time.Now()) {
-            return errors.New("El lote no está activo o ya expiró")
-        }
-        if credit.Amount < amount {
-            return errors.New("Créditos insuficientes en el lote")
-        }
-        // Deduct from lot
-        credit.Amount -= amount
-        if credit.Amount == 0 {
-            credit.IsActive = false
-        }
-        if err := tx.Save(&credit).Error; err != nil {
-            return err
-        }
-        // Create destination lot (30 days by default)
-        newCredit := models.Credit{
-            UserID:       toUserID,
-            Amount:       amount,
-            PurchaseDate: time.Now(),
-            ExpiryDate:   time.Now().AddDate(0, 0, 30),
-            IsActive:     true,
-        }
-        if err := tx.Create(&newCredit).Error; err != nil {
-            return err
-        }
-        return nil
-    })
+func (s *CreditService) TransferFromLot(creditID, toUserID uint, amount int, adminID uint) error {
+	if amount <= 0 {
+		return errors.New("El monto debe ser positivo")
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		credit, err := s.spendableLot(tx, creditID, amount)
+		if err != nil {
+			return err
+		}
+		if credit.UserID == toUserID {
+			return errors.New("No se puede transferir al mismo usuario")
+		}
+
+		if err := s.takeFromLot(tx, credit, amount, CreditEntry{
+			Type:    models.TransactionTypeTransferOut,
+			Reason:  "Transferencia de créditos desde lote",
+			Notes:   fmt.Sprintf("Transferidos al usuario ID: %d", toUserID),
+			AdminID: &adminID,
+		}); err != nil {
+			return err
+		}
+
+		// Create destination lot with the default expiry
+		_, err = s.grantLot(tx, toUserID, amount, time.Now().AddDate(0, 0, defaultCreditValidityDays), CreditEntry{
+			Type:    models.TransactionTypeTransferIn,
+			Reason:  "Transferencia de créditos desde lote",
+			Notes:   fmt.Sprintf("Recibidos del usuario ID: %d", credit.UserID),
+			AdminID: &adminID,
+		})
+		return err
+	})
+}
+
+// GetLedger returns the user's ledger entries up to asOf (all of them when
+// asOf is zero), newest first
+func (s *CreditService) GetLedger(userID uint, asOf time.Time) ([]models.CreditTransaction, error) {
+	query := config.DB.Preload("Admin").Where("user_id = ?", userID)
+	if !asOf.IsZero() {
+		query = query.Where("created_at <= ?", asOf)
+	}
+
+	var entries []models.CreditTransaction
+	err := query.Order("created_at DESC, id DESC").Find(&entries).Error
+	return entries, err
+}
+
+// BalanceAt derives the user's credit balance at the given moment from the
+// ledger. Legacy entries from before the ledger are not counted.
+func (s *CreditService) BalanceAt(userID uint, asOf time.Time) (int, error) {
+	var balance int64
+	err := config.DB.Model(&models.CreditTransaction{}).
+		Where("user_id = ? AND type <> ? AND created_at <= ?", userID, models.TransactionTypeLegacy, asOf).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return int(balance), nil
 }
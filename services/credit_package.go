@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+// creditUnitPrice is the price of a single credit for payments that do not
+// buy a package, configurable through CREDIT_UNIT_PRICE as a decimal amount
+func creditUnitPrice() models.Money {
+	if price, err := models.ParseMoney(os.Getenv("CREDIT_UNIT_PRICE")); err == nil && price > 0 {
+		return price
+	}
+	return 1000
+}
+
+type CreditPackageService struct{}
+
+func NewCreditPackageService() *CreditPackageService {
+	return &CreditPackageService{}
+}
+
+// GetPackages lists the catalog. Unless includeUnavailable is set, only the
+// packages that can be sold right now are returned.
+func (s *CreditPackageService) GetPackages(includeUnavailable bool) ([]models.CreditPackage, error) {
+	var packages []models.CreditPackage
+	if err := config.DB.Order("price_cents ASC").Find(&packages).Error; err != nil {
+		return nil, err
+	}
+	if includeUnavailable {
+		return packages, nil
+	}
+
+	now := time.Now()
+	available := []models.CreditPackage{}
+	for _, pkg := range packages {
+		if pkg.IsAvailable(now) {
+			available = append(available, pkg)
+		}
+	}
+	return available, nil
+}
+
+func (s *CreditPackageService) CreatePackage(pkg *models.CreditPackage) error {
+	if err := s.validatePackage(pkg); err != nil {
+		return err
+	}
+	pkg.Currency = models.DefaultCurrency
+	return config.DB.Create(pkg).Error
+}
+
+func (s *CreditPackageService) UpdatePackage(packageID uint, changes *models.CreditPackage) (*models.CreditPackage, error) {
+	var pkg models.CreditPackage
+	if err := config.DB.First(&pkg, packageID).Error; err != nil {
+		return nil, errors.New("Paquete no encontrado")
+	}
+
+	pkg.Name = changes.Name
+	pkg.Description = changes.Description
+	pkg.Price = changes.Price
+	pkg.Credits = changes.Credits
+	pkg.BonusCredits = changes.BonusCredits
+	pkg.ValidityDays = changes.ValidityDays
+	pkg.ActiveFrom = changes.ActiveFrom
+	pkg.ActiveUntil = changes.ActiveUntil
+	pkg.IsActive = changes.IsActive
+
+	if err := s.validatePackage(&pkg); err != nil {
+		return nil, err
+	}
+	if err := config.DB.Save(&pkg).Error; err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+// DeletePackage removes the package from the catalog; payments that bought it
+// keep their reference
+func (s *CreditPackageService) DeletePackage(packageID uint) error {
+	result := config.DB.Delete(&models.CreditPackage{}, packageID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Paquete no encontrado")
+	}
+	return nil
+}
+
+func (s *CreditPackageService) validatePackage(pkg *models.CreditPackage) error {
+	if strings.TrimSpace(pkg.Name) == "" {
+		return errors.New("El nombre del paquete es requerido")
+	}
+	if pkg.Price <= 0 {
+		return errors.New("El precio debe ser positivo")
+	}
+	if pkg.Credits <= 0 {
+		return errors.New("Los créditos del paquete deben ser positivos")
+	}
+	if pkg.BonusCredits < 0 {
+		return errors.New("Los créditos de bonificación no pueden ser negativos")
+	}
+	if pkg.ValidityDays <= 0 {
+		return errors.New("Los días de vigencia deben ser positivos")
+	}
+	if pkg.ActiveFrom != nil && pkg.ActiveUntil != nil && pkg.ActiveUntil.Before(*pkg.ActiveFrom) {
+		return errors.New("La fecha de fin debe ser posterior a la fecha de inicio")
+	}
+	return nil
+}
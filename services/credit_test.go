@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// Every movement posts one entry per lot it touches, so the ledger always
+// adds up to the credits in the lots
+func TestCreditMovementsPostToLedger(t *testing.T) {
+	db := useTestDB(t)
+	service := NewCreditService()
+	ana := createTestUser(t, db, "Ana")
+	beto := createTestUser(t, db, "Beto")
+
+	soon := grantTestCredits(t, db, ana.ID, 3, 5)
+	later := grantTestCredits(t, db, ana.ID, 10, 60)
+	requireBalance(t, ana.ID, 13)
+
+	if err := service.DeductCredits(db, ana.ID, 5, CreditEntry{Reason: "Reserva"}); err != nil {
+		t.Fatalf("DeductCredits: %v", err)
+	}
+	requireBalance(t, ana.ID, 8)
+
+	if err := service.TransferCredits(ana.ID, beto.ID, 2, ana.ID); err != nil {
+		t.Fatalf("TransferCredits: %v", err)
+	}
+	requireBalance(t, ana.ID, 6)
+	requireBalance(t, beto.ID, 2)
+
+	type posting struct {
+		creditID uint
+		amount   int
+		kind     models.TransactionType
+	}
+	want := []posting{
+		{soon.ID, 3, models.TransactionTypeGrant},
+		{later.ID, 10, models.TransactionTypeGrant},
+		{soon.ID, -3, models.TransactionTypeDeduction}, // Soonest to expire first
+		{later.ID, -2, models.TransactionTypeDeduction},
+		{later.ID, -2, models.TransactionTypeTransferOut},
+	}
+	entries := ledgerEntries(t, db, ana.ID)
+	if len(entries) != len(want) {
+		t.Fatalf("got %d ledger entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.CreditID == nil || *entry.CreditID != want[i].creditID || entry.Amount != want[i].amount || entry.Type != want[i].kind {
+			t.Fatalf("entry %d = lot %v, %d %s; want lot %d, %d %s",
+				i, entry.CreditID, entry.Amount, entry.Type, want[i].creditID, want[i].amount, want[i].kind)
+		}
+	}
+}
+
+func TestCreditLedgerIsAppendOnly(t *testing.T) {
+	db := useTestDB(t)
+	ana := createTestUser(t, db, "Ana")
+	grantTestCredits(t, db, ana.ID, 5, 30)
+
+	entry := ledgerEntries(t, db, ana.ID)[0]
+	entry.Amount = 50
+	if err := db.Save(&entry).Error; !errors.Is(err, models.ErrLedgerAppendOnly) {
+		t.Fatalf("Save() = %v, want %v", err, models.ErrLedgerAppendOnly)
+	}
+	if err := db.Delete(&entry).Error; !errors.Is(err, models.ErrLedgerAppendOnly) {
+		t.Fatalf("Delete() = %v, want %v", err, models.ErrLedgerAppendOnly)
+	}
+	requireBalance(t, ana.ID, 5)
+}
+
+// A failed movement posts nothing
+func TestFailedDeductionLeavesLedgerUntouched(t *testing.T) {
+	db := useTestDB(t)
+	ana := createTestUser(t, db, "Ana")
+	grantTestCredits(t, db, ana.ID, 3, 30)
+
+	if err := NewCreditService().DeductCredits(db, ana.ID, 4, CreditEntry{Reason: "Reserva"}); err == nil {
+		t.Fatal("DeductCredits() succeeded with too few credits")
+	}
+	if n := len(ledgerEntries(t, db, ana.ID)); n != 1 {
+		t.Fatalf("got %d ledger entries, want only the grant", n)
+	}
+	requireBalance(t, ana.ID, 3)
+}
+
+// Concurrent deductions wait for each other's row locks, so the balance can
+// never be spent twice
+func TestConcurrentDeductionsNeverOverdraw(t *testing.T) {
+	db := useTestDB(t)
+	ana := createTestUser(t, db, "Ana")
+	grantTestCredits(t, db, ana.ID, 4, 10)
+	grantTestCredits(t, db, ana.ID, 6, 40)
+
+	const attempts = 6
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- NewCreditService().DeductCredits(db, ana.ID, 3, CreditEntry{Reason: "Reserva"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 3 {
+		t.Fatalf("%d of %d deductions of 3 out of 10 credits succeeded, want 3", succeeded, attempts)
+	}
+	requireBalance(t, ana.ID, 1)
+}
+
+// A deduction made inside the caller's transaction is undone with it
+func TestDeductionRollsBackWithCaller(t *testing.T) {
+	db := useTestDB(t)
+	ana := createTestUser(t, db, "Ana")
+	grantTestCredits(t, db, ana.ID, 5, 30)
+
+	errAbort := errors.New("abort")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := NewCreditService().DeductCredits(tx, ana.ID, 5, CreditEntry{Reason: "Reserva"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Transaction() = %v, want %v", err, errAbort)
+	}
+	requireBalance(t, ana.ID, 5)
+}
+
+// Held credits stay in their lots but cannot be spent or held twice until
+// the hold is resolved
+func TestHoldsSetCreditsAside(t *testing.T) {
+	db := useTestDB(t)
+	service := NewCreditService()
+	ana := createTestUser(t, db, "Ana")
+	grantTestCredits(t, db, ana.ID, 10, 30)
+
+	if err := service.PlaceHold(db, ana.ID, 1, 6); err != nil {
+		t.Fatalf("PlaceHold: %v", err)
+	}
+	requireAvailable(t, ana.ID, 4, 6)
+	requireBalance(t, ana.ID, 10)
+
+	if err := service.PlaceHold(db, ana.ID, 2, 5); err == nil {
+		t.Fatal("PlaceHold() succeeded over the available credits")
+	}
+	if err := service.DeductCredits(db, ana.ID, 5, CreditEntry{Reason: "Reserva"}); err == nil {
+		t.Fatal("DeductCredits() spent held credits")
+	}
+	requireAvailable(t, ana.ID, 4, 6)
+
+	// Rejected: the credits are available again
+	if err := service.ResolveHold(db, 1, models.HoldReleased); err != nil {
+		t.Fatalf("ResolveHold: %v", err)
+	}
+	requireAvailable(t, ana.ID, 10, 0)
+	requireBalance(t, ana.ID, 10)
+
+	// Approved: the hold is captured and charged in the same transaction
+	if err := service.PlaceHold(db, ana.ID, 3, 7); err != nil {
+		t.Fatalf("PlaceHold: %v", err)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := service.ResolveHold(tx, 3, models.HoldCaptured); err != nil {
+			return err
+		}
+		return service.DeductCredits(tx, ana.ID, 7, CreditEntry{Reason: "Reserva aprobada"})
+	})
+	if err != nil {
+		t.Fatalf("capturing the hold: %v", err)
+	}
+	requireAvailable(t, ana.ID, 3, 0)
+	requireBalance(t, ana.ID, 3)
+}
+
+// requireAvailable fails unless the user's balance splits into the given
+// available and held credits
+func requireAvailable(t *testing.T, userID uint, available, held int) {
+	t.Helper()
+	balance, err := NewCreditService().GetBalance(userID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance.Available != available || balance.Held != held {
+		t.Fatalf("available = %d, held = %d; want %d, %d", balance.Available, balance.Held, available, held)
+	}
+}
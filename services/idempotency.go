@@ -0,0 +1,131 @@
+package services
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm/clause"
+)
+
+// idempotencyRetention is how long a stored response is replayed, configurable
+// through IDEMPOTENCY_KEY_RETENTION_HOURS
+func idempotencyRetention() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_KEY_RETENTION_HOURS")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// idempotencyProcessingTimeout is how long a key may stay in processing
+// before a retry can claim it again, configurable through
+// IDEMPOTENCY_PROCESSING_TIMEOUT_MINUTES. It covers requests whose server died
+// before completing or releasing the key.
+func idempotencyProcessingTimeout() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_PROCESSING_TIMEOUT_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 2 * time.Minute
+}
+
+type IdempotencyService struct{}
+
+func NewIdempotencyService() *IdempotencyService {
+	return &IdempotencyService{}
+}
+
+// Begin claims a key for a request. It returns the stored record and true
+// when the key already has a response to replay, or a new record in
+// processing when the request should run. The unique index on user and key
+// makes sure only one of two concurrent requests claims it.
+func (s *IdempotencyService) Begin(userID uint, key, method, path, requestHash string) (*models.IdempotencyKey, bool, error) {
+	now := time.Now()
+	record := models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash,
+		Status:      models.IdempotencyProcessing,
+		ExpiresAt:   now.Add(idempotencyRetention()),
+	}
+
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return &record, false, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := config.DB.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+
+	// An expired key that the cleanup job has not removed yet is free again,
+	// and so is the same request stuck in processing past the timeout
+	staleBefore := now.Add(-idempotencyProcessingTimeout())
+	stale := existing.Status == models.IdempotencyProcessing && existing.RequestHash == requestHash &&
+		!existing.UpdatedAt.After(staleBefore)
+	if !existing.ExpiresAt.After(now) || stale {
+		claimed := config.DB.Model(&models.IdempotencyKey{}).
+			Where("id = ? AND (expires_at <= ? OR (status = ? AND request_hash = ? AND updated_at <= ?))",
+				existing.ID, now, models.IdempotencyProcessing, requestHash, staleBefore).
+			Updates(map[string]interface{}{
+				"method":          method,
+				"path":            path,
+				"request_hash":    requestHash,
+				"status":          models.IdempotencyProcessing,
+				"response_status": 0,
+				"response_body":   "",
+				"expires_at":      record.ExpiresAt,
+				"updated_at":      now,
+			})
+		if claimed.Error != nil {
+			return nil, false, claimed.Error
+		}
+		if claimed.RowsAffected == 1 {
+			record.ID = existing.ID
+			record.CreatedAt = existing.CreatedAt
+			return &record, false, nil
+		}
+		if err := config.DB.First(&existing, existing.ID).Error; err != nil {
+			return nil, false, err
+		}
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, false, models.ErrIdempotencyKeyReused
+	}
+	if existing.Status != models.IdempotencyCompleted {
+		return nil, false, models.ErrIdempotencyKeyInProcess
+	}
+	return &existing, true, nil
+}
+
+// Complete stores the response of a claimed key for replay
+func (s *IdempotencyService) Complete(recordID uint, status int, body string) error {
+	return config.DB.Model(&models.IdempotencyKey{}).
+		Where("id = ?", recordID).
+		Updates(map[string]interface{}{
+			"status":          models.IdempotencyCompleted,
+			"response_status": status,
+			"response_body":   body,
+		}).Error
+}
+
+// Release frees a claimed key whose request failed unexpectedly so that a
+// retry runs it again
+func (s *IdempotencyService) Release(recordID uint) error {
+	return config.DB.Where("id = ? AND status = ?", recordID, models.IdempotencyProcessing).
+		Delete(&models.IdempotencyKey{}).Error
+}
+
+// CleanupExpiredKeys deletes the keys past their retention window
+func (s *IdempotencyService) CleanupExpiredKeys() (int64, error) {
+	result := config.DB.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
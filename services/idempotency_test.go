@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+const testIdempotencyPath = "/api/user/payments"
+
+// ageIdempotencyKey moves the key's last update back in time, as if its
+// request had been running for that long
+func ageIdempotencyKey(t *testing.T, db *gorm.DB, recordID uint, age time.Duration) {
+	t.Helper()
+	if err := db.Model(&models.IdempotencyKey{}).Where("id = ?", recordID).
+		UpdateColumn("updated_at", time.Now().Add(-age)).Error; err != nil {
+		t.Fatalf("aging the idempotency key: %v", err)
+	}
+}
+
+func TestIdempotencyKeyReplaysCompletedResponse(t *testing.T) {
+	useTestDB(t)
+	service := NewIdempotencyService()
+
+	record, replay, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a")
+	if err != nil || replay {
+		t.Fatalf("Begin() = %v, %v; want a new claim", replay, err)
+	}
+
+	if _, _, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a"); !errors.Is(err, models.ErrIdempotencyKeyInProcess) {
+		t.Fatalf("retry while processing: Begin() = %v, want %v", err, models.ErrIdempotencyKeyInProcess)
+	}
+	if _, _, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-b"); !errors.Is(err, models.ErrIdempotencyKeyReused) {
+		t.Fatalf("other request: Begin() = %v, want %v", err, models.ErrIdempotencyKeyReused)
+	}
+	if _, replay, err := service.Begin(2, "key-1", "POST", testIdempotencyPath, "hash-b"); err != nil || replay {
+		t.Fatalf("other user: Begin() = %v, %v; want a new claim", replay, err)
+	}
+
+	if err := service.Complete(record.ID, 201, `{"id":7}`); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	stored, replay, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a")
+	if err != nil || !replay {
+		t.Fatalf("retry after completion: Begin() = %v, %v; want a replay", replay, err)
+	}
+	if stored.ResponseStatus != 201 || stored.ResponseBody != `{"id":7}` {
+		t.Fatalf("replayed %d %s, want 201 {\"id\":7}", stored.ResponseStatus, stored.ResponseBody)
+	}
+}
+
+// A key stuck in processing, because its server died before completing or
+// releasing it, is claimed again by a retry of the same request after the
+// timeout
+func TestIdempotencyKeyReclaimsStuckRequest(t *testing.T) {
+	db := useTestDB(t)
+	service := NewIdempotencyService()
+
+	record, _, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+
+	ageIdempotencyKey(t, db, record.ID, idempotencyProcessingTimeout()/2)
+	if _, _, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a"); !errors.Is(err, models.ErrIdempotencyKeyInProcess) {
+		t.Fatalf("retry before the timeout: Begin() = %v, want %v", err, models.ErrIdempotencyKeyInProcess)
+	}
+
+	ageIdempotencyKey(t, db, record.ID, idempotencyProcessingTimeout()+time.Minute)
+	if _, _, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-b"); !errors.Is(err, models.ErrIdempotencyKeyReused) {
+		t.Fatalf("other request after the timeout: Begin() = %v, want %v", err, models.ErrIdempotencyKeyReused)
+	}
+
+	// Only one of several concurrent retries gets it
+	const retries = 5
+	var wg sync.WaitGroup
+	claims := make(chan uint, retries)
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reclaimed, replay, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a")
+			if err == nil && !replay {
+				claims <- reclaimed.ID
+			}
+		}()
+	}
+	wg.Wait()
+	close(claims)
+
+	if len(claims) != 1 {
+		t.Fatalf("%d of %d retries claimed the stuck key, want 1", len(claims), retries)
+	}
+	if id := <-claims; id != record.ID {
+		t.Fatalf("reclaimed key %d, want the stuck key %d", id, record.ID)
+	}
+}
+
+// Released and expired keys are free for any request
+func TestIdempotencyKeyFreedByReleaseOrExpiry(t *testing.T) {
+	db := useTestDB(t)
+	service := NewIdempotencyService()
+
+	record, _, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := service.Release(record.ID); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	record, replay, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-a")
+	if err != nil || replay {
+		t.Fatalf("retry after release: Begin() = %v, %v; want a new claim", replay, err)
+	}
+
+	if err := service.Complete(record.ID, 200, `{}`); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if err := db.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).
+		UpdateColumn("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("expiring the idempotency key: %v", err)
+	}
+	if _, replay, err := service.Begin(1, "key-1", "POST", testIdempotencyPath, "hash-b"); err != nil || replay {
+		t.Fatalf("other request after expiry: Begin() = %v, %v; want a new claim", replay, err)
+	}
+}
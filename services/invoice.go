@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// withoutInvoiceFiles leaves the XML and PDF out of invoices loaded in lists
+func withoutInvoiceFiles(db *gorm.DB) *gorm.DB {
+	return db.Omit("xml", "pdf")
+}
+
+type InvoiceService struct {
+	pac PACProvider
+}
+
+func NewInvoiceService() *InvoiceService {
+	return &InvoiceService{
+		pac: NewPACProvider(),
+	}
+}
+
+// UpdateTaxData validates and saves the tax data a user is invoiced with
+func (s *InvoiceService) UpdateTaxData(userID uint, data models.TaxData) (*models.User, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("Usuario no encontrado")
+	}
+
+	normalized, err := normalizeTaxData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	user.TaxData = normalized
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"tax_rfc":        normalized.RFC,
+		"tax_legal_name": normalized.LegalName,
+		"tax_regime":     normalized.Regime,
+		"tax_zip_code":   normalized.ZipCode,
+		"tax_cfdi_use":   normalized.CFDIUse,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetInvoices lists the invoices of a user, or all of them when userID is 0.
+// With cancellationRequired only invoices of refunded or voided payments that
+// still have to be cancelled or offset are listed.
+func (s *InvoiceService) GetInvoices(userID uint, cancellationRequired bool) ([]models.Invoice, error) {
+	query := withoutInvoiceFiles(config.DB.Preload("Payment"))
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	} else {
+		query = query.Preload("User")
+	}
+	if cancellationRequired {
+		query = query.Where("cancellation_required = ?", true)
+	}
+
+	var invoices []models.Invoice
+	err := query.Order("created_at DESC").Find(&invoices).Error
+	return invoices, err
+}
+
+// GetInvoice returns an invoice with its files. With ownerID set it must
+// belong to that user.
+func (s *InvoiceService) GetInvoice(invoiceID, ownerID uint) (*models.Invoice, error) {
+	query := config.DB.Where("id = ?", invoiceID)
+	if ownerID != 0 {
+		query = query.Where("user_id = ?", ownerID)
+	}
+
+	var invoice models.Invoice
+	if err := query.First(&invoice).Error; err != nil {
+		return nil, errors.New("Factura no encontrada")
+	}
+	return &invoice, nil
+}
+
+// CreateInvoice issues the CFDI of a payment with the payer's tax data. With
+// ownerID set the payment must belong to that user. An invoice the PAC
+// rejected is kept as failed, with the reason, and can be requested again.
+func (s *InvoiceService) CreateInvoice(paymentID, actorID, ownerID uint) (*models.Invoice, error) {
+	if s.pac == nil {
+		return nil, errors.New("La facturación no está disponible")
+	}
+	issuer, err := invoiceIssuerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var invoice models.Invoice
+	var stampErr error
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the payment keeps two requests from stamping it twice
+		var payment models.Payment
+		if err := forUpdate(tx).Preload("User").Preload("Package", withDeleted).First(&payment, paymentID).Error; err != nil {
+			return errors.New("Pago no encontrado")
+		}
+		if ownerID != 0 && payment.UserID != ownerID {
+			return errors.New("Pago no encontrado")
+		}
+		if payment.Type != models.PaymentTypePayment || payment.Amount <= 0 {
+			return errors.New("Solo se pueden facturar pagos")
+		}
+		if payment.Status != models.PaymentCompleted {
+			return errors.New("No se puede facturar un pago reembolsado o anulado")
+		}
+
+		err := tx.Where("payment_id = ?", payment.ID).First(&invoice).Error
+		if err == nil && invoice.Status == models.InvoiceStamped {
+			return errors.New("El pago ya tiene factura")
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if !payment.User.TaxData.IsComplete() {
+			return errors.New("Faltan datos fiscales (RFC, razón social, régimen fiscal, código postal y uso de CFDI) para facturar")
+		}
+		paymentForm, ok := paymentForms[strings.ToLower(payment.PaymentMethod)]
+		if !ok {
+			return fmt.Errorf("La forma de pago %q no tiene equivalente en el catálogo del SAT", payment.PaymentMethod)
+		}
+
+		subtotal, vat := splitVAT(payment.Amount)
+		invoice.PaymentID = payment.ID
+		invoice.UserID = payment.UserID
+		invoice.Series = issuer.Series
+		invoice.Folio = strconv.FormatUint(uint64(payment.ID), 10)
+		invoice.Receiver = payment.User.TaxData
+		invoice.Subtotal = subtotal
+		invoice.VAT = vat
+		invoice.Total = payment.Amount
+		invoice.Currency = payment.Currency
+		invoice.PaymentForm = paymentForm
+		invoice.PACProvider = s.pac.Name()
+		invoice.CreatedBy = actorID
+
+		description := invoiceDescription(&payment)
+		cfdi, err := buildCFDI(issuer, &invoice, description, time.Now())
+		if err != nil {
+			return err
+		}
+
+		stamped, err := s.pac.Stamp(cfdi)
+		if err != nil {
+			log.Printf("El PAC rechazó la factura del pago %d: %v", payment.ID, err)
+			stampErr = fmt.Errorf("El PAC rechazó la factura: %v", err)
+			invoice.Status = models.InvoiceFailed
+			invoice.Error = err.Error()
+			invoice.XML = string(cfdi)
+			invoice.PDF = nil
+			return tx.Save(&invoice).Error
+		}
+
+		invoice.Status = models.InvoiceStamped
+		invoice.Error = ""
+		invoice.UUID = stamped.UUID
+		invoice.StampedAt = &stamped.StampedAt
+		invoice.XML = string(stamped.XML)
+		invoice.PDF = renderInvoicePDF(issuer, &invoice, description)
+		return tx.Save(&invoice).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stampErr != nil {
+		return &invoice, stampErr
+	}
+
+	return &invoice, nil
+}
+
+// invoiceDescription names what the payment bought
+func invoiceDescription(payment *models.Payment) string {
+	switch {
+	case payment.PenaltyID != nil:
+		return "Pago de penalización"
+	case payment.MembershipID != nil:
+		return "Cuota de membresía"
+	case payment.Package != nil:
+		return "Paquete de créditos " + payment.Package.Name
+	case payment.CreditsGranted > 0:
+		return fmt.Sprintf("%d créditos para uso de espacios", payment.CreditsGranted)
+	default:
+		return "Vale de regalo de créditos"
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+// pdfText is a line of text on the page, positioned in points from the
+// bottom left corner
+type pdfText struct {
+	X, Y float64
+	Size float64
+	Bold bool
+	Text string
+}
+
+// pdfEscape encodes text for a PDF string in WinAnsiEncoding, which covers
+// the accents and ñ used in Spanish
+func pdfEscape(text string) string {
+	var out strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r < 32:
+			out.WriteByte(' ')
+		case r < 128:
+			out.WriteRune(r)
+		case r < 256:
+			out.WriteString(fmt.Sprintf("\\%03o", r))
+		default:
+			out.WriteByte('?')
+		}
+	}
+	return out.String()
+}
+
+// renderPDF writes a single letter-size page with the given lines. It is
+// enough for the printed representation of an invoice and avoids a PDF
+// library.
+func renderPDF(lines []pdfText) []byte {
+	var content bytes.Buffer
+	for _, line := range lines {
+		font := "F1"
+		if line.Bold {
+			font = "F2"
+		}
+		fmt.Fprintf(&content, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, line.Size, line.X, line.Y, pdfEscape(line.Text))
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return pdf.Bytes()
+}
+
+// renderInvoicePDF is the printed representation of a stamped invoice
+func renderInvoicePDF(issuer *invoiceIssuer, invoice *models.Invoice, description string) []byte {
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+	stampedAt := ""
+	if invoice.StampedAt != nil {
+		stampedAt = invoice.StampedAt.In(loc).Format("2006-01-02 15:04:05")
+	}
+
+	lines := []pdfText{
+		{X: 50, Y: 740, Size: 16, Bold: true, Text: "Factura electrónica (CFDI 4.0)"},
+		{X: 50, Y: 718, Size: 10, Text: fmt.Sprintf("Serie %s  Folio %s", invoice.Series, invoice.Folio)},
+		{X: 50, Y: 704, Size: 10, Text: "Folio fiscal (UUID): " + invoice.UUID},
+		{X: 50, Y: 690, Size: 10, Text: "Fecha de timbrado: " + stampedAt},
+
+		{X: 50, Y: 660, Size: 11, Bold: true, Text: "Emisor"},
+		{X: 50, Y: 646, Size: 10, Text: issuer.Name},
+		{X: 50, Y: 632, Size: 10, Text: fmt.Sprintf("RFC %s  Régimen fiscal %s", issuer.RFC, issuer.Regime)},
+		{X: 50, Y: 618, Size: 10, Text: "Lugar de expedición: " + issuer.ZipCode},
+
+		{X: 50, Y: 588, Size: 11, Bold: true, Text: "Receptor"},
+		{X: 50, Y: 574, Size: 10, Text: invoice.Receiver.LegalName},
+		{X: 50, Y: 560, Size: 10, Text: fmt.Sprintf("RFC %s  Régimen fiscal %s", invoice.Receiver.RFC, invoice.Receiver.Regime)},
+		{X: 50, Y: 546, Size: 10, Text: fmt.Sprintf("Domicilio fiscal %s  Uso CFDI %s", invoice.Receiver.ZipCode, invoice.Receiver.CFDIUse)},
+
+		{X: 50, Y: 516, Size: 11, Bold: true, Text: "Concepto"},
+		{X: 400, Y: 516, Size: 11, Bold: true, Text: "Importe"},
+		{X: 50, Y: 502, Size: 10, Text: fmt.Sprintf("%s (%s, %s)", description, issuer.ProductKey, issuer.UnitKey)},
+		{X: 400, Y: 502, Size: 10, Text: invoice.Subtotal.String()},
+
+		{X: 300, Y: 470, Size: 10, Text: "Subtotal"},
+		{X: 400, Y: 470, Size: 10, Text: invoice.Subtotal.String()},
+		{X: 300, Y: 456, Size: 10, Text: "IVA 16%"},
+		{X: 400, Y: 456, Size: 10, Text: invoice.VAT.String()},
+		{X: 300, Y: 442, Size: 10, Bold: true, Text: "Total " + invoice.Currency},
+		{X: 400, Y: 442, Size: 10, Bold: true, Text: invoice.Total.String()},
+
+		{X: 50, Y: 412, Size: 10, Text: fmt.Sprintf("Forma de pago %s  Método de pago PUE  Moneda %s", invoice.PaymentForm, invoice.Currency)},
+		{X: 50, Y: 380, Size: 8, Text: "Este documento es una representación impresa de un CFDI."},
+	}
+	return renderPDF(lines)
+}
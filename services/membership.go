@@ -0,0 +1,485 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// membershipGraceDays is how long after a period starts its payment may be
+// registered before the membership is flagged, configurable through
+// MEMBERSHIP_PAYMENT_GRACE_DAYS
+func membershipGraceDays() int {
+	if days, err := strconv.Atoi(os.Getenv("MEMBERSHIP_PAYMENT_GRACE_DAYS")); err == nil && days >= 0 {
+		return days
+	}
+	return 5
+}
+
+// nextBillingDate returns the first billing day strictly after from, at
+// midnight local time
+func nextBillingDate(from time.Time, billingDay int) time.Time {
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+
+	local := from.In(loc)
+	next := time.Date(local.Year(), local.Month(), billingDay, 0, 0, 0, 0, loc)
+	if !next.After(local) {
+		next = next.AddDate(0, 1, 0)
+	}
+	return next
+}
+
+type MembershipService struct {
+	creditService *CreditService
+}
+
+func NewMembershipService() *MembershipService {
+	return &MembershipService{
+		creditService: NewCreditService(),
+	}
+}
+
+func (s *MembershipService) GetPlans() ([]models.MembershipPlan, error) {
+	var plans []models.MembershipPlan
+	err := config.DB.Order("monthly_price_cents ASC").Find(&plans).Error
+	return plans, err
+}
+
+func (s *MembershipService) CreatePlan(plan *models.MembershipPlan) error {
+	if err := s.validatePlan(plan); err != nil {
+		return err
+	}
+	plan.Currency = models.DefaultCurrency
+	return config.DB.Create(plan).Error
+}
+
+// UpdatePlan changes a plan. Current periods keep what they were granted and
+// charged; the changes apply from the next period on.
+func (s *MembershipService) UpdatePlan(planID uint, changes *models.MembershipPlan) (*models.MembershipPlan, error) {
+	var plan models.MembershipPlan
+	if err := config.DB.First(&plan, planID).Error; err != nil {
+		return nil, errors.New("Plan no encontrado")
+	}
+
+	plan.Name = changes.Name
+	plan.Description = changes.Description
+	plan.MonthlyPrice = changes.MonthlyPrice
+	plan.MonthlyCredits = changes.MonthlyCredits
+	plan.RolloverCap = changes.RolloverCap
+	plan.IsActive = changes.IsActive
+
+	if err := s.validatePlan(&plan); err != nil {
+		return nil, err
+	}
+	if err := config.DB.Save(&plan).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (s *MembershipService) validatePlan(plan *models.MembershipPlan) error {
+	if strings.TrimSpace(plan.Name) == "" {
+		return errors.New("El nombre del plan es requerido")
+	}
+	if plan.MonthlyPrice <= 0 {
+		return errors.New("El precio mensual debe ser positivo")
+	}
+	if plan.MonthlyCredits <= 0 {
+		return errors.New("Los créditos mensuales deben ser positivos")
+	}
+	if plan.RolloverCap < 0 {
+		return errors.New("El tope de créditos acumulables no puede ser negativo")
+	}
+	return nil
+}
+
+// GetMemberships lists memberships, optionally filtered by status and user
+func (s *MembershipService) GetMemberships(status string, userID uint) ([]models.Membership, error) {
+	query := config.DB.Preload("User").Preload("Plan", withDeleted)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var memberships []models.Membership
+	err := query.Order("created_at DESC").Find(&memberships).Error
+	return memberships, err
+}
+
+// GetUserMemberships lists the user's memberships with their periods
+func (s *MembershipService) GetUserMemberships(userID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := config.DB.Preload("Plan", withDeleted).
+		Preload("Periods", func(db *gorm.DB) *gorm.DB {
+			return db.Order("period_start DESC")
+		}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&memberships).Error
+	return memberships, err
+}
+
+func (s *MembershipService) GetMembership(membershipID uint) (*models.Membership, error) {
+	var membership models.Membership
+	err := config.DB.Preload("User").Preload("Plan", withDeleted).
+		Preload("Periods", func(db *gorm.DB) *gorm.DB {
+			return db.Order("period_start DESC")
+		}).
+		First(&membership, membershipID).Error
+	if err != nil {
+		return nil, errors.New("Membresía no encontrada")
+	}
+	return &membership, nil
+}
+
+// CreateMembership subscribes a professional to a plan. The first period runs
+// from now until the next billing day and is granted its full allotment.
+func (s *MembershipService) CreateMembership(userID, planID uint, billingDay int, adminID uint) (*models.Membership, error) {
+	if billingDay < 1 || billingDay > 28 {
+		return nil, errors.New("El día de cobro debe estar entre 1 y 28")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("Usuario no encontrado")
+	}
+
+	var plan models.MembershipPlan
+	if err := config.DB.First(&plan, planID).Error; err != nil {
+		return nil, errors.New("Plan no encontrado")
+	}
+	if !plan.IsActive {
+		return nil, errors.New("El plan no está disponible")
+	}
+
+	var existing int64
+	config.DB.Model(&models.Membership{}).
+		Where("user_id = ? AND status <> ?", userID, models.MembershipCancelled).
+		Count(&existing)
+	if existing > 0 {
+		return nil, errors.New("El usuario ya tiene una membresía vigente")
+	}
+
+	now := time.Now()
+	membership := models.Membership{
+		UserID:     userID,
+		PlanID:     planID,
+		BillingDay: billingDay,
+		Status:     models.MembershipActive,
+		CreatedBy:  adminID,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		_, err := s.startPeriod(tx, &membership, &plan, now, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	membership.Plan = plan
+	return &membership, nil
+}
+
+// CancelMembership stops future periods. Credits already granted stay
+// spendable until the current period ends.
+func (s *MembershipService) CancelMembership(membershipID, adminID uint) (*models.Membership, error) {
+	var membership models.Membership
+	if err := config.DB.First(&membership, membershipID).Error; err != nil {
+		return nil, errors.New("Membresía no encontrada")
+	}
+	if membership.Status == models.MembershipCancelled {
+		return nil, errors.New("La membresía ya está cancelada")
+	}
+
+	now := time.Now()
+	result := config.DB.Model(&models.Membership{}).
+		Where("id = ? AND status <> ?", membershipID, models.MembershipCancelled).
+		Updates(map[string]interface{}{
+			"status":       models.MembershipCancelled,
+			"cancelled_at": now,
+			"cancelled_by": adminID,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("La membresía ya está cancelada")
+	}
+
+	membership.Status = models.MembershipCancelled
+	membership.CancelledAt = &now
+	membership.CancelledBy = &adminID
+	return &membership, nil
+}
+
+// startPeriod opens the period beginning at start: it grants the plan's
+// allotment, plus any credits rolled over, as a new lot expiring when the
+// period ends
+func (s *MembershipService) startPeriod(tx *gorm.DB, membership *models.Membership, plan *models.MembershipPlan, start time.Time, rolledOver int) (*models.MembershipPeriod, error) {
+	end := nextBillingDate(start, membership.BillingDay)
+
+	period := models.MembershipPeriod{
+		MembershipID: membership.ID,
+		PeriodStart:  start,
+		PeriodEnd:    end,
+		RolledOver:   rolledOver,
+		Amount:       plan.MonthlyPrice,
+		Currency:     plan.Currency,
+		DueDate:      start.AddDate(0, 0, membershipGraceDays()),
+	}
+	if err := tx.Create(&period).Error; err != nil {
+		return nil, err
+	}
+
+	lot, err := s.creditService.grantLot(tx, membership.UserID, plan.MonthlyCredits, end, CreditEntry{
+		Type:   models.TransactionTypeMembership,
+		Reason: "Créditos de la membresía " + plan.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rolledOver > 0 {
+		lot.Amount += rolledOver
+		if err := tx.Save(lot).Error; err != nil {
+			return nil, err
+		}
+		if err := s.creditService.post(tx, membership.UserID, lot.ID, rolledOver, CreditEntry{
+			Type:   models.TransactionTypeRollover,
+			Reason: "Créditos acumulados del periodo anterior",
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	period.CreditID = &lot.ID
+	period.CreditsGranted = plan.MonthlyCredits
+	if err := tx.Save(&period).Error; err != nil {
+		return nil, err
+	}
+
+	membership.CurrentPeriodStart = start
+	membership.CurrentPeriodEnd = end
+	if err := tx.Model(membership).Updates(map[string]interface{}{
+		"current_period_start": start,
+		"current_period_end":   end,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &period, nil
+}
+
+// closePeriod empties the period's lot: up to the plan's rollover cap is
+// carried into the next period and the rest expires. It returns the credits
+// carried over.
+func (s *MembershipService) closePeriod(tx *gorm.DB, period *models.MembershipPeriod, plan *models.MembershipPlan) (int, error) {
+	if period.CreditID == nil {
+		return 0, nil
+	}
+
+	var lot models.Credit
+	if err := forUpdate(tx).First(&lot, *period.CreditID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	unused := lot.Amount
+	rollover := unused
+	if rollover > plan.RolloverCap {
+		rollover = plan.RolloverCap
+	}
+	expired := unused - rollover
+
+	if lot.IsActive {
+		if expired > 0 {
+			if err := s.creditService.takeFromLot(tx, &lot, expired, CreditEntry{
+				Type:   models.TransactionTypeExpiration,
+				Reason: "Créditos de membresía no acumulables vencidos",
+			}); err != nil {
+				return 0, err
+			}
+		}
+		if rollover > 0 {
+			if err := s.creditService.takeFromLot(tx, &lot, rollover, CreditEntry{
+				Type:   models.TransactionTypeRollover,
+				Reason: "Créditos acumulados al siguiente periodo",
+			}); err != nil {
+				return 0, err
+			}
+		}
+	} else if rollover > 0 {
+		// The expiration job got to the lot first and already posted all of it
+		// as expired. Take the rolled over credits out of the lot so they
+		// cannot come back if it is ever reactivated.
+		lot.Amount -= rollover
+		if err := tx.Save(&lot).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	period.ExpiredCredits = expired
+	if err := tx.Model(period).Update("expired_credits", expired).Error; err != nil {
+		return 0, err
+	}
+	return rollover, nil
+}
+
+// RenewMemberships is the scheduled period roll: every membership whose
+// period has ended gets its previous credits rolled over or expired and the
+// next period's allotment. Missed periods are caught up one at a time.
+func (s *MembershipService) RenewMemberships() (int64, error) {
+	var memberships []models.Membership
+	if err := config.DB.Where("status <> ? AND current_period_end <= ?", models.MembershipCancelled, time.Now()).
+		Find(&memberships).Error; err != nil {
+		return 0, err
+	}
+
+	var renewed int64
+	for i := range memberships {
+		for !memberships[i].CurrentPeriodEnd.After(time.Now()) {
+			if err := s.renew(&memberships[i]); err != nil {
+				log.Printf("Error al renovar la membresía %d: %v", memberships[i].ID, err)
+				break
+			}
+			renewed++
+		}
+	}
+
+	return renewed, nil
+}
+
+func (s *MembershipService) renew(membership *models.Membership) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the membership and make sure another instance did not renew it
+		var current models.Membership
+		if err := forUpdate(tx).First(&current, membership.ID).Error; err != nil {
+			return err
+		}
+		if !current.CurrentPeriodEnd.Equal(membership.CurrentPeriodEnd) {
+			*membership = current
+			return nil
+		}
+
+		var plan models.MembershipPlan
+		if err := tx.Unscoped().First(&plan, current.PlanID).Error; err != nil {
+			return err
+		}
+
+		var period models.MembershipPeriod
+		rollover := 0
+		err := tx.Where("membership_id = ? AND period_start = ?", current.ID, current.CurrentPeriodStart).
+			First(&period).Error
+		if err == nil {
+			if rollover, err = s.closePeriod(tx, &period, &plan); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if _, err := s.startPeriod(tx, &current, &plan, current.CurrentPeriodEnd, rollover); err != nil {
+			return err
+		}
+		*membership = current
+		return nil
+	})
+}
+
+// FlagOverduePayments marks the memberships with a period whose payment was
+// not registered by its due date as past due
+func (s *MembershipService) FlagOverduePayments() (int64, error) {
+	var periods []models.MembershipPeriod
+	if err := config.DB.Joins("JOIN memberships ON memberships.id = membership_periods.membership_id").
+		Where("memberships.status <> ?", models.MembershipCancelled).
+		Where("membership_periods.payment_id IS NULL AND membership_periods.flagged_at IS NULL AND membership_periods.due_date <= ?", time.Now()).
+		Find(&periods).Error; err != nil {
+		return 0, err
+	}
+
+	var flagged int64
+	for _, period := range periods {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.MembershipPeriod{}).
+				Where("id = ? AND payment_id IS NULL AND flagged_at IS NULL", period.ID).
+				Update("flagged_at", time.Now())
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			flagged++
+			return tx.Model(&models.Membership{}).
+				Where("id = ? AND status = ?", period.MembershipID, models.MembershipActive).
+				Update("status", models.MembershipPastDue).Error
+		})
+		if err != nil {
+			return flagged, err
+		}
+	}
+
+	return flagged, nil
+}
+
+// payPeriod links a payment to the oldest unpaid period of the membership,
+// inside the payment's transaction, and clears the past due flag once no
+// overdue period is left
+func (s *MembershipService) payPeriod(tx *gorm.DB, membership *models.Membership, period *models.MembershipPeriod, paymentID uint) error {
+	now := time.Now()
+	result := tx.Model(&models.MembershipPeriod{}).
+		Where("id = ? AND payment_id IS NULL", period.ID).
+		Updates(map[string]interface{}{"payment_id": paymentID, "paid_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("El periodo ya fue pagado")
+	}
+	period.PaymentID = &paymentID
+	period.PaidAt = &now
+
+	if membership.Status != models.MembershipPastDue {
+		return nil
+	}
+
+	var overdue int64
+	if err := tx.Model(&models.MembershipPeriod{}).
+		Where("membership_id = ? AND payment_id IS NULL AND due_date <= ?", membership.ID, now).
+		Count(&overdue).Error; err != nil {
+		return err
+	}
+	if overdue > 0 {
+		return nil
+	}
+	return tx.Model(membership).Update("status", models.MembershipActive).Error
+}
+
+// unpaidPeriod returns the membership's oldest unpaid period, locked
+func (s *MembershipService) unpaidPeriod(tx *gorm.DB, membershipID uint) (*models.MembershipPeriod, error) {
+	var period models.MembershipPeriod
+	err := forUpdate(tx).Where("membership_id = ? AND payment_id IS NULL", membershipID).
+		Order("period_start ASC").
+		First(&period).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("La membresía no tiene periodos pendientes de pago")
+		}
+		return nil, err
+	}
+	return &period, nil
+}
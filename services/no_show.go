@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// defaultNoShowPolicy applies until an admin saves a policy: no-shows are not
+// tracked and past reservations are completed as before
+var defaultNoShowPolicy = models.NoShowPolicy{
+	Enabled:        false,
+	GraceMinutes:   15,
+	PenaltyCredits: 0,
+}
+
+type NoShowService struct{}
+
+func NewNoShowService() *NoShowService {
+	return &NoShowService{}
+}
+
+func (s *NoShowService) GetPolicy() models.NoShowPolicy {
+	var policy models.NoShowPolicy
+	if err := config.DB.First(&policy).Error; err != nil {
+		return defaultNoShowPolicy
+	}
+	return policy
+}
+
+func (s *NoShowService) UpdatePolicy(enabled bool, graceMinutes, penaltyCredits int, adminID uint) (*models.NoShowPolicy, error) {
+	if graceMinutes < 0 {
+		return nil, errors.New("Los minutos de tolerancia no pueden ser negativos")
+	}
+	if penaltyCredits < 0 {
+		return nil, errors.New("La penalización no puede ser negativa")
+	}
+
+	policy := s.GetPolicy()
+	policy.Enabled = enabled
+	policy.GraceMinutes = graceMinutes
+	policy.PenaltyCredits = penaltyCredits
+	policy.UpdatedBy = &adminID
+
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// FlagNoShows marks confirmed reservations that ended more than the grace
+// period ago without a check-in as no_show and penalizes the professional per
+// the policy. Does nothing while the policy is disabled.
+func (s *NoShowService) FlagNoShows() (int64, error) {
+	policy := s.GetPolicy()
+	if !policy.Enabled {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-time.Duration(policy.GraceMinutes) * time.Minute)
+
+	var reservations []models.Reservation
+	if err := config.DB.Where("status = ? AND end_time <= ? AND checked_in_at IS NULL", models.StatusConfirmed, cutoff).
+		Find(&reservations).Error; err != nil {
+		return 0, err
+	}
+
+	var flagged int64
+	for i := range reservations {
+		reservation := &reservations[i]
+
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := transitionReservation(tx, reservation, Transition{
+				To:     models.StatusNoShow,
+				Reason: "Sin registro de llegada",
+			}); err != nil {
+				return err
+			}
+
+			// External clients have no account to penalize
+			if policy.PenaltyCredits == 0 || reservation.UserID == nil {
+				return nil
+			}
+			return tx.Create(&models.Penalty{
+				UserID:        *reservation.UserID,
+				ReservationID: reservation.ID,
+				Amount:        policy.PenaltyCredits,
+				Status:        models.PenaltyPending,
+				Reason:        "No se presentó a la reservación",
+			}).Error
+		})
+		if err != nil {
+			return flagged, err
+		}
+		flagged++
+	}
+
+	return flagged, nil
+}
+
+// GetNoShowCounts returns the number of no-show reservations per user
+func (s *NoShowService) GetNoShowCounts() map[uint]int {
+	var rows []struct {
+		UserID uint
+		Count  int
+	}
+	config.DB.Model(&models.Reservation{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("status = ? AND user_id IS NOT NULL", models.StatusNoShow).
+		Group("user_id").
+		Scan(&rows)
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StampedCFDI is a CFDI certified by a PAC
+type StampedCFDI struct {
+	XML       []byte // The CFDI with its TimbreFiscalDigital complement
+	UUID      string
+	StampedAt time.Time
+}
+
+// PACProvider is the Proveedor Autorizado de Certificación that seals and
+// stamps (timbra) invoices before they are valid for the SAT
+type PACProvider interface {
+	Name() string
+	Stamp(cfdi []byte) (*StampedCFDI, error)
+}
+
+// NewPACProvider returns the PAC selected by INVOICE_PAC, or nil when
+// invoicing is off or it names a provider this build does not include. The
+// local stub is only used when selected explicitly.
+func NewPACProvider() PACProvider {
+	switch name := os.Getenv("INVOICE_PAC"); name {
+	case "":
+		return nil
+	case localPACName:
+		return &LocalPAC{}
+	default:
+		log.Printf("PAC desconocido: %s", name)
+		return nil
+	}
+}
+
+const localPACName = "local"
+
+// LocalPAC is a stub for development and tests. It adds a
+// TimbreFiscalDigital with a random UUID and placeholder seals; its invoices
+// are not valid for the SAT.
+type LocalPAC struct{}
+
+func (p *LocalPAC) Name() string {
+	return localPACName
+}
+
+func (p *LocalPAC) Stamp(cfdi []byte) (*StampedCFDI, error) {
+	closing := []byte("</cfdi:Comprobante>")
+	at := bytes.LastIndex(cfdi, closing)
+	if at < 0 {
+		return nil, errors.New("CFDI inválido")
+	}
+
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+	stampedAt := time.Now().Truncate(time.Second)
+	id := uuid.New().String()
+	complement := fmt.Sprintf(`  <cfdi:Complemento>
+    <tfd:TimbreFiscalDigital xmlns:tfd="http://www.sat.gob.mx/TimbreFiscalDigital" Version="1.1" UUID="%s" FechaTimbrado="%s" RfcProvCertif="AAA010101AAA" SelloCFD="STUB" NoCertificadoSAT="00000000000000000000" SelloSAT="STUB"></tfd:TimbreFiscalDigital>
+  </cfdi:Complemento>
+`, id, stampedAt.In(loc).Format("2006-01-02T15:04:05"))
+
+	stamped := make([]byte, 0, len(cfdi)+len(complement))
+	stamped = append(stamped, cfdi[:at]...)
+	stamped = append(stamped, complement...)
+	stamped = append(stamped, cfdi[at:]...)
+
+	return &StampedCFDI{XML: stamped, UUID: id, StampedAt: stampedAt}, nil
+}
@@ -2,49 +2,129 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/IkingariSolorzano/omma-be/config"
 	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
 )
 
 type PaymentService struct {
-	creditService *CreditService
+	creditService     *CreditService
+	membershipService *MembershipService
+	promoCodeService  *PromoCodeService
 }
 
 func NewPaymentService() *PaymentService {
 	return &PaymentService{
-		creditService: NewCreditService(),
+		creditService:     NewCreditService(),
+		membershipService: NewMembershipService(),
+		promoCodeService:  NewPromoCodeService(),
 	}
 }
 
-func (s *PaymentService) RegisterPayment(userID, adminID uint, amount float64, paymentMethod, reference, notes string) (*models.Payment, error) {
+// PaymentInput is a payment registered at the front desk. With PackageID set
+// the payment buys that package and Amount may be left at 0 to charge the
+// package price; with MembershipID set it pays the membership's oldest unpaid
+// period; otherwise Amount buys credits at the unit price. PromoCode applies
+// a bonus code to the credits bought.
+type PaymentInput struct {
+	UserID        uint
+	AdminID       uint
+	PackageID     *uint
+	MembershipID  *uint
+	PromoCode     string
+	Amount        models.Money
+	PaymentMethod string
+	Reference     string
+	Notes         string
+}
+
+// creditPurchase is what a payment buys
+type creditPurchase struct {
+	Amount       models.Money
+	Currency     string
+	Credits      int // Paid credits plus bonus
+	BonusCredits int
+	ValidityDays int
+	Package      *models.CreditPackage
+}
+
+// resolvePurchase works out the credits, validity and price of a payment from
+// its package or, without one, from the unit price
+func (s *PaymentService) resolvePurchase(packageID *uint, amount models.Money) (*creditPurchase, error) {
+	if packageID != nil {
+		var pkg models.CreditPackage
+		if err := config.DB.First(&pkg, *packageID).Error; err != nil {
+			return nil, errors.New("Paquete no encontrado")
+		}
+		if !pkg.IsAvailable(time.Now()) {
+			return nil, errors.New("El paquete no está disponible")
+		}
+		if amount != 0 && amount != pkg.Price {
+			return nil, fmt.Errorf("El monto no coincide con el precio del paquete (%s)", pkg.Price)
+		}
+		return &creditPurchase{
+			Amount:       pkg.Price,
+			Currency:     pkg.Currency,
+			Credits:      pkg.TotalCredits(),
+			BonusCredits: pkg.BonusCredits,
+			ValidityDays: pkg.ValidityDays,
+			Package:      &pkg,
+		}, nil
+	}
+
 	if amount <= 0 {
 		return nil, errors.New("El monto debe ser positivo")
 	}
 
-	// 1 crédito = 10 pesos. Exigir múltiplos de 10 para evitar fracciones de crédito
-	if int(amount)%10 != 0 {
-		return nil, errors.New("El monto debe ser múltiplo de 10 (1 crédito = 10 pesos)")
+	// Exigir un número entero de créditos para evitar fracciones de crédito
+	unitPrice := creditUnitPrice()
+	if amount%unitPrice != 0 {
+		return nil, fmt.Errorf("El monto debe ser múltiplo de %s (precio de 1 crédito)", unitPrice)
+	}
+
+	return &creditPurchase{
+		Amount:       amount,
+		Currency:     models.DefaultCurrency,
+		Credits:      int(amount / unitPrice),
+		ValidityDays: defaultCreditValidityDays,
+	}, nil
+}
+
+func (s *PaymentService) RegisterPayment(input PaymentInput) (*models.Payment, error) {
+	if input.MembershipID != nil {
+		if input.PackageID != nil {
+			return nil, errors.New("Un pago de membresía no puede incluir un paquete")
+		}
+		if input.PromoCode != "" {
+			return nil, errors.New("Los códigos promocionales no aplican a membresías")
+		}
+		return s.registerMembershipPayment(input)
 	}
 
-	// Calcular créditos desde el monto
-	credits := int(amount) / 10
-	creditCost := 10.0
+	purchase, err := s.resolvePurchase(input.PackageID, input.Amount)
+	if err != nil {
+		return nil, err
+	}
 
 	// Start transaction
 	tx := config.DB.Begin()
 
-	// Create payment record
+	// Create payment record. CreditCost is the effective price per credit,
+	// bonus credits included
 	payment := models.Payment{
-		UserID:         userID,
-		AdminID:        adminID,
-		Amount:         amount,
-		CreditsGranted: credits,
-		CreditCost:     creditCost,
-		PaymentMethod:  paymentMethod,
-		Reference:      reference,
-		Notes:          notes,
+		UserID:         input.UserID,
+		AdminID:        &input.AdminID,
+		Amount:         purchase.Amount,
+		Currency:       purchase.Currency,
+		CreditsGranted: purchase.Credits,
+		CreditCost:     purchase.Amount.Per(purchase.Credits),
+		PaymentMethod:  input.PaymentMethod,
+		Reference:      input.Reference,
+		Notes:          input.Notes,
+		PackageID:      input.PackageID,
 	}
 
 	if err := tx.Create(&payment).Error; err != nil {
@@ -52,29 +132,95 @@ func (s *PaymentService) RegisterPayment(userID, adminID uint, amount float64, p
 		return nil, err
 	}
 
-	// Add credits to user
-	credit := models.Credit{
-		UserID:       userID,
-		Amount:       credits,
-		PurchaseDate: time.Now(),
-		ExpiryDate:   time.Now().AddDate(0, 0, 30), // 30 days
-		IsActive:     true,
+	reason := "Compra de créditos"
+	if purchase.Package != nil {
+		reason = "Compra del paquete " + purchase.Package.Name
 	}
 
-	if err := tx.Create(&credit).Error; err != nil {
+	// Add credits to user
+	expiry := time.Now().AddDate(0, 0, purchase.ValidityDays)
+	if _, err := s.creditService.grantLot(tx, input.UserID, purchase.Credits, expiry, CreditEntry{
+		Type:      models.TransactionTypePurchase,
+		Reason:    reason,
+		Notes:     input.Reference,
+		AdminID:   &input.AdminID,
+		PaymentID: &payment.ID,
+	}); err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
+	if input.PromoCode != "" {
+		if _, err := s.promoCodeService.applyBonus(tx, input.PromoCode, input.UserID, purchase.Credits-purchase.BonusCredits, expiry, payment.ID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, err
 	}
+
+	payment.Package = purchase.Package
+	return &payment, nil
+}
+
+// registerMembershipPayment pays the oldest unpaid period of a membership. The
+// credits were already granted when the period started, so the payment grants
+// none; CreditCost is the plan price spread over the period's allotment.
+func (s *PaymentService) registerMembershipPayment(input PaymentInput) (*models.Payment, error) {
+	var payment models.Payment
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var membership models.Membership
+		if err := tx.First(&membership, *input.MembershipID).Error; err != nil {
+			return errors.New("Membresía no encontrada")
+		}
+		if membership.UserID != input.UserID {
+			return errors.New("La membresía no pertenece al usuario")
+		}
+
+		period, err := s.membershipService.unpaidPeriod(tx, membership.ID)
+		if err != nil {
+			return err
+		}
+		if input.Amount != 0 && input.Amount != period.Amount {
+			return fmt.Errorf("El monto no coincide con la cuota del periodo (%s)", period.Amount)
+		}
+
+		payment = models.Payment{
+			UserID:         input.UserID,
+			AdminID:        &input.AdminID,
+			Amount:         period.Amount,
+			Currency:       period.Currency,
+			CreditsGranted: 0,
+			CreditCost:     period.Amount.Per(period.CreditsGranted),
+			PaymentMethod:  input.PaymentMethod,
+			Reference:      input.Reference,
+			Notes:          input.Notes,
+			MembershipID:   &membership.ID,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		return s.membershipService.payPeriod(tx, &membership, period, payment.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &payment, nil
 }
 
+// withDeleted keeps packages removed from the catalog visible on the
+// payments that bought them
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
 func (s *PaymentService) GetPaymentHistory(userID uint) ([]models.Payment, error) {
 	var payments []models.Payment
-	err := config.DB.Preload("Admin").
+	err := config.DB.Preload("Admin").Preload("Package", withDeleted).Preload("Invoice", withoutInvoiceFiles).
 		Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Find(&payments).Error
@@ -84,7 +230,7 @@ func (s *PaymentService) GetPaymentHistory(userID uint) ([]models.Payment, error
 
 func (s *PaymentService) GetAllPayments() ([]models.Payment, error) {
 	var payments []models.Payment
-	err := config.DB.Preload("User").Preload("Admin").
+	err := config.DB.Preload("User").Preload("Admin").Preload("Package", withDeleted).Preload("Invoice", withoutInvoiceFiles).
 		Order("created_at DESC").
 		Find(&payments).Error
 	
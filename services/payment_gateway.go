@@ -0,0 +1,215 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+var ErrInvalidWebhookSignature = errors.New("Firma del webhook inválida")
+
+// GatewayCheckout is a checkout opened at the payment provider
+type GatewayCheckout struct {
+	Ref string // Checkout ID at the provider
+	URL string // Where the professional completes the payment
+}
+
+// GatewayEvent is the state of a checkout as reported by the provider, either
+// in a webhook or when queried
+type GatewayEvent struct {
+	EventID     string // Empty for status queries
+	CheckoutRef string
+	Status      models.CheckoutStatus
+	Amount      models.Money
+	Currency    string
+	Reference   string // Payment ID at the provider
+}
+
+// PaymentGateway is an online payment provider. Implementations verify the
+// webhook signature in ParseWebhook and never trust an unsigned payload.
+type PaymentGateway interface {
+	Name() string
+	CreateCheckout(checkout *models.Checkout) (*GatewayCheckout, error)
+	ParseWebhook(payload []byte, header http.Header) (*GatewayEvent, error)
+	GetStatus(ref string) (*GatewayEvent, error)
+}
+
+// NewPaymentGateway returns the gateway selected by PAYMENT_GATEWAY, or nil
+// when online payments are off or it names a provider this build does not
+// include. A gateway without PAYMENT_WEBHOOK_SECRET stops the server, since
+// its webhooks could be forged.
+func NewPaymentGateway() PaymentGateway {
+	switch name := os.Getenv("PAYMENT_GATEWAY"); name {
+	case "":
+		return nil
+	case fakeGatewayName:
+		secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+		if secret == "" {
+			log.Fatal("PAYMENT_WEBHOOK_SECRET es requerido para la pasarela de pago")
+		}
+		return NewFakeGateway(secret)
+	default:
+		log.Printf("Pasarela de pago desconocida: %s", name)
+		return nil
+	}
+}
+
+func randomHex(bytes int) (string, error) {
+	buf := make([]byte, bytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+const (
+	fakeGatewayName     = "fake"
+	fakeSignatureHeader = "X-Fake-Signature"
+)
+
+// fakeGatewayEvent is the webhook body sent by the fake gateway
+type fakeGatewayEvent struct {
+	ID        string                `json:"id"`
+	Checkout  string                `json:"checkout"`
+	Status    models.CheckoutStatus `json:"status"`
+	Amount    models.Money          `json:"amount"`
+	Currency  string                `json:"currency"`
+	Reference string                `json:"reference"`
+}
+
+// fakeCheckouts is the fake provider's own record of its checkouts, shared by
+// every FakeGateway in the process
+var fakeCheckouts = struct {
+	sync.Mutex
+	byRef map[string]*fakeGatewayEvent
+}{byRef: make(map[string]*fakeGatewayEvent)}
+
+// FakeGateway is an in-memory provider for development and tests. Its
+// webhooks are signed with HMAC-SHA256 like a real provider's, and
+// SimulatePayment produces them.
+type FakeGateway struct {
+	secret []byte
+}
+
+func NewFakeGateway(secret string) *FakeGateway {
+	return &FakeGateway{secret: []byte(secret)}
+}
+
+func (g *FakeGateway) Name() string {
+	return fakeGatewayName
+}
+
+func (g *FakeGateway) CreateCheckout(checkout *models.Checkout) (*GatewayCheckout, error) {
+	id, err := randomHex(12)
+	if err != nil {
+		return nil, err
+	}
+	ref := "fake_cs_" + id
+
+	fakeCheckouts.Lock()
+	fakeCheckouts.byRef[ref] = &fakeGatewayEvent{
+		Checkout: ref,
+		Status:   models.CheckoutPending,
+		Amount:   checkout.Amount,
+		Currency: checkout.Currency,
+	}
+	fakeCheckouts.Unlock()
+
+	return &GatewayCheckout{Ref: ref, URL: "fake://checkout/" + ref}, nil
+}
+
+func (g *FakeGateway) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Sign returns the signature the fake gateway sends with a payload
+func (g *FakeGateway) Sign(payload []byte) string {
+	return hex.EncodeToString(g.mac(payload))
+}
+
+func (g *FakeGateway) ParseWebhook(payload []byte, header http.Header) (*GatewayEvent, error) {
+	signature, err := hex.DecodeString(header.Get(fakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, g.mac(payload)) {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	var event fakeGatewayEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.New("Evento del webhook inválido")
+	}
+	if event.ID == "" || event.Checkout == "" {
+		return nil, errors.New("Evento del webhook incompleto")
+	}
+
+	return &GatewayEvent{
+		EventID:     event.ID,
+		CheckoutRef: event.Checkout,
+		Status:      event.Status,
+		Amount:      event.Amount,
+		Currency:    event.Currency,
+		Reference:   event.Reference,
+	}, nil
+}
+
+func (g *FakeGateway) GetStatus(ref string) (*GatewayEvent, error) {
+	fakeCheckouts.Lock()
+	defer fakeCheckouts.Unlock()
+
+	checkout, ok := fakeCheckouts.byRef[ref]
+	if !ok {
+		return nil, errors.New("Pago no encontrado en la pasarela")
+	}
+	return &GatewayEvent{
+		CheckoutRef: checkout.Checkout,
+		Status:      checkout.Status,
+		Amount:      checkout.Amount,
+		Currency:    checkout.Currency,
+		Reference:   checkout.Reference,
+	}, nil
+}
+
+// SimulatePayment settles a fake checkout as paid or failed and returns the
+// signed webhook the provider would send for it
+func (g *FakeGateway) SimulatePayment(ref string, status models.CheckoutStatus) ([]byte, http.Header, error) {
+	if status != models.CheckoutPaid && status != models.CheckoutFailed {
+		return nil, nil, errors.New("Estado de pago inválido")
+	}
+	eventID, err := randomHex(12)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fakeCheckouts.Lock()
+	checkout, ok := fakeCheckouts.byRef[ref]
+	if !ok {
+		fakeCheckouts.Unlock()
+		return nil, nil, errors.New("Pago no encontrado en la pasarela")
+	}
+	checkout.Status = status
+	if status == models.CheckoutPaid && checkout.Reference == "" {
+		checkout.Reference = "fake_pay_" + eventID
+	}
+	event := *checkout
+	fakeCheckouts.Unlock()
+
+	event.ID = "evt_" + eventID
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set(fakeSignatureHeader, g.Sign(payload))
+	return payload, header, nil
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// RefundResult is a refund or void together with what happened to the
+// credits the payment had granted
+type RefundResult struct {
+	Refund  *models.Payment `json:"refund"`
+	Payment *models.Payment `json:"payment"`
+	// Credits is how many of the granted credits the refunded amount covers
+	Credits int `json:"credits"`
+	// ClawedBack were taken back from the payment's lots
+	ClawedBack int `json:"clawed_back"`
+	// Consumed could not be taken back: they were already spent, transferred
+	// or expired, or are held for reservations pending approval
+	Consumed int `json:"consumed"`
+	// Invoice is the payment's stamped CFDI, now flagged for cancellation or
+	// a credit note
+	Invoice *models.Invoice `json:"invoice,omitempty"`
+}
+
+// RefundPayment gives back part or all of a payment. amount 0 refunds what
+// is left of it. The unspent credits it bought are taken back in proportion.
+func (s *PaymentService) RefundPayment(paymentID, adminID uint, amount models.Money, reason string) (*RefundResult, error) {
+	return s.reverse(paymentID, adminID, amount, reason, models.PaymentTypeRefund)
+}
+
+// VoidPayment reverses a payment registered by mistake as a whole. A payment
+// that was already partially refunded has to be refunded instead.
+func (s *PaymentService) VoidPayment(paymentID, adminID uint, reason string) (*RefundResult, error) {
+	return s.reverse(paymentID, adminID, 0, reason, models.PaymentTypeVoid)
+}
+
+func (s *PaymentService) reverse(paymentID, adminID uint, amount models.Money, reason string, kind models.PaymentType) (*RefundResult, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, errors.New("El motivo es requerido")
+	}
+	if amount < 0 {
+		return nil, errors.New("El monto a reembolsar debe ser positivo")
+	}
+
+	result := &RefundResult{}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := forUpdate(tx).First(&payment, paymentID).Error; err != nil {
+			return errors.New("Pago no encontrado")
+		}
+		if payment.Type != models.PaymentTypePayment {
+			return errors.New("Solo se pueden reembolsar pagos, no reembolsos")
+		}
+		if payment.Status == models.PaymentVoided || payment.Status == models.PaymentRefunded {
+			return errors.New("El pago ya fue reembolsado por completo")
+		}
+		if kind == models.PaymentTypeVoid && payment.RefundedAmount > 0 {
+			return errors.New("El pago ya tiene reembolsos parciales, reembolse el resto en su lugar")
+		}
+
+		remaining := payment.Amount - payment.RefundedAmount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount > remaining {
+			return fmt.Errorf("El monto excede lo que queda por reembolsar (%s)", remaining)
+		}
+		full := amount == remaining
+
+		lotIDs, granted, err := s.grantedLots(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		if granted == 0 {
+			// A gift voucher's credits went to whoever redeemed it, so once
+			// redeemed they count as granted but cannot be taken back
+			if granted, err = s.redeemedVoucherCredits(tx, payment.ID); err != nil {
+				return err
+			}
+		}
+
+		// Credits covered by the refund, in proportion to the amount paid. A
+		// full refund covers whatever is left so rounding never leaves any.
+		credits := 0
+		if granted > 0 && payment.Amount > 0 {
+			credits = int((int64(amount)*int64(granted) + int64(payment.Amount)/2) / int64(payment.Amount))
+		}
+		covered, err := s.refundedCredits(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		if full || credits > granted-covered {
+			credits = granted - covered
+		}
+
+		refund := models.Payment{
+			Type:           kind,
+			UserID:         payment.UserID,
+			AdminID:        &adminID,
+			Amount:         -amount,
+			Currency:       payment.Currency,
+			CreditsGranted: -credits,
+			CreditCost:     payment.CreditCost,
+			PaymentMethod:  payment.PaymentMethod,
+			Reference:      payment.Reference,
+			RefundOfID:     &payment.ID,
+			Reason:         reason,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+
+		clawedBack, err := s.clawBack(tx, lotIDs, &payment, &refund, credits, adminID, reason)
+		if err != nil {
+			return err
+		}
+
+		status := models.PaymentPartiallyRefunded
+		if kind == models.PaymentTypeVoid {
+			status = models.PaymentVoided
+		} else if full {
+			status = models.PaymentRefunded
+		}
+		payment.RefundedAmount += amount
+		payment.Status = status
+		if err := tx.Model(&payment).Updates(map[string]interface{}{
+			"refunded_cents": payment.RefundedAmount,
+			"status":         status,
+		}).Error; err != nil {
+			return err
+		}
+
+		if full {
+			if err := s.unlinkPayment(tx, &payment); err != nil {
+				return err
+			}
+		}
+
+		invoice, err := s.flagInvoice(tx, &payment, kind, amount, full, reason)
+		if err != nil {
+			return err
+		}
+
+		result.Refund = &refund
+		result.Payment = &payment
+		result.Credits = credits
+		result.ClawedBack = clawedBack
+		result.Consumed = credits - clawedBack
+		result.Invoice = invoice
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// refundedCredits is how many granted credits earlier refunds of the payment
+// already covered
+func (s *PaymentService) refundedCredits(tx *gorm.DB, paymentID uint) (int, error) {
+	var covered int64
+	err := tx.Model(&models.Payment{}).
+		Where("refund_of_id = ?", paymentID).
+		Select("COALESCE(SUM(-credits_granted), 0)").
+		Scan(&covered).Error
+	return int(covered), err
+}
+
+// grantedLots returns the lots a payment granted, the purchased lot and any
+// bonus lot, and the credits they started with
+func (s *PaymentService) grantedLots(tx *gorm.DB, paymentID uint) ([]uint, int, error) {
+	var entries []models.CreditTransaction
+	if err := tx.Where("payment_id = ? AND amount > 0 AND type IN ?", paymentID,
+		[]models.TransactionType{models.TransactionTypePurchase, models.TransactionTypePromo}).
+		Order("id ASC").
+		Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	lotIDs := make([]uint, 0, len(entries))
+	granted := 0
+	for _, entry := range entries {
+		if entry.CreditID != nil {
+			lotIDs = append(lotIDs, *entry.CreditID)
+		}
+		granted += entry.Amount
+	}
+	return lotIDs, granted, nil
+}
+
+// redeemedVoucherCredits returns the credits of the gift voucher bought with
+// the payment, or 0 when it bought none or the voucher was not redeemed yet
+func (s *PaymentService) redeemedVoucherCredits(tx *gorm.DB, paymentID uint) (int, error) {
+	var vouchers []models.PromoCode
+	if err := tx.Unscoped().Where("payment_id = ? AND redemption_count > 0", paymentID).Find(&vouchers).Error; err != nil {
+		return 0, err
+	}
+
+	credits := 0
+	for _, voucher := range vouchers {
+		credits += voucher.Credits * voucher.RedemptionCount
+	}
+	return credits, nil
+}
+
+// clawBack takes up to credits unspent credits back from the payment's lots,
+// purchased lot first, posting a clawback entry per lot. Credits held for
+// pending reservations stay with the user, so it never takes more than the
+// balance outside the holds. It returns how many it could take back.
+func (s *PaymentService) clawBack(tx *gorm.DB, lotIDs []uint, payment, refund *models.Payment, credits int, adminID uint, reason string) (int, error) {
+	if credits <= 0 || len(lotIDs) == 0 {
+		return 0, nil
+	}
+
+	available, err := s.creditService.lockedAvailable(tx, payment.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if credits > available {
+		credits = available
+	}
+	if credits <= 0 {
+		return 0, nil
+	}
+
+	var lots []models.Credit
+	if err := forUpdate(tx).Where("id IN ? AND is_active = ? AND expiry_date > ?", lotIDs, true, time.Now()).
+		Order("id ASC").
+		Find(&lots).Error; err != nil {
+		return 0, err
+	}
+
+	remaining := credits
+	for i := range lots {
+		if remaining == 0 {
+			break
+		}
+		taken := lots[i].Amount
+		if taken > remaining {
+			taken = remaining
+		}
+		if taken == 0 {
+			continue
+		}
+		if err := s.creditService.takeFromLot(tx, &lots[i], taken, CreditEntry{
+			Type:      models.TransactionTypeClawback,
+			Reason:    "Reembolso del pago",
+			Notes:     fmt.Sprintf("Pago ID: %d. %s", payment.ID, reason),
+			AdminID:   &adminID,
+			PaymentID: &refund.ID,
+		}); err != nil {
+			return 0, err
+		}
+		remaining -= taken
+	}
+
+	return credits - remaining, nil
+}
+
+// unlinkPayment undoes what a fully refunded payment paid for besides
+// credits: the penalty goes back to pending, the membership period becomes
+// unpaid, an unredeemed gift voucher stops working and a bonus code applied
+// to it can be used again
+func (s *PaymentService) unlinkPayment(tx *gorm.DB, payment *models.Payment) error {
+	if payment.PenaltyID != nil {
+		if err := tx.Model(&models.Penalty{}).
+			Where("id = ? AND payment_id = ?", *payment.PenaltyID, payment.ID).
+			Updates(map[string]interface{}{
+				"status":      models.PenaltyPending,
+				"settled_via": "",
+				"settled_at":  nil,
+				"settled_by":  nil,
+				"payment_id":  nil,
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	if payment.MembershipID != nil {
+		if err := tx.Model(&models.MembershipPeriod{}).
+			Where("membership_id = ? AND payment_id = ?", *payment.MembershipID, payment.ID).
+			Updates(map[string]interface{}{
+				"payment_id": nil,
+				"paid_at":    nil,
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := s.promoCodeService.reverseBonus(tx, payment.ID); err != nil {
+		return err
+	}
+
+	return tx.Model(&models.PromoCode{}).
+		Where("payment_id = ? AND redemption_count = 0", payment.ID).
+		Update("is_active", false).Error
+}
+
+// flagInvoice marks the payment's stamped invoice as needing cancellation,
+// for a void or full refund, or a credit note for the refunded amount. It
+// returns nil when the payment was not invoiced.
+func (s *PaymentService) flagInvoice(tx *gorm.DB, payment *models.Payment, kind models.PaymentType, amount models.Money, full bool, reason string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := withoutInvoiceFiles(tx).Where("payment_id = ? AND status = ?", payment.ID, models.InvoiceStamped).First(&invoice).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var note string
+	switch {
+	case kind == models.PaymentTypeVoid:
+		note = fmt.Sprintf("Pago anulado, cancelar el CFDI: %s", reason)
+	case full:
+		note = fmt.Sprintf("Pago reembolsado por completo, cancelar el CFDI o emitir nota de crédito por %s: %s", amount, reason)
+	default:
+		note = fmt.Sprintf("Reembolso parcial, emitir nota de crédito por %s: %s", amount, reason)
+	}
+	if invoice.CancellationReason != "" {
+		note = invoice.CancellationReason + "\n" + note
+	}
+
+	invoice.CancellationRequired = true
+	invoice.CancellationReason = note
+	if err := tx.Model(&invoice).Updates(map[string]interface{}{
+		"cancellation_required": true,
+		"cancellation_reason":   note,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
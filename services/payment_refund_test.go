@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+// buyTestCredits registers a transfer from the user buying the credits at
+// 100.00 each
+func buyTestCredits(t *testing.T, userID, adminID uint, credits int) *models.Payment {
+	t.Helper()
+	t.Setenv("CREDIT_UNIT_PRICE", "100.00")
+	payment, err := NewPaymentService().RegisterPayment(PaymentInput{
+		UserID:        userID,
+		AdminID:       adminID,
+		Amount:        models.Money(credits) * 10000,
+		PaymentMethod: "transfer",
+	})
+	if err != nil {
+		t.Fatalf("RegisterPayment: %v", err)
+	}
+	return payment
+}
+
+func requireRefund(t *testing.T, result *RefundResult, credits, clawedBack, consumed int, status models.PaymentStatus) {
+	t.Helper()
+	if result.Credits != credits || result.ClawedBack != clawedBack || result.Consumed != consumed {
+		t.Fatalf("refund covered %d credits, clawed back %d, consumed %d; want %d, %d, %d",
+			result.Credits, result.ClawedBack, result.Consumed, credits, clawedBack, consumed)
+	}
+	if result.Payment.Status != status {
+		t.Fatalf("payment status = %s, want %s", result.Payment.Status, status)
+	}
+}
+
+// Only the unspent credits can be taken back; the rest is reported consumed
+func TestRefundClawsBackUnspentCredits(t *testing.T) {
+	db := useTestDB(t)
+	admin := createTestUser(t, db, "Admin")
+	ana := createTestUser(t, db, "Ana")
+	payment := buyTestCredits(t, ana.ID, admin.ID, 10)
+
+	if err := NewCreditService().DeductCredits(db, ana.ID, 4, CreditEntry{Reason: "Reserva"}); err != nil {
+		t.Fatalf("DeductCredits: %v", err)
+	}
+
+	result, err := NewPaymentService().RefundPayment(payment.ID, admin.ID, 0, "Cliente insatisfecho")
+	if err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	requireRefund(t, result, 10, 6, 4, models.PaymentRefunded)
+	if result.Refund.Amount != -payment.Amount || result.Refund.CreditsGranted != -10 {
+		t.Fatalf("refund entry = %s, %d credits; want %s, -10", result.Refund.Amount, result.Refund.CreditsGranted, -payment.Amount)
+	}
+	requireBalance(t, ana.ID, 0)
+
+	if _, err := NewPaymentService().RefundPayment(payment.ID, admin.ID, 0, "Otra vez"); err == nil {
+		t.Fatal("RefundPayment() refunded a payment twice")
+	}
+}
+
+// Partial refunds cover the credits in proportion and together never cover
+// more than the payment granted
+func TestPartialRefundsCoverCreditsInProportion(t *testing.T) {
+	db := useTestDB(t)
+	admin := createTestUser(t, db, "Admin")
+	ana := createTestUser(t, db, "Ana")
+	payment := buyTestCredits(t, ana.ID, admin.ID, 10)
+	service := NewPaymentService()
+
+	result, err := service.RefundPayment(payment.ID, admin.ID, 30000, "Reembolso parcial")
+	if err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	requireRefund(t, result, 3, 3, 0, models.PaymentPartiallyRefunded)
+	requireBalance(t, ana.ID, 7)
+
+	if _, err := service.RefundPayment(payment.ID, admin.ID, 70001, "Demasiado"); err == nil {
+		t.Fatal("RefundPayment() refunded more than was left")
+	}
+	if _, err := service.VoidPayment(payment.ID, admin.ID, "Error de captura"); err == nil {
+		t.Fatal("VoidPayment() voided a partially refunded payment")
+	}
+
+	result, err = service.RefundPayment(payment.ID, admin.ID, 0, "Resto")
+	if err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	requireRefund(t, result, 7, 7, 0, models.PaymentRefunded)
+	if result.Payment.RefundedAmount != payment.Amount {
+		t.Fatalf("RefundedAmount = %s, want %s", result.Payment.RefundedAmount, payment.Amount)
+	}
+	requireBalance(t, ana.ID, 0)
+}
+
+// Credits held for a reservation pending approval stay with the user
+func TestRefundLeavesHeldCredits(t *testing.T) {
+	db := useTestDB(t)
+	admin := createTestUser(t, db, "Admin")
+	ana := createTestUser(t, db, "Ana")
+	payment := buyTestCredits(t, ana.ID, admin.ID, 10)
+
+	if err := NewCreditService().PlaceHold(db, ana.ID, 1, 8); err != nil {
+		t.Fatalf("PlaceHold: %v", err)
+	}
+
+	result, err := NewPaymentService().VoidPayment(payment.ID, admin.ID, "Error de captura")
+	if err != nil {
+		t.Fatalf("VoidPayment: %v", err)
+	}
+	requireRefund(t, result, 10, 2, 8, models.PaymentVoided)
+	requireBalance(t, ana.ID, 8)
+	requireAvailable(t, ana.ID, 0, 8)
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+type PenaltyService struct {
+	creditService *CreditService
+}
+
+func NewPenaltyService() *PenaltyService {
+	return &PenaltyService{
+		creditService: NewCreditService(),
+	}
+}
+
+func (s *PenaltyService) GetUserPenalties(userID uint) ([]models.Penalty, error) {
+	var penalties []models.Penalty
+	err := config.DB.Preload("Reservation.Space").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&penalties).Error
+
+	return penalties, err
+}
+
+// GetPenalties lists penalties for admins, optionally filtered by status and user
+func (s *PenaltyService) GetPenalties(status string, userID uint) ([]models.Penalty, error) {
+	query := config.DB.Preload("User").Preload("Reservation.Space")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var penalties []models.Penalty
+	err := query.Order("created_at DESC").Find(&penalties).Error
+	return penalties, err
+}
+
+// SettleWithCredits pays a pending penalty from the user's active credits.
+// ownerID restricts the penalty to that user (professional settling their
+// own); pass 0 when an admin settles it.
+func (s *PenaltyService) SettleWithCredits(penaltyID, ownerID, actorID uint) (*models.Penalty, error) {
+	penalty, err := s.pendingPenalty(penaltyID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := CreditEntry{
+		Type:          models.TransactionTypePenalty,
+		Reason:        "Pago de penalización",
+		ReservationID: &penalty.ReservationID,
+	}
+	if ownerID == 0 {
+		entry.AdminID = &actorID
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.creditService.DeductCredits(tx, penalty.UserID, penalty.Amount, entry); err != nil {
+			return err
+		}
+		return s.markSettled(tx, penalty, models.SettledWithCredits, actorID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return penalty, nil
+}
+
+// SettleWithPayment records a money payment for a pending penalty
+func (s *PenaltyService) SettleWithPayment(penaltyID, adminID uint, paymentMethod, reference, notes string) (*models.Penalty, *models.Payment, error) {
+	penalty, err := s.pendingPenalty(penaltyID, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Penalty credits are valued at the single credit price
+	unitPrice := creditUnitPrice()
+	payment := models.Payment{
+		UserID:         penalty.UserID,
+		AdminID:        &adminID,
+		Amount:         unitPrice.Times(penalty.Amount),
+		Currency:       models.DefaultCurrency,
+		CreditsGranted: 0,
+		CreditCost:     unitPrice,
+		PaymentMethod:  paymentMethod,
+		Reference:      reference,
+		Notes:          notes,
+		PenaltyID:      &penalty.ID,
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return s.markSettled(tx, penalty, models.SettledWithPayment, adminID, &payment.ID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return penalty, &payment, nil
+}
+
+func (s *PenaltyService) markSettled(tx *gorm.DB, penalty *models.Penalty, via models.PenaltySettlement, actorID uint, paymentID *uint) error {
+	now := time.Now()
+	result := tx.Model(&models.Penalty{}).
+		Where("id = ? AND status = ?", penalty.ID, models.PenaltyPending).
+		Updates(map[string]interface{}{
+			"status":      models.PenaltyPaid,
+			"settled_via": via,
+			"settled_at":  now,
+			"settled_by":  actorID,
+			"payment_id":  paymentID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("La penalización ya no está pendiente")
+	}
+
+	// A settled penalty has nothing left to appeal
+	if err := tx.Model(&models.PenaltyAppeal{}).
+		Where("penalty_id = ? AND status = ?", penalty.ID, models.AppealPending).
+		Updates(map[string]interface{}{
+			"status":      models.AppealDenied,
+			"review_note": "La penalización fue liquidada",
+			"reviewed_at": now,
+		}).Error; err != nil {
+		return err
+	}
+
+	penalty.Status = models.PenaltyPaid
+	penalty.SettledVia = via
+	penalty.SettledAt = &now
+	penalty.SettledBy = &actorID
+	penalty.PaymentID = paymentID
+	return nil
+}
+
+// AppealPenalty lets the professional ask for a pending penalty to be forgiven
+func (s *PenaltyService) AppealPenalty(penaltyID, userID uint, message string) (*models.PenaltyAppeal, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, errors.New("El motivo de la apelación es requerido")
+	}
+
+	penalty, err := s.pendingPenalty(penaltyID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	config.DB.Model(&models.PenaltyAppeal{}).
+		Where("penalty_id = ? AND status IN (?, ?)", penalty.ID, models.AppealPending, models.AppealDenied).
+		Count(&count)
+	if count > 0 {
+		return nil, errors.New("La penalización ya fue apelada")
+	}
+
+	appeal := models.PenaltyAppeal{
+		PenaltyID: penalty.ID,
+		UserID:    userID,
+		Message:   message,
+		Status:    models.AppealPending,
+	}
+	if err := config.DB.Create(&appeal).Error; err != nil {
+		return nil, err
+	}
+	return &appeal, nil
+}
+
+func (s *PenaltyService) GetAppeals(status string) ([]models.PenaltyAppeal, error) {
+	query := config.DB.Preload("User").Preload("Penalty.Reservation.Space")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var appeals []models.PenaltyAppeal
+	err := query.Order("created_at ASC").Find(&appeals).Error
+	return appeals, err
+}
+
+// ReviewAppeal approves (waiving the penalty) or denies a pending appeal
+func (s *PenaltyService) ReviewAppeal(appealID, adminID uint, approve bool, note string) (*models.PenaltyAppeal, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, errors.New("La nota de la revisión es requerida")
+	}
+
+	var appeal models.PenaltyAppeal
+	if err := config.DB.First(&appeal, appealID).Error; err != nil {
+		return nil, errors.New("Apelación no encontrada")
+	}
+	if appeal.Status != models.AppealPending {
+		return nil, errors.New("La apelación ya fue revisada")
+	}
+
+	now := time.Now()
+	appeal.Status = models.AppealDenied
+	if approve {
+		appeal.Status = models.AppealApproved
+	}
+	appeal.ReviewedBy = &adminID
+	appeal.ReviewNote = note
+	appeal.ReviewedAt = &now
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&appeal).Error; err != nil {
+			return err
+		}
+		if !approve {
+			return nil
+		}
+		result := tx.Model(&models.Penalty{}).
+			Where("id = ? AND status = ?", appeal.PenaltyID, models.PenaltyPending).
+			Update("status", models.PenaltyWaived)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("La penalización ya no está pendiente")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	config.DB.Preload("Penalty").First(&appeal, appeal.ID)
+	return &appeal, nil
+}
+
+// UnpaidCredits is the total of the user's pending penalties, leaving out
+// those with an appeal still under review
+func (s *PenaltyService) UnpaidCredits(userID uint) int {
+	var total int
+	config.DB.Model(&models.Penalty{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user_id = ? AND status = ?", userID, models.PenaltyPending).
+		Where("id NOT IN (?)", config.DB.Model(&models.PenaltyAppeal{}).
+			Select("penalty_id").
+			Where("status = ?", models.AppealPending)).
+		Scan(&total)
+	return total
+}
+
+// CheckBlocked returns an error when the penalty policy blocks the user from
+// making new reservations
+func (s *PenaltyService) CheckBlocked(userID uint) error {
+	policy := s.GetPolicy()
+	if !policy.BlockEnabled {
+		return nil
+	}
+
+	unpaid := s.UnpaidCredits(userID)
+	if unpaid > policy.BlockThreshold {
+		return fmt.Errorf("Tiene %d créditos en penalizaciones sin pagar; liquídelas para poder reservar", unpaid)
+	}
+	return nil
+}
+
+func (s *PenaltyService) GetPolicy() models.PenaltyPolicy {
+	var policy models.PenaltyPolicy
+	config.DB.First(&policy)
+	return policy
+}
+
+func (s *PenaltyService) UpdatePolicy(blockEnabled bool, blockThreshold int, adminID uint) (*models.PenaltyPolicy, error) {
+	if blockThreshold < 0 {
+		return nil, errors.New("El límite de penalizaciones no puede ser negativo")
+	}
+
+	policy := s.GetPolicy()
+	policy.BlockEnabled = blockEnabled
+	policy.BlockThreshold = blockThreshold
+	policy.UpdatedBy = &adminID
+
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// pendingPenalty loads a pending penalty, restricted to ownerID when non-zero
+func (s *PenaltyService) pendingPenalty(penaltyID, ownerID uint) (*models.Penalty, error) {
+	query := config.DB.Where("id = ?", penaltyID)
+	if ownerID > 0 {
+		query = query.Where("user_id = ?", ownerID)
+	}
+
+	var penalty models.Penalty
+	if err := query.First(&penalty).Error; err != nil {
+		return nil, errors.New("Penalización no encontrada")
+	}
+	if penalty.Status != models.PenaltyPending {
+		return nil, errors.New("La penalización ya no está pendiente")
+	}
+	return &penalty, nil
+}
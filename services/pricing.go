@@ -0,0 +1,291 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+// Credits added to reservations that require admin approval
+const specialReservationSurcharge = 1
+
+// PriceQuote is the credit cost of booking a space for a time range
+type PriceQuote struct {
+	SpaceID          uint               `json:"space_id"`
+	PricingMode      models.PricingMode `json:"pricing_mode"`
+	DurationMinutes  int                `json:"duration_minutes"`
+	BillableMinutes  int                `json:"billable_minutes"`
+	BillableUnits    int                `json:"billable_units"`
+	UnitCredits      int                `json:"unit_credits"`
+	BaseCredits      int                `json:"base_credits"`
+	Surcharge        int                `json:"surcharge"`
+	TotalCredits     int                `json:"total_credits"`
+	RequiresApproval bool               `json:"requires_approval"`
+	Breakdown        []PriceUnit        `json:"breakdown"`
+}
+
+// PriceUnit is the price of one billable hour/block (or of the whole
+// reservation for flat pricing) and the pricing rule that set it, if any
+type PriceUnit struct {
+	StartTime time.Time `json:"start_time"`
+	Credits   int       `json:"credits"`
+	RuleID    *uint     `json:"rule_id,omitempty"`
+	RuleName  string    `json:"rule_name,omitempty"`
+}
+
+// PricingService is the single place where reservation costs are computed
+type PricingService struct{}
+
+func NewPricingService() *PricingService {
+	return &PricingService{}
+}
+
+// Quote prices a reservation of the space between startTime and endTime
+func (s *PricingService) Quote(space *models.Space, startTime, endTime time.Time, requiresApproval bool) (*PriceQuote, error) {
+	return s.QuoteWithRules(space, s.LoadRules(space.ID), startTime, endTime, requiresApproval)
+}
+
+// QuoteWithRules prices a reservation using already loaded pricing rules, so
+// callers pricing many slots of the same space query the rules only once.
+// Each billable unit is priced by the rule active when that unit starts.
+func (s *PricingService) QuoteWithRules(space *models.Space, rules []models.SpacePricingRule, startTime, endTime time.Time, requiresApproval bool) (*PriceQuote, error) {
+	if !endTime.After(startTime) {
+		return nil, errors.New("La hora de inicio debe ser anterior a la hora de fin")
+	}
+
+	duration := int(math.Ceil(endTime.Sub(startTime).Minutes()))
+	billable := duration
+	if billable < space.MinBillableMinutes {
+		billable = space.MinBillableMinutes
+	}
+
+	quote := &PriceQuote{
+		SpaceID:          space.ID,
+		PricingMode:      space.PricingMode,
+		DurationMinutes:  duration,
+		BillableMinutes:  billable,
+		UnitCredits:      space.CostCredits,
+		RequiresApproval: requiresApproval,
+	}
+
+	unitMinutes := 0
+	switch space.PricingMode {
+	case models.PricingHourly:
+		unitMinutes = 60
+		quote.BillableUnits = billableUnits(billable, unitMinutes, space.RoundingMode)
+	case models.PricingBlock:
+		unitMinutes = space.BlockMinutes
+		if unitMinutes <= 0 {
+			unitMinutes = 60
+		}
+		quote.BillableUnits = billableUnits(billable, unitMinutes, space.RoundingMode)
+	default:
+		quote.PricingMode = models.PricingFlat
+		quote.BillableUnits = 1
+	}
+
+	quote.Breakdown = make([]PriceUnit, quote.BillableUnits)
+	for i := range quote.Breakdown {
+		unitStart := startTime.Add(time.Duration(i*unitMinutes) * time.Minute)
+		unit := PriceUnit{StartTime: unitStart, Credits: space.CostCredits}
+		if rule := matchPricingRule(rules, unitStart); rule != nil {
+			unit.Credits = applyPricingRule(rule, space.CostCredits)
+			unit.RuleID = &rule.ID
+			unit.RuleName = rule.Name
+		}
+		quote.Breakdown[i] = unit
+		quote.BaseCredits += unit.Credits
+	}
+	if requiresApproval {
+		quote.Surcharge = specialReservationSurcharge
+	}
+	quote.TotalCredits = quote.BaseCredits + quote.Surcharge
+
+	return quote, nil
+}
+
+// LoadRules returns the active pricing rules of a space, most specific first:
+// higher priority, then rules limited to a date range, then the newest
+func (s *PricingService) LoadRules(spaceID uint) []models.SpacePricingRule {
+	var rules []models.SpacePricingRule
+	config.DB.Where("space_id = ? AND is_active = ?", spaceID, true).
+		Order("priority DESC, start_date IS NULL, id DESC").
+		Find(&rules)
+	return rules
+}
+
+// matchPricingRule returns the first rule (rules are ordered by precedence)
+// whose weekly window and date range contain t
+func matchPricingRule(rules []models.SpacePricingRule, t time.Time) *models.SpacePricingRule {
+	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+	if err != nil {
+		loc = time.Local
+	}
+	local := t.In(loc)
+	clock := local.Format("15:04")
+	day := truncateToDay(local)
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.DayOfWeek != int(local.Weekday()) {
+			continue
+		}
+		if clock < rule.StartTime || clock >= rule.EndTime {
+			continue
+		}
+		if rule.StartDate != nil && day.Before(truncateToDay(rule.StartDate.In(loc))) {
+			continue
+		}
+		if rule.EndDate != nil && day.After(truncateToDay(rule.EndDate.In(loc))) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// applyPricingRule returns the credits charged for one unit under the rule
+func applyPricingRule(rule *models.SpacePricingRule, baseCredits int) int {
+	if rule.FixedCredits != nil {
+		return *rule.FixedCredits
+	}
+	if rule.Multiplier != nil {
+		return int(math.Round(float64(baseCredits) * *rule.Multiplier))
+	}
+	return baseCredits
+}
+
+func (s *PricingService) GetRules(spaceID uint) ([]models.SpacePricingRule, error) {
+	var rules []models.SpacePricingRule
+	err := config.DB.Where("space_id = ?", spaceID).
+		Order("day_of_week ASC, start_time ASC").
+		Find(&rules).Error
+
+	return rules, err
+}
+
+func (s *PricingService) CreateRule(rule *models.SpacePricingRule) error {
+	var space models.Space
+	if err := config.DB.First(&space, rule.SpaceID).Error; err != nil {
+		return errors.New("Espacio no encontrado")
+	}
+	if err := s.validateRule(rule); err != nil {
+		return err
+	}
+	return config.DB.Create(rule).Error
+}
+
+func (s *PricingService) UpdateRule(ruleID uint, changes *models.SpacePricingRule) (*models.SpacePricingRule, error) {
+	var rule models.SpacePricingRule
+	if err := config.DB.First(&rule, ruleID).Error; err != nil {
+		return nil, errors.New("Regla de precio no encontrada")
+	}
+
+	rule.Name = changes.Name
+	rule.DayOfWeek = changes.DayOfWeek
+	rule.StartTime = changes.StartTime
+	rule.EndTime = changes.EndTime
+	rule.StartDate = changes.StartDate
+	rule.EndDate = changes.EndDate
+	rule.Multiplier = changes.Multiplier
+	rule.FixedCredits = changes.FixedCredits
+	rule.Priority = changes.Priority
+	rule.IsActive = changes.IsActive
+
+	if err := s.validateRule(&rule); err != nil {
+		return nil, err
+	}
+	if err := config.DB.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *PricingService) DeleteRule(ruleID uint) error {
+	result := config.DB.Delete(&models.SpacePricingRule{}, ruleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Regla de precio no encontrada")
+	}
+	return nil
+}
+
+func (s *PricingService) validateRule(rule *models.SpacePricingRule) error {
+	if rule.DayOfWeek < 0 || rule.DayOfWeek > 6 {
+		return errors.New("Día de la semana inválido")
+	}
+	if _, err := time.Parse("15:04", rule.StartTime); err != nil {
+		return errors.New("Formato de hora de inicio inválido. Use HH:MM")
+	}
+	if _, err := time.Parse("15:04", rule.EndTime); err != nil {
+		return errors.New("Formato de hora de fin inválido. Use HH:MM")
+	}
+	if rule.StartTime >= rule.EndTime {
+		return errors.New("La hora de inicio debe ser anterior a la hora de fin")
+	}
+	if rule.StartDate != nil && rule.EndDate != nil && rule.EndDate.Before(*rule.StartDate) {
+		return errors.New("La fecha de fin debe ser posterior a la fecha de inicio")
+	}
+	if (rule.Multiplier == nil) == (rule.FixedCredits == nil) {
+		return errors.New("Indique un multiplicador o un costo fijo, pero no ambos")
+	}
+	if rule.Multiplier != nil && *rule.Multiplier < 0 {
+		return errors.New("El multiplicador no puede ser negativo")
+	}
+	if rule.FixedCredits != nil && *rule.FixedCredits < 0 {
+		return errors.New("El costo fijo no puede ser negativo")
+	}
+	return nil
+}
+
+// billableUnits converts minutes to hours/blocks using the space's rounding
+// rule. A booking is always billed at least one unit.
+func billableUnits(minutes, unitMinutes int, rounding models.RoundingMode) int {
+	units := float64(minutes) / float64(unitMinutes)
+
+	var result float64
+	switch rounding {
+	case models.RoundDown:
+		result = math.Floor(units)
+	case models.RoundNearest:
+		result = math.Round(units)
+	default:
+		result = math.Ceil(units)
+	}
+
+	if result < 1 {
+		return 1
+	}
+	return int(result)
+}
+
+// ValidatePricing checks a space's pricing settings before saving them
+func (s *PricingService) ValidatePricing(space *models.Space) error {
+	switch space.PricingMode {
+	case models.PricingFlat, models.PricingHourly, models.PricingBlock:
+	default:
+		return errors.New("Modo de precio inválido. Use: flat, hourly, block")
+	}
+
+	switch space.RoundingMode {
+	case models.RoundUp, models.RoundDown, models.RoundNearest:
+	default:
+		return errors.New("Modo de redondeo inválido. Use: up, down, nearest")
+	}
+
+	if space.PricingMode == models.PricingBlock && space.BlockMinutes <= 0 {
+		return errors.New("La duración del bloque debe ser positiva")
+	}
+	if space.MinBillableMinutes < 0 {
+		return errors.New("La duración mínima facturable no puede ser negativa")
+	}
+	if space.CostCredits < 0 {
+		return errors.New("El costo en créditos no puede ser negativo")
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // matchPricingRule reads rules in Mexico City time
+
+	"github.com/IkingariSolorzano/omma-be/models"
+)
+
+func TestBillableUnits(t *testing.T) {
+	tests := []struct {
+		name        string
+		minutes     int
+		unitMinutes int
+		rounding    models.RoundingMode
+		want        int
+	}{
+		{"exact hours", 120, 60, models.RoundUp, 2},
+		{"partial hour rounds up", 61, 60, models.RoundUp, 2},
+		{"unknown rounding rounds up", 61, 60, "", 2},
+		{"partial hour rounds down", 119, 60, models.RoundDown, 1},
+		{"nearest below half", 89, 60, models.RoundNearest, 1},
+		{"nearest at half", 90, 60, models.RoundNearest, 2},
+		{"blocks", 100, 45, models.RoundUp, 3},
+		{"down never bills zero", 30, 60, models.RoundDown, 1},
+		{"nearest never bills zero", 10, 60, models.RoundNearest, 1},
+		{"zero minutes", 0, 60, models.RoundUp, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := billableUnits(tt.minutes, tt.unitMinutes, tt.rounding); got != tt.want {
+				t.Fatalf("billableUnits(%d, %d, %q) = %d, want %d", tt.minutes, tt.unitMinutes, tt.rounding, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteWithRulesWithoutRules(t *testing.T) {
+	start := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		space            models.Space
+		duration         time.Duration
+		requiresApproval bool
+		wantMode         models.PricingMode
+		wantBillable     int
+		wantUnits        int
+		wantTotal        int
+	}{
+		{"flat", models.Space{PricingMode: models.PricingFlat, CostCredits: 5}, 3 * time.Hour, false, models.PricingFlat, 180, 1, 5},
+		{"unknown mode is flat", models.Space{PricingMode: "", CostCredits: 5}, 3 * time.Hour, false, models.PricingFlat, 180, 1, 5},
+		{"hourly rounds up", models.Space{PricingMode: models.PricingHourly, RoundingMode: models.RoundUp, CostCredits: 2}, 90 * time.Minute, false, models.PricingHourly, 90, 2, 4},
+		{"hourly minimum", models.Space{PricingMode: models.PricingHourly, RoundingMode: models.RoundUp, CostCredits: 2, MinBillableMinutes: 120}, 30 * time.Minute, false, models.PricingHourly, 120, 2, 4},
+		{"blocks", models.Space{PricingMode: models.PricingBlock, RoundingMode: models.RoundDown, BlockMinutes: 30, CostCredits: 1}, 100 * time.Minute, false, models.PricingBlock, 100, 3, 3},
+		{"approval surcharge", models.Space{PricingMode: models.PricingHourly, RoundingMode: models.RoundUp, CostCredits: 2}, time.Hour, true, models.PricingHourly, 60, 1, 2 + specialReservationSurcharge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := NewPricingService().QuoteWithRules(&tt.space, nil, start, start.Add(tt.duration), tt.requiresApproval)
+			if err != nil {
+				t.Fatalf("QuoteWithRules: %v", err)
+			}
+			if quote.PricingMode != tt.wantMode || quote.BillableMinutes != tt.wantBillable || quote.BillableUnits != tt.wantUnits {
+				t.Fatalf("quote = %s, %d minutes, %d units; want %s, %d, %d",
+					quote.PricingMode, quote.BillableMinutes, quote.BillableUnits, tt.wantMode, tt.wantBillable, tt.wantUnits)
+			}
+			if len(quote.Breakdown) != tt.wantUnits {
+				t.Fatalf("breakdown has %d units, want %d", len(quote.Breakdown), tt.wantUnits)
+			}
+			if quote.TotalCredits != tt.wantTotal || quote.BaseCredits+quote.Surcharge != quote.TotalCredits {
+				t.Fatalf("total = %d (base %d + surcharge %d), want %d", quote.TotalCredits, quote.BaseCredits, quote.Surcharge, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestQuoteWithRulesRejectsEmptyRange(t *testing.T) {
+	start := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	space := &models.Space{PricingMode: models.PricingHourly, CostCredits: 2}
+
+	for _, end := range []time.Time{start, start.Add(-time.Hour)} {
+		if _, err := NewPricingService().QuoteWithRules(space, nil, start, end, false); err == nil {
+			t.Fatalf("QuoteWithRules(%s, %s) succeeded, want an error", start, end)
+		}
+	}
+}
+
+// mexicoCity is the time zone the pricing rules are written in
+func mexicoCity(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/Mexico_City")
+	if err != nil {
+		t.Fatalf("time.LoadLocation: %v", err)
+	}
+	return loc
+}
+
+func TestMatchPricingRule(t *testing.T) {
+	loc := mexicoCity(t)
+	day := func(d int) *time.Time {
+		date := time.Date(2026, 3, d, 0, 0, 0, 0, loc)
+		return &date
+	}
+
+	// Ordered by precedence, as LoadRules returns them
+	rules := []models.SpacePricingRule{
+		{ID: 1, Name: "Promoción de marzo", DayOfWeek: 1, StartTime: "18:00", EndTime: "21:00", StartDate: day(9), EndDate: day(16)},
+		{ID: 2, Name: "Hora pico", DayOfWeek: 1, StartTime: "18:00", EndTime: "21:00"},
+		{ID: 3, Name: "Mañana de sábado", DayOfWeek: 6, StartTime: "08:00", EndTime: "12:00"},
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want uint
+	}{
+		{"monday peak", time.Date(2026, 3, 2, 18, 0, 0, 0, loc), 2},
+		{"monday before peak", time.Date(2026, 3, 2, 17, 59, 0, 0, loc), 0},
+		{"end time is exclusive", time.Date(2026, 3, 2, 21, 0, 0, 0, loc), 0},
+		{"date range wins by order", time.Date(2026, 3, 9, 19, 0, 0, 0, loc), 1},
+		{"range end is inclusive", time.Date(2026, 3, 16, 20, 59, 0, 0, loc), 1},
+		{"after the range", time.Date(2026, 3, 23, 19, 0, 0, 0, loc), 2},
+		{"other weekday", time.Date(2026, 3, 3, 19, 0, 0, 0, loc), 0},
+		{"saturday", time.Date(2026, 3, 7, 9, 30, 0, 0, loc), 3},
+		{"utc instant in local time", time.Date(2026, 3, 3, 1, 0, 0, 0, time.UTC), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got uint
+			if rule := matchPricingRule(rules, tt.at); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.want {
+				t.Fatalf("matchPricingRule(%s) = rule %d, want %d", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPricingRule(t *testing.T) {
+	multiplier := 1.5
+	fixed := 3
+	tests := []struct {
+		name string
+		rule models.SpacePricingRule
+		want int
+	}{
+		{"multiplier", models.SpacePricingRule{Multiplier: &multiplier}, 6},
+		{"fixed", models.SpacePricingRule{FixedCredits: &fixed}, 3},
+		{"neither", models.SpacePricingRule{}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyPricingRule(&tt.rule, 4); got != tt.want {
+				t.Fatalf("applyPricingRule() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// Each hour is priced by the rule active when it starts
+func TestQuoteWithRulesPricesEachUnit(t *testing.T) {
+	loc := mexicoCity(t)
+	multiplier := 2.0
+	rules := []models.SpacePricingRule{
+		{ID: 2, Name: "Hora pico", DayOfWeek: 1, StartTime: "18:00", EndTime: "21:00", Multiplier: &multiplier},
+	}
+	space := &models.Space{PricingMode: models.PricingHourly, RoundingMode: models.RoundUp, CostCredits: 2}
+	start := time.Date(2026, 3, 2, 17, 0, 0, 0, loc)
+
+	quote, err := NewPricingService().QuoteWithRules(space, rules, start, start.Add(3*time.Hour), false)
+	if err != nil {
+		t.Fatalf("QuoteWithRules: %v", err)
+	}
+
+	wantCredits := []int{2, 4, 4}
+	if len(quote.Breakdown) != len(wantCredits) {
+		t.Fatalf("breakdown has %d units, want %d", len(quote.Breakdown), len(wantCredits))
+	}
+	for i, unit := range quote.Breakdown {
+		if unit.Credits != wantCredits[i] {
+			t.Fatalf("unit %d costs %d, want %d", i, unit.Credits, wantCredits[i])
+		}
+		if (unit.RuleID != nil) != (i > 0) {
+			t.Fatalf("unit %d rule = %v, want a rule: %v", i, unit.RuleID, i > 0)
+		}
+	}
+	if quote.TotalCredits != 10 {
+		t.Fatalf("TotalCredits = %d, want 10", quote.TotalCredits)
+	}
+}
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// promoCodeAlphabet leaves out characters that are easy to misread
+const promoCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// generatePromoCode returns a random code for vouchers and codes created
+// without one
+func generatePromoCode() (string, error) {
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(promoCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = promoCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PromoCodeSummary totals the redemptions of one code
+type PromoCodeSummary struct {
+	PromoCodeID uint                 `json:"promo_code_id"`
+	Code        string               `json:"code"`
+	Kind        models.PromoCodeKind `json:"kind"`
+	Redemptions int                  `json:"redemptions"`
+	Credits     int                  `json:"credits"`
+}
+
+// RedemptionReport lists the redemptions in a date range with per-code totals
+type RedemptionReport struct {
+	From         *time.Time               `json:"from"`
+	To           *time.Time               `json:"to"`
+	TotalCredits int                      `json:"total_credits"`
+	Codes        []PromoCodeSummary       `json:"codes"`
+	Redemptions  []models.PromoRedemption `json:"redemptions"`
+}
+
+type PromoCodeService struct {
+	creditService *CreditService
+}
+
+func NewPromoCodeService() *PromoCodeService {
+	return &PromoCodeService{
+		creditService: NewCreditService(),
+	}
+}
+
+func (s *PromoCodeService) GetCodes() ([]models.PromoCode, error) {
+	var codes []models.PromoCode
+	err := config.DB.Order("created_at DESC").Find(&codes).Error
+	return codes, err
+}
+
+// CreateCode creates a promo code, generating one when none is given
+func (s *PromoCodeService) CreateCode(code *models.PromoCode) error {
+	code.Code = normalizePromoCode(code.Code)
+	if code.Code == "" {
+		generated, err := generatePromoCode()
+		if err != nil {
+			return err
+		}
+		code.Code = generated
+	}
+	if err := s.validateCode(code); err != nil {
+		return err
+	}
+
+	var existing int64
+	config.DB.Unscoped().Model(&models.PromoCode{}).Where("code = ?", code.Code).Count(&existing)
+	if existing > 0 {
+		return errors.New("El código ya existe")
+	}
+
+	return config.DB.Create(code).Error
+}
+
+// UpdateCode changes the limits, expiry and status of a code. Its code, kind
+// and value stay as they were when it was handed out.
+func (s *PromoCodeService) UpdateCode(codeID uint, changes *models.PromoCode) (*models.PromoCode, error) {
+	var code models.PromoCode
+	if err := config.DB.First(&code, codeID).Error; err != nil {
+		return nil, errors.New("Código no encontrado")
+	}
+
+	code.Description = changes.Description
+	code.MaxRedemptions = changes.MaxRedemptions
+	code.PerUserLimit = changes.PerUserLimit
+	code.ExpiresAt = changes.ExpiresAt
+	code.IsActive = changes.IsActive
+
+	if err := s.validateCode(&code); err != nil {
+		return nil, err
+	}
+	if code.MaxRedemptions > 0 && code.MaxRedemptions < code.RedemptionCount {
+		return nil, errors.New("El límite de usos no puede ser menor a los usos registrados")
+	}
+	if err := config.DB.Save(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (s *PromoCodeService) validateCode(code *models.PromoCode) error {
+	switch code.Kind {
+	case models.PromoCodeCredits:
+		if code.Credits <= 0 {
+			return errors.New("Los créditos del código deben ser positivos")
+		}
+		if code.ValidityDays <= 0 {
+			code.ValidityDays = defaultCreditValidityDays
+		}
+		code.BonusPercent = 0
+	case models.PromoCodeBonus:
+		if code.BonusPercent <= 0 || code.BonusPercent > 100 {
+			return errors.New("El porcentaje de bonificación debe estar entre 0 y 100")
+		}
+		code.Credits = 0
+	default:
+		return errors.New("Tipo de código inválido")
+	}
+	if code.MaxRedemptions < 0 || code.PerUserLimit < 0 {
+		return errors.New("Los límites de uso no pueden ser negativos")
+	}
+	return nil
+}
+
+// usableCode locks the code and checks that the user may redeem it now
+func (s *PromoCodeService) usableCode(tx *gorm.DB, rawCode string, userID uint, kind models.PromoCodeKind) (*models.PromoCode, error) {
+	var code models.PromoCode
+	if err := forUpdate(tx).Where("code = ?", normalizePromoCode(rawCode)).First(&code).Error; err != nil {
+		return nil, errors.New("Código no encontrado")
+	}
+	if code.Kind != kind {
+		if kind == models.PromoCodeBonus {
+			return nil, errors.New("El código no es una bonificación sobre pagos")
+		}
+		return nil, errors.New("El código solo aplica al registrar un pago")
+	}
+	if !code.IsActive {
+		return nil, errors.New("El código no está activo")
+	}
+	if code.ExpiresAt != nil && !code.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("El código ha expirado")
+	}
+	if code.MaxRedemptions > 0 && code.RedemptionCount >= code.MaxRedemptions {
+		return nil, errors.New("El código ya no tiene usos disponibles")
+	}
+	if code.PerUserLimit > 0 {
+		var used int64
+		if err := tx.Model(&models.PromoRedemption{}).
+			Where("promo_code_id = ? AND user_id = ? AND reversed_at IS NULL", code.ID, userID).
+			Count(&used).Error; err != nil {
+			return nil, err
+		}
+		if int(used) >= code.PerUserLimit {
+			return nil, errors.New("Ya usaste este código el máximo de veces permitido")
+		}
+	}
+	return &code, nil
+}
+
+// redeem grants the credits of a redemption as a new lot and records it
+func (s *PromoCodeService) redeem(tx *gorm.DB, code *models.PromoCode, userID uint, credits int, expiry time.Time, paymentID *uint) (*models.PromoRedemption, error) {
+	reason := "Canje del código " + code.Code
+	if code.PurchasedBy != nil {
+		reason = "Canje del vale de regalo " + code.Code
+	}
+
+	lot, err := s.creditService.grantLot(tx, userID, credits, expiry, CreditEntry{
+		Type:      models.TransactionTypePromo,
+		Reason:    reason,
+		PaymentID: paymentID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	redemption := models.PromoRedemption{
+		PromoCodeID: code.ID,
+		UserID:      userID,
+		Credits:     credits,
+		CreditID:    &lot.ID,
+		PaymentID:   paymentID,
+	}
+	if err := tx.Create(&redemption).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(code).Update("redemption_count", gorm.Expr("redemption_count + 1")).Error; err != nil {
+		return nil, err
+	}
+	return &redemption, nil
+}
+
+// reverseBonus gives back the use of the bonus codes applied to a fully
+// refunded payment. Their lots are clawed back with the purchased one.
+func (s *PromoCodeService) reverseBonus(tx *gorm.DB, paymentID uint) error {
+	var redemptions []models.PromoRedemption
+	if err := tx.Where("payment_id = ? AND reversed_at IS NULL", paymentID).Find(&redemptions).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, redemption := range redemptions {
+		if err := tx.Model(&redemption).Update("reversed_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PromoCode{}).
+			Where("id = ? AND redemption_count > 0", redemption.PromoCodeID).
+			Update("redemption_count", gorm.Expr("redemption_count - 1")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedeemCode redeems a credits code or gift voucher for the user
+func (s *PromoCodeService) RedeemCode(rawCode string, userID uint) (*models.PromoRedemption, error) {
+	var redemption *models.PromoRedemption
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		code, err := s.usableCode(tx, rawCode, userID, models.PromoCodeCredits)
+		if err != nil {
+			return err
+		}
+		redemption, err = s.redeem(tx, code, userID, code.Credits, time.Now().AddDate(0, 0, code.ValidityDays), nil)
+		if err != nil {
+			return err
+		}
+		redemption.PromoCode = *code
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return redemption, nil
+}
+
+// applyBonus redeems a bonus code on a payment inside the payment's
+// transaction. The bonus is a separate lot expiring with the purchased one.
+// Percentages round down to whole credits; a bonus under one credit is
+// rejected rather than used up for nothing.
+func (s *PromoCodeService) applyBonus(tx *gorm.DB, rawCode string, userID uint, paidCredits int, expiry time.Time, paymentID uint) (*models.PromoRedemption, error) {
+	code, err := s.usableCode(tx, rawCode, userID, models.PromoCodeBonus)
+	if err != nil {
+		return nil, err
+	}
+
+	credits := int(math.Floor(float64(paidCredits) * code.BonusPercent / 100))
+	if credits < 1 {
+		return nil, errors.New("El pago es demasiado pequeño para la bonificación del código")
+	}
+
+	return s.redeem(tx, code, userID, credits, expiry, &paymentID)
+}
+
+// SellVoucher registers the payment of a professional buying a gift voucher
+// and creates its single-use code. The buyer gets no credits; whoever redeems
+// the code does.
+func (s *PromoCodeService) SellVoucher(buyerID, adminID uint, credits int, expiresAt *time.Time, paymentMethod, reference, notes string) (*models.PromoCode, *models.Payment, error) {
+	if credits <= 0 {
+		return nil, nil, errors.New("Los créditos del vale deben ser positivos")
+	}
+
+	var buyer models.User
+	if err := config.DB.First(&buyer, buyerID).Error; err != nil {
+		return nil, nil, errors.New("Usuario no encontrado")
+	}
+
+	generated, err := generatePromoCode()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unitPrice := creditUnitPrice()
+	payment := models.Payment{
+		UserID:         buyerID,
+		AdminID:        &adminID,
+		Amount:         unitPrice.Times(credits),
+		Currency:       models.DefaultCurrency,
+		CreditsGranted: 0,
+		CreditCost:     unitPrice,
+		PaymentMethod:  paymentMethod,
+		Reference:      reference,
+		Notes:          notes,
+	}
+	voucher := models.PromoCode{
+		Code:           generated,
+		Description:    "Vale de regalo comprado por " + buyer.Name,
+		Kind:           models.PromoCodeCredits,
+		Credits:        credits,
+		ValidityDays:   defaultCreditValidityDays,
+		MaxRedemptions: 1,
+		PerUserLimit:   1,
+		ExpiresAt:      expiresAt,
+		IsActive:       true,
+		PurchasedBy:    &buyerID,
+		CreatedBy:      adminID,
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		voucher.PaymentID = &payment.ID
+		return tx.Create(&voucher).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &voucher, &payment, nil
+}
+
+// GetRedemptionReport lists the redemptions between from and to (either may
+// be nil), optionally for a single code, with totals per code
+func (s *PromoCodeService) GetRedemptionReport(from, to *time.Time, codeID uint) (*RedemptionReport, error) {
+	query := config.DB.Preload("PromoCode", withDeleted).Preload("User")
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at <= ?", *to)
+	}
+	if codeID != 0 {
+		query = query.Where("promo_code_id = ?", codeID)
+	}
+
+	var redemptions []models.PromoRedemption
+	if err := query.Order("created_at DESC").Find(&redemptions).Error; err != nil {
+		return nil, err
+	}
+
+	report := &RedemptionReport{
+		From:        from,
+		To:          to,
+		Codes:       []PromoCodeSummary{},
+		Redemptions: redemptions,
+	}
+	byCode := make(map[uint]int)
+	for _, redemption := range redemptions {
+		// Reversed redemptions are listed but no longer count
+		if redemption.ReversedAt != nil {
+			continue
+		}
+		i, ok := byCode[redemption.PromoCodeID]
+		if !ok {
+			i = len(report.Codes)
+			byCode[redemption.PromoCodeID] = i
+			report.Codes = append(report.Codes, PromoCodeSummary{
+				PromoCodeID: redemption.PromoCodeID,
+				Code:        redemption.PromoCode.Code,
+				Kind:        redemption.PromoCode.Kind,
+			})
+		}
+		report.Codes[i].Redemptions++
+		report.Codes[i].Credits += redemption.Credits
+		report.TotalCredits += redemption.Credits
+	}
+
+	return report, nil
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// LotDrift is a credit lot whose spendable amount disagrees with the sum of
+// its ledger entries
+type LotDrift struct {
+	CreditID      uint      `json:"credit_id"`
+	Amount        int       `json:"amount"`
+	IsActive      bool      `json:"is_active"`
+	ExpiryDate    time.Time `json:"expiry_date"`
+	Deleted       bool      `json:"deleted"`
+	LotBalance    int       `json:"lot_balance"`    // What the lot holds: its amount while active, 0 otherwise
+	LedgerBalance int       `json:"ledger_balance"` // Sum of the lot's ledger entries
+	Drift         int       `json:"drift"`          // LotBalance - LedgerBalance
+}
+
+// UserDrift is a user whose lot totals disagree with their ledger balance
+type UserDrift struct {
+	UserID        uint       `json:"user_id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	LotBalance    int        `json:"lot_balance"`
+	LedgerBalance int        `json:"ledger_balance"`
+	Drift         int        `json:"drift"`
+	Lots          []LotDrift `json:"lots"`
+}
+
+// ReconciliationReport lists every user whose credit lots drifted from the ledger
+type ReconciliationReport struct {
+	GeneratedAt  time.Time   `json:"generated_at"`
+	UsersChecked int         `json:"users_checked"`
+	LotsChecked  int         `json:"lots_checked"`
+	Drifts       []UserDrift `json:"drifts"`
+}
+
+type ReconciliationService struct{}
+
+func NewReconciliationService() *ReconciliationService {
+	return &ReconciliationService{}
+}
+
+type lotRow struct {
+	ID         uint
+	UserID     uint
+	Amount     int
+	IsActive   bool
+	ExpiryDate time.Time
+	DeletedAt  gorm.DeletedAt
+}
+
+type ledgerRow struct {
+	CreditID uint
+	UserID   uint
+	Balance  int
+}
+
+// lotBalance is what a lot holds according to the lots table
+func (l lotRow) lotBalance() int {
+	if !l.IsActive || l.DeletedAt.Valid {
+		return 0
+	}
+	return l.Amount
+}
+
+// Reconcile recomputes every lot's balance from the ledger (purchases,
+// grants, deductions, refunds, transfers, expirations...) and reports the
+// users whose lots disagree, with the offending lots
+func (s *ReconciliationService) Reconcile() (*ReconciliationReport, error) {
+	return s.reconcile(config.DB, nil)
+}
+
+func (s *ReconciliationService) reconcile(db *gorm.DB, creditID *uint) (*ReconciliationReport, error) {
+	lotQuery := db.Unscoped().Model(&models.Credit{}).
+		Select("id, user_id, amount, is_active, expiry_date, deleted_at")
+	ledgerQuery := db.Model(&models.CreditTransaction{}).
+		Select("credit_id, user_id, COALESCE(SUM(amount), 0) AS balance").
+		Where("type <> ? AND credit_id IS NOT NULL", models.TransactionTypeLegacy).
+		Group("credit_id, user_id")
+	if creditID != nil {
+		lotQuery = lotQuery.Where("id = ?", *creditID)
+		ledgerQuery = ledgerQuery.Where("credit_id = ?", *creditID)
+	}
+
+	var lots []lotRow
+	if err := lotQuery.Scan(&lots).Error; err != nil {
+		return nil, err
+	}
+	var ledger []ledgerRow
+	if err := ledgerQuery.Scan(&ledger).Error; err != nil {
+		return nil, err
+	}
+
+	ledgerByLot := make(map[uint]int, len(ledger))
+	for _, row := range ledger {
+		ledgerByLot[row.CreditID] += row.Balance
+	}
+
+	users := make(map[uint]*UserDrift)
+	userFor := func(userID uint) *UserDrift {
+		if users[userID] == nil {
+			users[userID] = &UserDrift{UserID: userID}
+		}
+		return users[userID]
+	}
+
+	seen := make(map[uint]bool, len(lots))
+	for _, lot := range lots {
+		seen[lot.ID] = true
+		user := userFor(lot.UserID)
+		lotBalance := lot.lotBalance()
+		ledgerBalance := ledgerByLot[lot.ID]
+		user.LotBalance += lotBalance
+		user.LedgerBalance += ledgerBalance
+
+		if lotBalance != ledgerBalance {
+			user.Lots = append(user.Lots, LotDrift{
+				CreditID:      lot.ID,
+				Amount:        lot.Amount,
+				IsActive:      lot.IsActive,
+				ExpiryDate:    lot.ExpiryDate,
+				Deleted:       lot.DeletedAt.Valid,
+				LotBalance:    lotBalance,
+				LedgerBalance: ledgerBalance,
+				Drift:         lotBalance - ledgerBalance,
+			})
+		}
+	}
+
+	// Ledger entries pointing at a lot that no longer exists at all
+	for _, row := range ledger {
+		if seen[row.CreditID] {
+			continue
+		}
+		user := userFor(row.UserID)
+		user.LedgerBalance += row.Balance
+		if row.Balance != 0 {
+			user.Lots = append(user.Lots, LotDrift{
+				CreditID:      row.CreditID,
+				Deleted:       true,
+				LedgerBalance: row.Balance,
+				Drift:         -row.Balance,
+			})
+		}
+	}
+
+	report := &ReconciliationReport{
+		GeneratedAt:  time.Now(),
+		UsersChecked: len(users),
+		LotsChecked:  len(lots),
+		Drifts:       []UserDrift{},
+	}
+
+	var driftUserIDs []uint
+	for userID, user := range users {
+		if len(user.Lots) == 0 {
+			continue
+		}
+		user.Drift = user.LotBalance - user.LedgerBalance
+		driftUserIDs = append(driftUserIDs, userID)
+	}
+	sort.Slice(driftUserIDs, func(i, j int) bool { return driftUserIDs[i] < driftUserIDs[j] })
+
+	var names []models.User
+	if len(driftUserIDs) > 0 {
+		db.Select("id, name, email").Where("id IN ?", driftUserIDs).Find(&names)
+	}
+	for _, u := range names {
+		users[u.ID].Name = u.Name
+		users[u.ID].Email = u.Email
+	}
+
+	for _, userID := range driftUserIDs {
+		report.Drifts = append(report.Drifts, *users[userID])
+	}
+
+	return report, nil
+}
+
+// RunReconciliation is the scheduled check: it logs every user with drift and
+// returns how many there are
+func (s *ReconciliationService) RunReconciliation() (int64, error) {
+	report, err := s.Reconcile()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, drift := range report.Drifts {
+		log.Printf("Descuadre de créditos: usuario %d, lotes %d, libro %d, %d lote(s) afectados",
+			drift.UserID, drift.LotBalance, drift.LedgerBalance, len(drift.Lots))
+	}
+	return int64(len(report.Drifts)), nil
+}
+
+// PostCorrection posts a correction entry for a drifted lot so its ledger
+// balance matches what the lot holds. The lot itself is not changed; if the
+// lot is what is wrong, adjust it afterwards through the lot endpoints.
+func (s *ReconciliationService) PostCorrection(creditID, adminID uint, reason string) (*models.CreditTransaction, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, errors.New("El motivo de la corrección es requerido")
+	}
+
+	var entry *models.CreditTransaction
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		report, err := s.reconcile(tx, &creditID)
+		if err != nil {
+			return err
+		}
+		if len(report.Drifts) == 0 {
+			if report.LotsChecked == 0 {
+				return errors.New("Lote no encontrado")
+			}
+			return errors.New("El lote no tiene diferencias con el libro de créditos")
+		}
+
+		drift := report.Drifts[0]
+		entry = &models.CreditTransaction{
+			UserID:   drift.UserID,
+			Amount:   drift.Lots[0].Drift,
+			Type:     models.TransactionTypeCorrection,
+			Reason:   reason,
+			CreditID: &creditID,
+			AdminID:  &adminID,
+		}
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
@@ -3,52 +3,76 @@ package services
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/IkingariSolorzano/omma-be/config"
 	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/websocket"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
 )
 
+// ErrPeriodReserved is returned when the requested period overlaps an active reservation
+var ErrPeriodReserved = errors.New("Periodo ya reservado")
+
+// reservationOverlapConstraint is the exclusion constraint that keeps active
+// reservations of the same space from overlapping (see migration 00003)
+const reservationOverlapConstraint = "reservations_no_overlap"
+
+// MapReservationError translates a violation of the reservation overlap
+// constraint into ErrPeriodReserved, leaving any other error untouched. The
+// COUNT check in checkReservationConflicts gives a friendly early answer, but
+// only the constraint is safe against concurrent bookings.
+func MapReservationError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == reservationOverlapConstraint {
+		return ErrPeriodReserved
+	}
+	return err
+}
+
 type ReservationService struct {
-	creditService *CreditService
+	creditService  *CreditService
+	policyService  *CancellationPolicyService
+	pricingService *PricingService
+	bookingService *BookingService
 }
 
 func NewReservationService() *ReservationService {
 	return &ReservationService{
-		creditService: NewCreditService(),
+		creditService:  NewCreditService(),
+		policyService:  NewCancellationPolicyService(),
+		pricingService: NewPricingService(),
+		bookingService: NewBookingService(),
 	}
 }
 
-func (s *ReservationService) CreateReservation(userID, spaceID uint, startTime, endTime time.Time) (*models.Reservation, error) {
-	// Get space details
-	var space models.Space
-	if err := config.DB.First(&space, spaceID).Error; err != nil {
-		return nil, errors.New("Espacio no encontrado")
-	}
+// CreateReservation books a space and returns the reservation together with
+// the price quote (including the per-slot breakdown) that was charged
+func (s *ReservationService) CreateReservation(userID, spaceID uint, startTime, endTime time.Time) (*models.Reservation, *PriceQuote, error) {
+	return s.createReservation(userID, spaceID, startTime, endTime, nil)
+}
 
-	// Check if user has enough credits
-	availableCredits, err := s.creditService.GetActiveCredits(userID)
+// createReservation holds the booking rules shared by single reservations and
+// the occurrences generated by a ReservationSeries. Bookings outside hours or
+// schedules are not rejected; they become special reservations that need approval.
+func (s *ReservationService) createReservation(userID, spaceID uint, startTime, endTime time.Time, seriesID *uint) (*models.Reservation, *PriceQuote, error) {
+	eval, err := s.bookingService.Evaluate(BookingRequest{
+		SpaceID:   spaceID,
+		StartTime: startTime,
+		EndTime:   endTime,
+		UserID:    &userID,
+	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-
-	if availableCredits < space.CostCredits {
-		return nil, errors.New("Creditos insuficientes")
-	}
-
-	// Check for conflicts
-	if err := s.checkReservationConflicts(spaceID, startTime, endTime, 0); err != nil {
-		return nil, err
+	if err := eval.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	// Check if reservation is within allowed schedule and business hours
-	requiresApproval := s.requiresApproval(spaceID, startTime, endTime)
-
-	// Calculate cost: add +1 credit surcharge for special reservations
-	totalCredits := space.CostCredits
-	if requiresApproval {
-		totalCredits += 1 // Special reservation surcharge
-	}
+	requiresApproval := eval.RequiresApproval
+	totalCredits := eval.Quote.TotalCredits
 
 	reservation := models.Reservation{
 		UserID:           &userID,
@@ -58,158 +82,282 @@ func (s *ReservationService) CreateReservation(userID, spaceID uint, startTime,
 		Status:           models.StatusPending,
 		CreditsUsed:      totalCredits,
 		RequiresApproval: requiresApproval,
+		SeriesID:         seriesID,
 	}
 
 	if !requiresApproval {
 		reservation.Status = models.StatusConfirmed
-		// Deduct credits immediately for confirmed reservations
-		if err := s.creditService.DeductCredits(userID, totalCredits); err != nil {
-			return nil, err
-		}
 	}
 
-	if err := config.DB.Create(&reservation).Error; err != nil {
-		return nil, err
+	reason := "Reservación especial, requiere aprobación"
+	creditDelta := 0
+	if !requiresApproval {
+		reason = "Reservación confirmada"
+		creditDelta = totalCredits
 	}
 
-	return &reservation, nil
-}
+	// The insert, the charge and the history entry commit together. Inserting
+	// first lets the overlap constraint reject a concurrent booking of the same
+	// period before the user's lots are locked.
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&reservation).Error; err != nil {
+			return MapReservationError(err)
+		}
 
-func (s *ReservationService) checkReservationConflicts(spaceID uint, startTime, endTime time.Time, excludeID uint) error {
-	var count int64
-	query := config.DB.Model(&models.Reservation{}).
-		Where("space_id = ? AND status IN (?, ?) AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?))",
-			spaceID, models.StatusPending, models.StatusConfirmed,
-			startTime, startTime, endTime, endTime)
+		if !requiresApproval {
+			// Deduct credits immediately for confirmed reservations
+			if err := s.creditService.DeductCredits(tx, userID, totalCredits, CreditEntry{
+				Reason:        "Cargo por reservación",
+				ReservationID: &reservation.ID,
+			}); err != nil {
+				return err
+			}
+		} else if err := s.creditService.PlaceHold(tx, userID, reservation.ID, totalCredits); err != nil {
+			// Special reservations hold their credits until approved
+			return err
+		}
 
-	if excludeID > 0 {
-		query = query.Where("id != ?", excludeID)
+		return recordReservationCreated(tx, &reservation, &userID, reason, creditDelta)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	query.Count(&count)
+	return &reservation, eval.Quote, nil
+}
 
-	if count > 0 {
-		return errors.New("Periodo ya reservado")
+// QuoteReservation returns the exact credit cost of a reservation, including
+// the special reservation surcharge, without booking it
+func (s *ReservationService) QuoteReservation(spaceID uint, startTime, endTime time.Time) (*PriceQuote, error) {
+	eval, err := s.bookingService.Evaluate(BookingRequest{
+		SpaceID:   spaceID,
+		StartTime: startTime,
+		EndTime:   endTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if eval.Quote == nil {
+		return nil, eval.Err()
 	}
+	return eval.Quote, nil
+}
 
-	return nil
+// ExternalReservationInput is an admin booking for a client without an account
+type ExternalReservationInput struct {
+	ExternalClientID uint
+	SpaceID          uint
+	StartTime        time.Time
+	EndTime          time.Time
+	Status           models.ReservationStatus
+	Notes            string
+	AdminID          uint
+	// Override books even when overridable rules (hours, closed dates,
+	// schedules) fail
+	Override bool
 }
 
-func (s *ReservationService) requiresApproval(spaceID uint, startTime, endTime time.Time) bool {
-	// DEBUG: Add logging to understand timezone handling
-	fmt.Printf("DEBUG requiresApproval - spaceID: %d, startTime: %s (location: %s), endTime: %s (location: %s)\n", 
-		spaceID, startTime.Format("2006-01-02 15:04"), startTime.Location().String(), 
-		endTime.Format("2006-01-02 15:04"), endTime.Location().String())
-	
-	// Convert to local timezone for time comparisons
-	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
+// CreateExternalReservation books a space for an external client. External
+// clients pay outside the credit system, so nothing is charged.
+func (s *ReservationService) CreateExternalReservation(input ExternalReservationInput) (*models.Reservation, *BookingEvaluation, error) {
+	if input.Status != models.StatusConfirmed && input.Status != models.StatusPending {
+		return nil, nil, errors.New("Estado inválido. Use: confirmed, pending")
+	}
+
+	eval, err := s.bookingService.Check(BookingRequest{
+		SpaceID:   input.SpaceID,
+		StartTime: input.StartTime,
+		EndTime:   input.EndTime,
+	}, input.Override)
 	if err != nil {
-		loc = time.Local
+		return nil, eval, err
 	}
-	
-	localStartTime := startTime.In(loc)
-	localEndTime := endTime.In(loc)
-	
-	// Check if date is a closed date
-	if s.isClosedDate(startTime) {
-		fmt.Printf("DEBUG: Closed date detected\n")
-		return true // Closed date, requires approval
+
+	reservation := models.Reservation{
+		ExternalClientID: &input.ExternalClientID,
+		SpaceID:          input.SpaceID,
+		StartTime:        input.StartTime,
+		EndTime:          input.EndTime,
+		Status:           input.Status,
+		CreditsUsed:      0, // External clients don't use credits
+		RequiresApproval: eval.RequiresApproval,
+		CreatedBy:        &input.AdminID,
+		Notes:            input.Notes,
 	}
 
-	// Check business hours
-	if !s.isWithinBusinessHours(startTime, endTime) {
-		fmt.Printf("DEBUG: Outside business hours\n")
-		return true // Outside business hours, requires approval
+	if err := config.DB.Create(&reservation).Error; err != nil {
+		return nil, eval, MapReservationError(err)
+	}
+
+	reason := "Reservación de cliente externo"
+	if len(eval.Failed()) > 0 {
+		reason = "Reservación de cliente externo con reglas omitidas por el administrador"
+	}
+	if err := recordReservationCreated(config.DB, &reservation, &input.AdminID, reason, 0); err != nil {
+		return nil, eval, err
+	}
+
+	return &reservation, eval, nil
+}
+
+// RescheduleInput is an admin change of a reservation's space, times or notes.
+// Nil fields keep their current value.
+type RescheduleInput struct {
+	SpaceID   *uint
+	StartTime *time.Time
+	EndTime   *time.Time
+	Notes     *string
+	Override  bool
+	AdminID   uint
+}
+
+// UpdateReservation moves a reservation through the booking engine. The price
+// and approval requirement are recomputed; a confirmed user reservation is
+// charged or refunded the difference in credits.
+func (s *ReservationService) UpdateReservation(reservationID uint, input RescheduleInput) (*models.Reservation, *BookingEvaluation, error) {
+	var reservation models.Reservation
+	if err := config.DB.First(&reservation, reservationID).Error; err != nil {
+		return nil, nil, errors.New("Reserva no encontrada")
 	}
 
-	// Check space-specific schedules
-	var schedules []models.Schedule
-	dayOfWeek := int(localStartTime.Weekday())
+	// Only active reservations can be moved; every other status is final
+	if reservation.Status != models.StatusPending && reservation.Status != models.StatusConfirmed {
+		return nil, nil, fmt.Errorf("No se puede actualizar una reserva %s", reservationStatusNames[reservation.Status])
+	}
 
-	config.DB.Where("space_id = ? AND day_of_week = ? AND is_active = ?", spaceID, dayOfWeek, true).Find(&schedules)
-	fmt.Printf("DEBUG: Found %d schedules for space %d on day %d\n", len(schedules), spaceID, dayOfWeek)
+	spaceID, startTime, endTime := reservation.SpaceID, reservation.StartTime, reservation.EndTime
+	if input.SpaceID != nil {
+		spaceID = *input.SpaceID
+	}
+	if input.StartTime != nil {
+		startTime = *input.StartTime
+	}
+	if input.EndTime != nil {
+		endTime = *input.EndTime
+	}
 
-	if len(schedules) == 0 {
-		fmt.Printf("DEBUG: No schedule defined for space\n")
-		return true // No schedule defined, requires approval
+	eval, err := s.bookingService.Check(BookingRequest{
+		SpaceID:        spaceID,
+		StartTime:      startTime,
+		EndTime:        endTime,
+		UserID:         reservation.UserID,
+		ReservationID:  reservation.ID,
+		ChargedCredits: s.chargedCredits(&reservation),
+	}, input.Override)
+	if err != nil {
+		return nil, eval, err
 	}
 
-	// Use local times for schedule comparisons
-	startTimeStr := localStartTime.Format("15:04")
-	endTimeStr := localEndTime.Format("15:04")
-	fmt.Printf("DEBUG: Checking time range %s-%s in local timezone\n", startTimeStr, endTimeStr)
+	if err := s.reschedule(&reservation, eval, startTime, endTime, &input.AdminID); err != nil {
+		return nil, eval, err
+	}
 
-	for i, schedule := range schedules {
-		fmt.Printf("DEBUG: Schedule %d: %s-%s\n", i, schedule.StartTime, schedule.EndTime)
-		if startTimeStr >= schedule.StartTime && endTimeStr <= schedule.EndTime {
-			fmt.Printf("DEBUG: Time is within schedule - NO approval required\n")
-			return false // Within allowed schedule
+	if input.Notes != nil {
+		if err := config.DB.Model(&reservation).Update("notes", *input.Notes).Error; err != nil {
+			return nil, eval, err
 		}
 	}
 
-	fmt.Printf("DEBUG: Outside allowed schedule - approval required\n")
-	return true // Outside allowed schedule, requires approval
+	return &reservation, eval, nil
 }
 
-// isClosedDate checks if the given date is marked as closed
-func (s *ReservationService) isClosedDate(date time.Time) bool {
-	// Convert to local timezone for date comparison
-	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
-	if err != nil {
-		loc = time.Local
+// chargedCredits is what the reservation has paid or holds so far: pending
+// user reservations hold their price until approval and external clients are
+// never charged
+func (s *ReservationService) chargedCredits(reservation *models.Reservation) int {
+	if reservation.UserID == nil {
+		return 0
+	}
+	if reservation.Status != models.StatusConfirmed && reservation.Status != models.StatusPending {
+		return 0
 	}
-	
-	localDate := date.In(loc)
-	var count int64
-	config.DB.Model(&models.ClosedDate{}).
-		Where("date = ? AND is_active = ?", localDate.Format("2006-01-02"), true).
-		Count(&count)
-	return count > 0
+	return reservation.CreditsUsed
 }
 
-// isWithinBusinessHours checks if the reservation time is within business hours
-func (s *ReservationService) isWithinBusinessHours(startTime, endTime time.Time) bool {
-	// Convert to local timezone for business hours validation
-	loc, err := time.LoadLocation("America/Mexico_City") // GMT-6
-	if err != nil {
-		// Fallback to system timezone if location loading fails
-		loc = time.Local
+// reschedule applies an evaluated move to the reservation, adjusting the
+// credits of confirmed user reservations by the price difference and the hold
+// of pending ones to the new price. adminID is nil when the professional moves
+// their own reservation.
+func (s *ReservationService) reschedule(r *models.Reservation, eval *BookingEvaluation, start, end time.Time, adminID *uint) error {
+	cost := 0
+	if r.UserID != nil {
+		cost = eval.Quote.TotalCredits
 	}
-	
-	localStartTime := startTime.In(loc)
-	localEndTime := endTime.In(loc)
-	dayOfWeek := int(localStartTime.Weekday())
 
-	var businessHour models.BusinessHour
-	err = config.DB.Where("day_of_week = ?", dayOfWeek).First(&businessHour).Error
+	diff := cost - s.chargedCredits(r)
 
-	if err != nil {
-		return false
+	updates := map[string]interface{}{
+		"space_id":          eval.Space.ID,
+		"start_time":        start,
+		"end_time":          end,
+		"credits_used":      cost,
+		"requires_approval": eval.RequiresApproval,
 	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Reservation{}).Where("id = ?", r.ID).Updates(updates).Error; err != nil {
+			return MapReservationError(err)
+		}
 
-	if businessHour.IsClosed {
-		return false
+		if r.UserID == nil {
+			return nil
+		}
+		if r.Status == models.StatusPending {
+			if diff == 0 {
+				return nil
+			}
+			if err := s.creditService.ResolveHold(tx, r.ID, models.HoldReleased); err != nil {
+				return err
+			}
+			return s.creditService.PlaceHold(tx, *r.UserID, r.ID, cost)
+		}
+		if r.Status != models.StatusConfirmed {
+			return nil
+		}
+		entry := CreditEntry{
+			Reason:        "Ajuste por cambio de reservación",
+			AdminID:       adminID,
+			ReservationID: &r.ID,
+		}
+		if diff > 0 {
+			return s.creditService.DeductCredits(tx, *r.UserID, diff, entry)
+		}
+		if diff < 0 {
+			_, err := s.creditService.AddCredits(tx, *r.UserID, -diff, entry)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	startTimeStr := localStartTime.Format("15:04")
-	endTimeStr := localEndTime.Format("15:04")
-
-	// Check if reservation time is within business hours
-	return startTimeStr >= businessHour.StartTime && endTimeStr <= businessHour.EndTime
+	r.SpaceID = eval.Space.ID
+	r.Space = *eval.Space
+	r.StartTime = start
+	r.EndTime = end
+	r.CreditsUsed = cost
+	r.RequiresApproval = eval.RequiresApproval
+	return nil
 }
 
-func (s *ReservationService) CancelReservation(reservationID, userID uint, creditsToRefund *int) error {
+func (s *ReservationService) CancelReservation(reservationID, userID uint) (*models.Cancellation, error) {
 	var reservation models.Reservation
 	if err := config.DB.Where("id = ? AND user_id = ?", reservationID, userID).First(&reservation).Error; err != nil {
-		return errors.New("Reservación no encontrada")
+		return nil, errors.New("Reservación no encontrada")
 	}
 
-	if reservation.Status == models.StatusCancelled {
-		return errors.New("Reservación ya cancelada")
+	if err := checkTransition(reservation.Status, models.StatusCancelled); err != nil {
+		return nil, err
 	}
 
-	if reservation.Status == models.StatusCompleted {
-		return errors.New("No se puede cancelar una reservación completada")
+	// The refund is always computed from the cancellation policy
+	now := time.Now()
+	quote := s.policyService.QuoteRefund(&reservation, now)
+
+	// Only confirmed reservations were charged, so only they get a refund
+	refund := 0
+	if reservation.Status == models.StatusConfirmed {
+		refund = quote.RefundCredits
 	}
 
 	// Start transaction
@@ -226,60 +374,113 @@ func (s *ReservationService) CancelReservation(reservationID, userID uint, credi
 	originalStatus := reservation.Status
 
 	// Update reservation status to Cancelled
-	reservation.Status = models.StatusCancelled
-	if err := tx.Save(&reservation).Error; err != nil {
+	if err := transitionReservation(tx, &reservation, Transition{
+		To:          models.StatusCancelled,
+		ActorID:     &userID,
+		Reason:      "Cancelada por el usuario",
+		CreditDelta: -refund,
+	}); err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
-	// Handle credit refund logic based on the original status
-	if originalStatus == models.StatusConfirmed {
-		var refundAmount int
-		if creditsToRefund != nil {
-			// New logic: use the provided refund amount
-			refundAmount = *creditsToRefund
-		} else {
-			// Fallback to old logic: check for penalty
-			now := time.Now()
-			loc, _ := time.LoadLocation("America/Mexico_City")
-			hoursUntilReservation := reservation.StartTime.Sub(now.In(loc)).Hours()
-			if hoursUntilReservation >= 24 {
-				refundAmount = reservation.CreditsUsed
-			}
+	cancellation := models.Cancellation{
+		UserID:           &userID,
+		ReservationID:    reservationID,
+		CancelledAt:      now,
+		HoursBeforeStart: quote.HoursBeforeStart,
+		Status:           models.CancellationProcessed,
+		Reason:           "Cancelada por el usuario",
+		CancelledBy:      &userID,
+		PolicyID:         quote.PolicyID,
+		PolicyTierID:     quote.PolicyTierID,
+		RefundPercent:    quote.RefundPercent,
+	}
+
+	// Pending reservations only held their credits, which are given back
+	if originalStatus == models.StatusPending {
+		if err := s.creditService.ResolveHold(tx, reservationID, models.HoldReleased); err != nil {
+			tx.Rollback()
+			return nil, err
 		}
+	}
 
-		if refundAmount > 0 {
-			if _, err := s.creditService.AddCredits(userID, refundAmount, "Reembolso por cancelación de usuario", reservationID, ""); err != nil {
+	// Only confirmed reservations were charged, so only they get a refund
+	if originalStatus == models.StatusConfirmed {
+		if quote.RefundCredits > 0 {
+			if _, err := s.creditService.AddCredits(tx, userID, quote.RefundCredits, CreditEntry{
+				Reason:        "Reembolso por cancelación de usuario",
+				ReservationID: &reservationID,
+			}); err != nil {
 				tx.Rollback()
-				return err
+				return nil, err
 			}
+			cancellation.Status = models.CancellationRefunded
+			cancellation.RefundedCredits = quote.RefundCredits
+		} else if quote.WithheldCredits > 0 {
+			cancellation.Status = models.CancellationPenalized
 		}
+		cancellation.PenaltyCredits = quote.WithheldCredits
+	}
+
+	if err := tx.Create(&cancellation).Error; err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	// Commit the transaction
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	// Offer the released slot to the waitlist
+	NewWaitlistService().ProcessReleasedSlot(reservation.SpaceID, reservation.StartTime, reservation.EndTime)
+	return &cancellation, nil
 }
 
-func (s *ReservationService) ApproveReservation(reservationID, adminID uint) error {
+// QuoteCancellation shows the refund a user would get by cancelling now
+func (s *ReservationService) QuoteCancellation(reservationID, userID uint) (*RefundQuote, error) {
 	var reservation models.Reservation
-	if err := config.DB.First(&reservation, reservationID).Error; err != nil {
-		return errors.New("Reservación no encontrada")
+	if err := config.DB.Where("id = ? AND user_id = ?", reservationID, userID).First(&reservation).Error; err != nil {
+		return nil, errors.New("Reservación no encontrada")
+	}
+
+	quote := s.policyService.QuoteRefund(&reservation, time.Now())
+	if reservation.Status != models.StatusConfirmed {
+		// Nothing was charged yet
+		quote.RefundCredits = 0
+		quote.WithheldCredits = 0
 	}
+	return &quote, nil
+}
 
-	if reservation.Status != models.StatusPending {
-		return errors.New("Reservación no pendiente de aprobación")
+// ApproveReservation confirms a pending reservation. User reservations are
+// re-priced with the current pricing rules and their hold is converted into
+// the charge; the returned quote is the price that was charged.
+func (s *ReservationService) ApproveReservation(reservationID, adminID uint) (*PriceQuote, error) {
+	var reservation models.Reservation
+	if err := config.DB.Preload("Space").First(&reservation, reservationID).Error; err != nil {
+		return nil, errors.New("Reservación no encontrada")
+	}
+
+	if err := checkTransition(reservation.Status, models.StatusConfirmed); err != nil {
+		return nil, err
 	}
 
 	// Check for conflicts again
-	if err := s.checkReservationConflicts(reservation.SpaceID, reservation.StartTime, reservation.EndTime, reservationID); err != nil {
-		return err
+	if err := s.bookingService.checkConflicts(reservation.SpaceID, reservation.StartTime, reservation.EndTime, reservationID); err != nil {
+		return nil, err
 	}
 
-	// Deduct credits (only for user reservations, not external clients)
+	quote, err := s.pricingService.Quote(&reservation.Space, reservation.StartTime, reservation.EndTime, reservation.RequiresApproval)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only user reservations are charged, not external clients
+	charged := 0
 	if reservation.UserID != nil {
-		if err := s.creditService.DeductCredits(*reservation.UserID, reservation.CreditsUsed); err != nil {
-			return err
-		}
+		charged = quote.TotalCredits
 	}
 
 	// Update reservation
@@ -290,11 +491,176 @@ func (s *ReservationService) ApproveReservation(reservationID, adminID uint) err
 		loc = time.Local
 	}
 	localNow := now.In(loc)
-	reservation.Status = models.StatusConfirmed
-	reservation.ApprovedBy = &adminID
-	reservation.ApprovedAt = &localNow
 
-	return config.DB.Save(&reservation).Error
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if reservation.UserID != nil {
+			if err := s.creditService.ResolveHold(tx, reservationID, models.HoldCaptured); err != nil {
+				return err
+			}
+			if err := s.creditService.DeductCredits(tx, *reservation.UserID, charged, CreditEntry{
+				Reason:        "Cargo por reservación aprobada",
+				AdminID:       &adminID,
+				ReservationID: &reservationID,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if err := transitionReservation(tx, &reservation, Transition{
+			To:          models.StatusConfirmed,
+			ActorID:     &adminID,
+			Reason:      "Aprobada por el administrador",
+			CreditDelta: charged,
+		}); err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"approved_by": adminID,
+			"approved_at": localNow,
+		}
+		if reservation.UserID != nil {
+			updates["credits_used"] = charged
+		}
+		return tx.Model(&models.Reservation{}).Where("id = ?", reservation.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// CompletePastReservations marks confirmed reservations that already ended as
+// completed and returns how many were updated. While no-show tracking is
+// enabled only checked-in reservations are completed; the rest are left to
+// NoShowService.FlagNoShows.
+func (s *ReservationService) CompletePastReservations() (int64, error) {
+	query := config.DB.Where("status = ? AND end_time <= ?", models.StatusConfirmed, time.Now())
+	if NewNoShowService().GetPolicy().Enabled {
+		query = query.Where("checked_in_at IS NOT NULL")
+	}
+
+	var reservations []models.Reservation
+	if err := query.Find(&reservations).Error; err != nil {
+		return 0, err
+	}
+
+	var completed int64
+	for i := range reservations {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return transitionReservation(tx, &reservations[i], Transition{
+				To:     models.StatusCompleted,
+				Reason: "Reservación finalizada",
+			})
+		})
+		if err != nil {
+			return completed, err
+		}
+		completed++
+	}
+
+	return completed, nil
+}
+
+// RejectStalePending rejects special reservations that were not approved in
+// time: those pending for longer than timeout or whose start already passed.
+// Pending reservations were never charged; their hold is released.
+func (s *ReservationService) RejectStalePending(timeout time.Duration) (int64, error) {
+	now := time.Now()
+
+	var reservations []models.Reservation
+	if err := config.DB.Preload("Space").Preload("User").
+		Where("status = ? AND (created_at <= ? OR start_time <= ?)", models.StatusPending, now.Add(-timeout), now).
+		Find(&reservations).Error; err != nil {
+		return 0, err
+	}
+
+	var rejected int64
+	for i := range reservations {
+		if err := s.rejectReservation(&reservations[i], nil, "Rechazada automáticamente por falta de aprobación"); err != nil {
+			return rejected, err
+		}
+		rejected++
+	}
+
+	return rejected, nil
+}
+
+// RejectReservation declines a pending reservation. Pending reservations were
+// never charged, so only their hold is released.
+func (s *ReservationService) RejectReservation(reservationID, adminID uint, reason string) (*models.Reservation, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, errors.New("El motivo del rechazo es requerido")
+	}
+
+	var reservation models.Reservation
+	if err := config.DB.Preload("Space").Preload("User").Preload("ExternalClient").First(&reservation, reservationID).Error; err != nil {
+		return nil, errors.New("Reservación no encontrada")
+	}
+
+	if err := s.rejectReservation(&reservation, &adminID, reason); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// rejectReservation moves the reservation to rejected, notifies the
+// professional and offers the released slot to the waitlist. The reservation
+// must have Space and User loaded for the notification.
+func (s *ReservationService) rejectReservation(reservation *models.Reservation, actorID *uint, reason string) error {
+	now := time.Now()
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := transitionReservation(tx, reservation, Transition{
+			To:      models.StatusRejected,
+			ActorID: actorID,
+			Reason:  reason,
+		}); err != nil {
+			return err
+		}
+		if err := s.creditService.ResolveHold(tx, reservation.ID, models.HoldReleased); err != nil {
+			return err
+		}
+		return tx.Model(&models.Reservation{}).Where("id = ?", reservation.ID).Updates(map[string]interface{}{
+			"rejected_by":      actorID,
+			"rejected_at":      now,
+			"rejection_reason": reason,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	reservation.RejectedBy = actorID
+	reservation.RejectedAt = &now
+	reservation.RejectionReason = reason
+
+	if config.WSHub != nil {
+		// The reason is for the professional only; everyone else just
+		// reloads the calendar
+		if reservation.UserID != nil {
+			userName := "Cliente"
+			if reservation.User != nil {
+				userName = reservation.User.Name
+			}
+			config.WSHub.SendToUser(*reservation.UserID, websocket.EventReservationRejected, websocket.ReservationEvent{
+				ReservationID: reservation.ID,
+				SpaceID:       reservation.SpaceID,
+				SpaceName:     reservation.Space.Name,
+				UserName:      userName,
+				StartTime:     reservation.StartTime.Format(time.RFC3339),
+				EndTime:       reservation.EndTime.Format(time.RFC3339),
+				Status:        string(reservation.Status),
+				Action:        "rejected",
+				UserID:        reservation.UserID,
+				Reason:        reason,
+			})
+		}
+		config.WSHub.BroadcastMessage(websocket.EventCalendarRefresh, websocket.CalendarRefreshEvent{Reason: websocket.EventReservationRejected})
+	}
+
+	if reservation.StartTime.After(now) {
+		NewWaitlistService().ProcessReleasedSlot(reservation.SpaceID, reservation.StartTime, reservation.EndTime)
+	}
+	return nil
 }
 
 func (s *ReservationService) GetUserReservations(userID uint) ([]models.Reservation, error) {
@@ -307,18 +673,19 @@ func (s *ReservationService) GetUserReservations(userID uint) ([]models.Reservat
 	return reservations, err
 }
 
-func (s *ReservationService) AdminCancelReservation(reservationID, adminID uint, reason string, penalty float64, notes string) error {
+// AdminCancelReservation cancels a reservation on the admin's behalf. A
+// confirmed reservation gets all its credits back unless applyPolicy asks for
+// the cancellation policy's refund; an explicit penalty is withheld on top.
+// Reservations of external clients involve no credits, so they are cancelled
+// without refund or penalty.
+func (s *ReservationService) AdminCancelReservation(reservationID, adminID uint, reason string, penalty float64, notes string, applyPolicy bool) (*models.Cancellation, error) {
 	var reservation models.Reservation
 	if err := config.DB.First(&reservation, reservationID).Error; err != nil {
-		return errors.New("Reservación no encontrada")
-	}
-
-	if reservation.Status == models.StatusCancelled {
-		return errors.New("Reservación ya cancelada")
+		return nil, errors.New("Reservación no encontrada")
 	}
 
-	if reservation.Status == models.StatusCompleted {
-		return errors.New("No se puede cancelar una reservación completada")
+	if err := checkTransition(reservation.Status, models.StatusCancelled); err != nil {
+		return nil, err
 	}
 
 	now := time.Now()
@@ -328,83 +695,173 @@ func (s *ReservationService) AdminCancelReservation(reservationID, adminID uint,
 		loc = time.Local
 	}
 	localNow := now.In(loc)
-	hoursUntilReservation := reservation.StartTime.Sub(localNow).Hours()
+	quote := s.policyService.QuoteRefund(&reservation, localNow)
 
 	tx := config.DB.Begin()
 
 	cancellation := models.Cancellation{
-		UserID:           *reservation.UserID,
+		UserID:           reservation.UserID,
 		ReservationID:    reservationID,
 		CancelledAt:      localNow,  // Use local time
-		HoursBeforeStart: hoursUntilReservation,
+		HoursBeforeStart: quote.HoursBeforeStart,
 		Reason:           reason,
 		Notes:            notes,
 		CancelledBy:      &adminID,
+		RefundPercent:    100,
+	}
+	refundable := reservation.CreditsUsed
+	if applyPolicy {
+		cancellation.PolicyID = quote.PolicyID
+		cancellation.PolicyTierID = quote.PolicyTierID
+		cancellation.RefundPercent = quote.RefundPercent
+		refundable = quote.RefundCredits
 	}
 
 	penaltyInt := int(penalty)
-	if penalty > 0 {
-		penaltyRecord := models.Penalty{
-			UserID:        *reservation.UserID,
-			ReservationID: reservationID,
-			Amount:        penaltyInt,
-			Status:        models.PenaltyPending,
-			Reason:        reason,
-		}
+	collected := 0 // Penalty credits withheld from the refund or deducted
+	if reservation.UserID == nil {
+		penaltyInt = 0
+	}
 
-		if err := tx.Create(&penaltyRecord).Error; err != nil {
+	// A pending reservation only held its credits; give them back before any
+	// penalty is charged
+	if reservation.Status == models.StatusPending && reservation.UserID != nil {
+		if err := s.creditService.ResolveHold(tx, reservationID, models.HoldReleased); err != nil {
 			tx.Rollback()
-			return err
+			return nil, err
 		}
+	}
 
-		cancellation.PenaltyCredits = penaltyInt
-
-		if reservation.Status == models.StatusConfirmed {
-			refund := reservation.CreditsUsed - penaltyInt
-			if refund < 0 {
-				refund = 0
+	if reservation.UserID == nil {
+		cancellation.Status = models.CancellationProcessed
+		cancellation.RefundPercent = 0
+	} else if reservation.Status == models.StatusConfirmed {
+		// An explicit penalty is withheld from what is refundable
+		if penaltyInt > 0 {
+			collected = penaltyInt
+			if collected > refundable {
+				collected = refundable
 			}
-			if refund > 0 {
-				if _, err := s.creditService.AddCredits(*reservation.UserID, refund, "Reembolso por cancelación administrativa", reservationID, notes); err != nil {
-					tx.Rollback()
-					return err
-				}
+		}
+		refund := refundable - collected
+		if refund > 0 {
+			if _, err := s.creditService.AddCredits(tx, *reservation.UserID, refund, CreditEntry{
+				Reason:        "Reembolso por cancelación administrativa",
+				Notes:         notes,
+				AdminID:       &adminID,
+				ReservationID: &reservationID,
+			}); err != nil {
+				tx.Rollback()
+				return nil, err
 			}
 			cancellation.Status = models.CancellationRefunded
-			cancellation.RefundedCredits = refund
+		} else if reservation.CreditsUsed > 0 {
+			cancellation.Status = models.CancellationPenalized
 		} else {
-			if err := s.creditService.DeductCredits(*reservation.UserID, penaltyInt); err != nil {
+			cancellation.Status = models.CancellationProcessed
+		}
+		cancellation.RefundedCredits = refund
+		cancellation.PenaltyCredits = reservation.CreditsUsed - refund
+	} else if penaltyInt > 0 {
+		// Only what the user has available is deducted now
+		available, err := s.creditService.lockedAvailable(tx, *reservation.UserID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		collected = penaltyInt
+		if collected > available {
+			collected = available
+		}
+		if collected < 0 {
+			collected = 0
+		}
+		if collected > 0 {
+			if err := s.creditService.DeductCredits(tx, *reservation.UserID, collected, CreditEntry{
+				Type:          models.TransactionTypePenalty,
+				Reason:        "Penalización por cancelación administrativa",
+				Notes:         notes,
+				AdminID:       &adminID,
+				ReservationID: &reservationID,
+			}); err != nil {
 				tx.Rollback()
-				return err
+				return nil, err
 			}
-			cancellation.Status = models.CancellationPenalized
 		}
+		cancellation.Status = models.CancellationPenalized
+		cancellation.PenaltyCredits = collected
 	} else {
-		if reservation.Status == models.StatusConfirmed {
-			if _, err := s.creditService.AddCredits(*reservation.UserID, reservation.CreditsUsed, "Reembolso por cancelación administrativa", reservationID, notes); err != nil {
-				tx.Rollback()
-				return err
-			}
-			cancellation.Status = models.CancellationRefunded
-			cancellation.RefundedCredits = reservation.CreditsUsed
-		} else {
-			cancellation.Status = models.CancellationProcessed
+		cancellation.Status = models.CancellationProcessed
+	}
+
+	if penaltyInt > 0 {
+		if err := s.recordAdminPenalty(tx, &reservation, adminID, reason, penaltyInt, collected, now); err != nil {
+			tx.Rollback()
+			return nil, err
 		}
 	}
 
 	if err := tx.Create(&cancellation).Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
-	reservation.Status = models.StatusCancelled
-	if err := tx.Save(&reservation).Error; err != nil {
+	// Confirmed reservations get their refund back; a penalty on a pending
+	// reservation is charged on top of nothing
+	creditDelta := -cancellation.RefundedCredits
+	if reservation.Status != models.StatusConfirmed {
+		creditDelta = cancellation.PenaltyCredits
+	}
+	if err := transitionReservation(tx, &reservation, Transition{
+		To:          models.StatusCancelled,
+		ActorID:     &adminID,
+		Reason:      reason,
+		CreditDelta: creditDelta,
+	}); err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		return err
+		return nil, err
+	}
+
+	// Offer the released slot to the waitlist
+	NewWaitlistService().ProcessReleasedSlot(reservation.SpaceID, reservation.StartTime, reservation.EndTime)
+	return &cancellation, nil
+}
+
+// recordAdminPenalty records the penalty of an admin cancellation. The part
+// that was collected is settled with credits; the rest stays pending, to be
+// paid or appealed like any other penalty.
+func (s *ReservationService) recordAdminPenalty(tx *gorm.DB, reservation *models.Reservation, adminID uint, reason string, amount, collected int, now time.Time) error {
+	if collected > 0 {
+		paid := models.Penalty{
+			UserID:        *reservation.UserID,
+			ReservationID: reservation.ID,
+			Amount:        collected,
+			Status:        models.PenaltyPaid,
+			Reason:        reason,
+			SettledVia:    models.SettledWithCredits,
+			SettledAt:     &now,
+			SettledBy:     &adminID,
+		}
+		if err := tx.Create(&paid).Error; err != nil {
+			return err
+		}
+	}
+
+	if amount > collected {
+		pending := models.Penalty{
+			UserID:        *reservation.UserID,
+			ReservationID: reservation.ID,
+			Amount:        amount - collected,
+			Status:        models.PenaltyPending,
+			Reason:        reason,
+		}
+		if err := tx.Create(&pending).Error; err != nil {
+			return err
+		}
 	}
 	return nil
 }
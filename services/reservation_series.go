@@ -0,0 +1,440 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// Maximum number of occurrences a single series may generate
+const maxSeriesOccurrences = 52
+
+type SeriesScope string
+
+const (
+	ScopeThis      SeriesScope = "this"
+	ScopeFollowing SeriesScope = "following"
+	ScopeAll       SeriesScope = "all"
+)
+
+// OccurrenceConflict reports an occurrence of a series that could not be
+// booked, cancelled or edited, and why
+type OccurrenceConflict struct {
+	ReservationID uint      `json:"reservation_id,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Reason        string    `json:"reason"`
+}
+
+type SeriesResult struct {
+	Series       *models.ReservationSeries `json:"series"`
+	Reservations []models.Reservation      `json:"reservations"`
+	Conflicts    []OccurrenceConflict      `json:"conflicts"`
+}
+
+type occurrence struct {
+	start time.Time
+	end   time.Time
+}
+
+type ReservationSeriesService struct {
+	reservationService *ReservationService
+	creditService      *CreditService
+}
+
+func NewReservationSeriesService() *ReservationSeriesService {
+	return &ReservationSeriesService{
+		reservationService: NewReservationService(),
+		creditService:      NewCreditService(),
+	}
+}
+
+// CreateSeries stores the recurrence rule and books every occurrence through the
+// regular reservation rules. Occurrences that cannot be booked are reported as
+// conflicts instead of aborting the whole series.
+func (s *ReservationSeriesService) CreateSeries(userID, spaceID uint, startTime, endTime time.Time, frequency models.RecurrenceFrequency, untilDate *time.Time, occurrences *int) (*SeriesResult, error) {
+	if !endTime.After(startTime) {
+		return nil, errors.New("La hora de inicio debe ser anterior a la hora de fin")
+	}
+	if untilDate == nil && occurrences == nil {
+		return nil, errors.New("Debe indicar una fecha límite o un número de ocurrencias")
+	}
+	if occurrences != nil && (*occurrences <= 0 || *occurrences > maxSeriesOccurrences) {
+		return nil, errors.New("El número de ocurrencias debe estar entre 1 y 52")
+	}
+	if untilDate != nil && untilDate.Before(truncateToDay(startTime)) {
+		return nil, errors.New("La fecha límite debe ser posterior al inicio de la serie")
+	}
+
+	var space models.Space
+	if err := config.DB.First(&space, spaceID).Error; err != nil {
+		return nil, errors.New("Espacio no encontrado")
+	}
+
+	series := models.ReservationSeries{
+		UserID:      userID,
+		SpaceID:     spaceID,
+		Frequency:   frequency,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		UntilDate:   untilDate,
+		Occurrences: occurrences,
+		Status:      models.SeriesActive,
+	}
+
+	dates, err := s.occurrences(&series)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.Create(&series).Error; err != nil {
+		return nil, err
+	}
+
+	result := &SeriesResult{
+		Series:       &series,
+		Reservations: []models.Reservation{},
+		Conflicts:    []OccurrenceConflict{},
+	}
+
+	for _, o := range dates {
+		reservation, _, err := s.reservationService.createReservation(userID, spaceID, o.start, o.end, &series.ID)
+		if err != nil {
+			result.Conflicts = append(result.Conflicts, OccurrenceConflict{
+				StartTime: o.start,
+				EndTime:   o.end,
+				Reason:    err.Error(),
+			})
+			continue
+		}
+		result.Reservations = append(result.Reservations, *reservation)
+	}
+
+	if len(result.Reservations) == 0 {
+		// Without occurrences the series is left over; if it cannot be
+		// removed the caller has to know it still exists
+		if err := config.DB.Delete(&series).Error; err != nil {
+			return result, fmt.Errorf("Ninguna ocurrencia de la serie pudo reservarse y la serie %d no pudo eliminarse: %v", series.ID, err)
+		}
+		result.Series = nil
+		return result, errors.New("Ninguna ocurrencia de la serie pudo reservarse")
+	}
+
+	return result, nil
+}
+
+// occurrences expands the recurrence rule of a series into concrete time ranges
+func (s *ReservationSeriesService) occurrences(series *models.ReservationSeries) ([]occurrence, error) {
+	duration := series.EndTime.Sub(series.StartTime)
+	limit := maxSeriesOccurrences
+	if series.Occurrences != nil && *series.Occurrences < limit {
+		limit = *series.Occurrences
+	}
+
+	var result []occurrence
+	for i := 0; len(result) < limit && i < maxSeriesOccurrences*2; i++ {
+		var start time.Time
+		switch series.Frequency {
+		case models.FrequencyWeekly:
+			start = series.StartTime.AddDate(0, 0, 7*i)
+		case models.FrequencyBiweekly:
+			start = series.StartTime.AddDate(0, 0, 14*i)
+		case models.FrequencyMonthly:
+			start = series.StartTime.AddDate(0, i, 0)
+			// Skip months that don't have the day (e.g. the 31st)
+			if start.Day() != series.StartTime.Day() {
+				continue
+			}
+		default:
+			return nil, errors.New("Frecuencia inválida. Use: weekly, biweekly, monthly")
+		}
+
+		if series.UntilDate != nil && !start.Before(truncateToDay(*series.UntilDate).AddDate(0, 0, 1)) {
+			break
+		}
+
+		result = append(result, occurrence{start: start, end: start.Add(duration)})
+	}
+
+	return result, nil
+}
+
+func (s *ReservationSeriesService) GetUserSeries(userID uint) ([]models.ReservationSeries, error) {
+	var series []models.ReservationSeries
+	err := config.DB.Preload("Space").
+		Where("user_id = ?", userID).
+		Order("start_time ASC").
+		Find(&series).Error
+
+	return series, err
+}
+
+func (s *ReservationSeriesService) GetSeries(seriesID, userID uint) (*models.ReservationSeries, error) {
+	var series models.ReservationSeries
+	err := config.DB.Preload("Space").
+		Preload("Reservations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("start_time ASC")
+		}).
+		Where("id = ? AND user_id = ?", seriesID, userID).
+		First(&series).Error
+	if err != nil {
+		return nil, errors.New("Serie no encontrada")
+	}
+
+	return &series, nil
+}
+
+// affectedReservations resolves which active, upcoming occurrences a scoped
+// operation applies to. reservationID is the occurrence the user acted on and is
+// ignored for ScopeAll.
+func (s *ReservationSeriesService) affectedReservations(series *models.ReservationSeries, reservationID uint, scope SeriesScope) ([]models.Reservation, *models.Reservation, error) {
+	query := config.DB.Where("series_id = ? AND status IN (?, ?)", series.ID, models.StatusPending, models.StatusConfirmed)
+
+	var pivot *models.Reservation
+	if scope != ScopeAll {
+		var reservation models.Reservation
+		if err := config.DB.Where("id = ? AND series_id = ?", reservationID, series.ID).First(&reservation).Error; err != nil {
+			return nil, nil, errors.New("La reservación no pertenece a la serie")
+		}
+		pivot = &reservation
+	}
+
+	switch scope {
+	case ScopeThis:
+		query = query.Where("id = ?", pivot.ID)
+	case ScopeFollowing:
+		query = query.Where("start_time >= ?", pivot.StartTime)
+	case ScopeAll:
+		query = query.Where("start_time > ?", time.Now())
+	default:
+		return nil, nil, errors.New("Alcance inválido. Use: this, following, all")
+	}
+
+	var reservations []models.Reservation
+	if err := query.Order("start_time ASC").Find(&reservations).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return reservations, pivot, nil
+}
+
+// CancelSeries cancels one occurrence, an occurrence and the ones after it, or
+// every upcoming occurrence. Refunds follow the regular cancellation rules.
+func (s *ReservationSeriesService) CancelSeries(seriesID, userID, reservationID uint, scope SeriesScope) ([]uint, []OccurrenceConflict, error) {
+	series, err := s.GetSeries(seriesID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if series.Status == models.SeriesCancelled {
+		return nil, nil, errors.New("Serie ya cancelada")
+	}
+
+	reservations, pivot, err := s.affectedReservations(series, reservationID, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cancelled := []uint{}
+	conflicts := []OccurrenceConflict{}
+	for _, r := range reservations {
+		if _, err := s.reservationService.CancelReservation(r.ID, userID); err != nil {
+			conflicts = append(conflicts, OccurrenceConflict{
+				ReservationID: r.ID,
+				StartTime:     r.StartTime,
+				EndTime:       r.EndTime,
+				Reason:        err.Error(),
+			})
+			continue
+		}
+		cancelled = append(cancelled, r.ID)
+	}
+
+	switch scope {
+	case ScopeAll:
+		series.Status = models.SeriesCancelled
+		if err := config.DB.Model(series).Update("status", series.Status).Error; err != nil {
+			return cancelled, conflicts, err
+		}
+	case ScopeFollowing:
+		// Truncate the rule so it ends the day before the cancelled occurrence
+		until := truncateToDay(pivot.StartTime).AddDate(0, 0, -1)
+		if err := config.DB.Model(series).Update("until_date", until).Error; err != nil {
+			return cancelled, conflicts, err
+		}
+	}
+
+	return cancelled, conflicts, nil
+}
+
+// EditSeries moves one occurrence, an occurrence and the ones after it, or every
+// upcoming occurrence to a new time of day and/or space. newStart and newEnd are
+// the new times for the occurrence the user acted on (the first upcoming one for
+// ScopeAll); the same shift is applied to the rest. Editing "following" splits the
+// series in two so the original rule keeps describing the earlier occurrences.
+func (s *ReservationSeriesService) EditSeries(seriesID, userID, reservationID uint, scope SeriesScope, newStart, newEnd time.Time, newSpaceID *uint) (*SeriesResult, error) {
+	if !newEnd.After(newStart) {
+		return nil, errors.New("La hora de inicio debe ser anterior a la hora de fin")
+	}
+
+	series, err := s.GetSeries(seriesID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if series.Status == models.SeriesCancelled {
+		return nil, errors.New("No se puede modificar una serie cancelada")
+	}
+
+	reservations, pivot, err := s.affectedReservations(series, reservationID, scope)
+	if err != nil {
+		return nil, err
+	}
+	if len(reservations) == 0 {
+		return nil, errors.New("No hay ocurrencias pendientes que modificar")
+	}
+	if pivot == nil {
+		pivot = &reservations[0]
+	}
+
+	spaceID := series.SpaceID
+	if newSpaceID != nil {
+		spaceID = *newSpaceID
+	}
+	var space models.Space
+	if err := config.DB.First(&space, spaceID).Error; err != nil {
+		return nil, errors.New("Espacio no encontrado")
+	}
+
+	dayOffset := int(truncateToDay(newStart).Sub(truncateToDay(pivot.StartTime)).Hours() / 24)
+	duration := newEnd.Sub(newStart)
+	shift := func(t time.Time) time.Time {
+		local := t.In(newStart.Location())
+		return time.Date(local.Year(), local.Month(), local.Day()+dayOffset,
+			newStart.Hour(), newStart.Minute(), 0, 0, newStart.Location())
+	}
+
+	target := series
+	if scope == ScopeFollowing && pivot.StartTime.After(series.StartTime) {
+		target, err = s.splitSeries(series, pivot)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &SeriesResult{
+		Series:       target,
+		Reservations: []models.Reservation{},
+		Conflicts:    []OccurrenceConflict{},
+	}
+
+	for _, r := range reservations {
+		start := shift(r.StartTime)
+		end := start.Add(duration)
+		if err := s.moveOccurrence(&r, space, start, end); err != nil {
+			result.Conflicts = append(result.Conflicts, OccurrenceConflict{
+				ReservationID: r.ID,
+				StartTime:     start,
+				EndTime:       end,
+				Reason:        err.Error(),
+			})
+			continue
+		}
+		if target.ID != series.ID {
+			config.DB.Model(&r).Update("series_id", target.ID)
+			r.SeriesID = &target.ID
+		}
+		result.Reservations = append(result.Reservations, r)
+	}
+
+	// Keep the rule in sync with its occurrences
+	if scope != ScopeThis {
+		start := shift(target.StartTime)
+		updates := map[string]interface{}{
+			"start_time": start,
+			"end_time":   start.Add(duration),
+			"space_id":   spaceID,
+		}
+		if err := config.DB.Model(target).Updates(updates).Error; err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// splitSeries ends the original rule before pivot and creates a new series that
+// starts at pivot with the remaining occurrences
+func (s *ReservationSeriesService) splitSeries(series *models.ReservationSeries, pivot *models.Reservation) (*models.ReservationSeries, error) {
+	dates, err := s.occurrences(series)
+	if err != nil {
+		return nil, err
+	}
+	before := 0
+	for _, o := range dates {
+		if o.start.Before(pivot.StartTime) {
+			before++
+		}
+	}
+
+	following := models.ReservationSeries{
+		UserID:    series.UserID,
+		SpaceID:   series.SpaceID,
+		Frequency: series.Frequency,
+		StartTime: pivot.StartTime,
+		EndTime:   pivot.EndTime,
+		UntilDate: series.UntilDate,
+		Status:    models.SeriesActive,
+	}
+	if series.Occurrences != nil {
+		remaining := *series.Occurrences - before
+		following.Occurrences = &remaining
+	}
+
+	if err := config.DB.Create(&following).Error; err != nil {
+		return nil, err
+	}
+
+	until := truncateToDay(pivot.StartTime).AddDate(0, 0, -1)
+	updates := map[string]interface{}{"until_date": until}
+	if series.Occurrences != nil {
+		updates["occurrences"] = before
+	}
+	if err := config.DB.Model(series).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	return &following, nil
+}
+
+// moveOccurrence reschedules a single occurrence through the booking engine,
+// adjusting the charged credits when the new time or space has a different cost
+func (s *ReservationSeriesService) moveOccurrence(r *models.Reservation, space models.Space, start, end time.Time) error {
+	eval, err := s.reservationService.bookingService.Evaluate(BookingRequest{
+		SpaceID:        space.ID,
+		StartTime:      start,
+		EndTime:        end,
+		UserID:         r.UserID,
+		ReservationID:  r.ID,
+		ChargedCredits: s.reservationService.chargedCredits(r),
+	})
+	if err != nil {
+		return err
+	}
+	if err := eval.Err(); err != nil {
+		return err
+	}
+
+	if r.Status == models.StatusConfirmed && eval.RequiresApproval {
+		return errors.New("El nuevo horario requiere aprobación del administrador")
+	}
+
+	return s.reservationService.reschedule(r, eval, start, end, nil)
+}
+
+// truncateToDay returns midnight of t's date in t's location
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
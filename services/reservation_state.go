@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition is returned when a reservation cannot move from its
+// current status to the requested one
+var ErrInvalidTransition = errors.New("Cambio de estado de la reservación no permitido")
+
+// reservationTransitions is the reservation state machine: the statuses each
+// status may move to. Rejected, cancelled, completed and no-show are final.
+var reservationTransitions = map[models.ReservationStatus][]models.ReservationStatus{
+	models.StatusPending:   {models.StatusConfirmed, models.StatusRejected, models.StatusCancelled},
+	models.StatusConfirmed: {models.StatusCompleted, models.StatusCancelled, models.StatusNoShow},
+}
+
+var reservationStatusNames = map[models.ReservationStatus]string{
+	models.StatusPending:   "pendiente",
+	models.StatusConfirmed: "confirmada",
+	models.StatusCancelled: "cancelada",
+	models.StatusCompleted: "completada",
+	models.StatusRejected:  "rechazada",
+	models.StatusNoShow:    "no asistida",
+}
+
+// CanTransition reports whether a reservation may move from one status to another
+func CanTransition(from, to models.ReservationStatus) bool {
+	for _, allowed := range reservationTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition explains why a transition is not allowed, if it is not. Use
+// it before side effects such as refunds that must not happen for an illegal
+// transition.
+func checkTransition(from, to models.ReservationStatus) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: la reservación está %s y no puede quedar %s",
+			ErrInvalidTransition, reservationStatusNames[from], reservationStatusNames[to])
+	}
+	return nil
+}
+
+// Transition describes a status change and who made it
+type Transition struct {
+	To          models.ReservationStatus
+	ActorID     *uint // nil for the system
+	Reason      string
+	CreditDelta int // Credits charged (+) or returned (-) to the user
+}
+
+// transitionReservation moves the reservation to a new status inside tx and
+// records it in the reservation history. The update only applies if the
+// status did not change since the reservation was read.
+func transitionReservation(tx *gorm.DB, reservation *models.Reservation, t Transition) error {
+	from := reservation.Status
+	if err := checkTransition(from, t.To); err != nil {
+		return err
+	}
+
+	result := tx.Model(&models.Reservation{}).
+		Where("id = ? AND status = ?", reservation.ID, from).
+		Update("status", t.To)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("La reservación cambió de estado, intente de nuevo")
+	}
+	reservation.Status = t.To
+
+	return tx.Create(&models.ReservationHistory{
+		ReservationID: reservation.ID,
+		FromStatus:    from,
+		ToStatus:      t.To,
+		ActorID:       t.ActorID,
+		Reason:        t.Reason,
+		CreditDelta:   t.CreditDelta,
+	}).Error
+}
+
+// recordReservationCreated stores the first history entry of a new reservation
+func recordReservationCreated(tx *gorm.DB, reservation *models.Reservation, actorID *uint, reason string, creditDelta int) error {
+	return tx.Create(&models.ReservationHistory{
+		ReservationID: reservation.ID,
+		ToStatus:      reservation.Status,
+		ActorID:       actorID,
+		Reason:        reason,
+		CreditDelta:   creditDelta,
+	}).Error
+}
+
+// GetHistory returns the status transitions of a reservation, oldest first
+func (s *ReservationService) GetHistory(reservationID uint) ([]models.ReservationHistory, error) {
+	var reservation models.Reservation
+	if err := config.DB.Select("id").First(&reservation, reservationID).Error; err != nil {
+		return nil, errors.New("Reservación no encontrada")
+	}
+
+	var history []models.ReservationHistory
+	err := config.DB.Preload("Actor").
+		Where("reservation_id = ?", reservationID).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error
+
+	return history, err
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"gorm.io/gorm/clause"
+)
+
+// Job is a recurring background task. Run returns the number of rows it
+// changed, which is stored in the job's run history.
+type Job struct {
+	Name     string                `json:"name"`
+	Interval time.Duration         `json:"-"`
+	Run      func() (int64, error) `json:"-"`
+}
+
+// JobStatus is a registered job together with its most recent run
+type JobStatus struct {
+	Job
+	IntervalSeconds int64          `json:"interval_seconds"`
+	LastRun         *models.JobRun `json:"last_run"`
+}
+
+// DefaultJobs returns the maintenance jobs run by every instance of the API
+func DefaultJobs() []Job {
+	creditService := NewCreditService()
+	reservationService := NewReservationService()
+	waitlistService := NewWaitlistService()
+	noShowService := NewNoShowService()
+	reconciliationService := NewReconciliationService()
+	membershipService := NewMembershipService()
+	idempotencyService := NewIdempotencyService()
+	checkoutService := NewCheckoutService()
+
+	return []Job{
+		{
+			Name:     "expire_credits",
+			Interval: 15 * time.Minute,
+			Run:      creditService.ExpireCredits,
+		},
+		{
+			Name:     "complete_reservations",
+			Interval: 5 * time.Minute,
+			Run:      reservationService.CompletePastReservations,
+		},
+		{
+			Name:     "flag_no_shows",
+			Interval: 5 * time.Minute,
+			Run:      noShowService.FlagNoShows,
+		},
+		{
+			Name:     "reject_stale_pending",
+			Interval: 15 * time.Minute,
+			Run: func() (int64, error) {
+				return reservationService.RejectStalePending(pendingApprovalTimeout())
+			},
+		},
+		{
+			Name:     "expire_waitlist_offers",
+			Interval: time.Minute,
+			Run:      waitlistService.ExpireOffers,
+		},
+		{
+			Name:     "reconcile_credits",
+			Interval: 6 * time.Hour,
+			Run:      reconciliationService.RunReconciliation,
+		},
+		{
+			Name:     "renew_memberships",
+			Interval: 15 * time.Minute,
+			Run:      membershipService.RenewMemberships,
+		},
+		{
+			Name:     "flag_unpaid_memberships",
+			Interval: time.Hour,
+			Run:      membershipService.FlagOverduePayments,
+		},
+		{
+			Name:     "cleanup_idempotency_keys",
+			Interval: time.Hour,
+			Run:      idempotencyService.CleanupExpiredKeys,
+		},
+		{
+			Name:     "reconcile_checkouts",
+			Interval: 15 * time.Minute,
+			Run:      checkoutService.ReconcileCheckouts,
+		},
+	}
+}
+
+// pendingApprovalTimeout is how long a special reservation may wait for admin
+// approval, configurable through PENDING_APPROVAL_TIMEOUT_HOURS
+func pendingApprovalTimeout() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("PENDING_APPROVAL_TIMEOUT_HOURS")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 48 * time.Hour
+}
+
+var (
+	ErrJobNotFound = errors.New("Trabajo no encontrado")
+	ErrJobRunning  = errors.New("El trabajo ya se está ejecutando")
+)
+
+// SchedulerService runs jobs on their intervals. A lease in job_locks makes
+// sure only one instance runs a given job when the API is scaled out: the
+// instance holding the lease keeps renewing it, and another instance takes
+// over once a lease expires without being renewed. Within the instance a job
+// never runs twice at the same time.
+type SchedulerService struct {
+	jobs     []Job
+	instance string
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewSchedulerService(jobs ...Job) *SchedulerService {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &SchedulerService{
+		jobs:     jobs,
+		instance: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		running:  make(map[string]bool),
+	}
+}
+
+// Start launches one goroutine per job. Each job runs once right away and then
+// on every tick of its interval.
+func (s *SchedulerService) Start() {
+	for _, job := range s.jobs {
+		go func(job Job) {
+			s.tick(job)
+			for range time.Tick(job.Interval) {
+				s.tick(job)
+			}
+		}(job)
+	}
+}
+
+func (s *SchedulerService) tick(job Job) {
+	if !s.start(job) {
+		return
+	}
+	defer s.finish(job)
+
+	if _, err := s.execute(job); err != nil {
+		log.Printf("Error en el trabajo %s: %v", job.Name, err)
+	}
+}
+
+// RunNow executes a registered job immediately, outside its schedule. It
+// takes the same lease as a scheduled run and returns ErrJobRunning while
+// the job runs here or another instance holds it.
+func (s *SchedulerService) RunNow(name string) (*models.JobRun, error) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			if !s.start(job) {
+				return nil, ErrJobRunning
+			}
+			defer s.finish(job)
+			return s.execute(job)
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// start marks the job as running in this instance and takes its lease. It
+// returns false when the job is already running or the lease is held
+// elsewhere.
+func (s *SchedulerService) start(job Job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[job.Name] || !s.acquireLock(job) {
+		return false
+	}
+	s.running[job.Name] = true
+	return true
+}
+
+func (s *SchedulerService) finish(job Job) {
+	s.mu.Lock()
+	delete(s.running, job.Name)
+	s.mu.Unlock()
+}
+
+// execute runs the job and records the outcome in its run history
+func (s *SchedulerService) execute(job Job) (run *models.JobRun, err error) {
+	run = &models.JobRun{
+		JobName:   job.Name,
+		Instance:  s.instance,
+		Status:    models.JobRunning,
+		StartedAt: time.Now(),
+	}
+	config.DB.Create(run)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		run.Status = models.JobSucceeded
+		if err != nil {
+			run.Status = models.JobFailed
+			run.Error = err.Error()
+		}
+		config.DB.Save(run)
+	}()
+
+	run.Affected, err = job.Run()
+	return run, err
+}
+
+// acquireLock takes or renews the job's lease. The lease lasts two intervals so
+// the holder renews it on its next tick before anyone else can take it.
+// Returns false when another instance holds an unexpired lease.
+func (s *SchedulerService) acquireLock(job Job) bool {
+	now := time.Now()
+
+	// Make sure the lock row exists; the lease itself is taken by the update
+	config.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.JobLock{JobName: job.Name, LockedUntil: now})
+
+	result := config.DB.Model(&models.JobLock{}).
+		Where("job_name = ? AND (locked_until <= ? OR locked_by = ?)", job.Name, now, s.instance).
+		Updates(map[string]interface{}{
+			"locked_by":    s.instance,
+			"locked_until": now.Add(2 * job.Interval),
+		})
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// GetJobs lists the registered jobs with their latest run
+func (s *SchedulerService) GetJobs() []JobStatus {
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, job := range s.jobs {
+		statuses[i] = JobStatus{
+			Job:             job,
+			IntervalSeconds: int64(job.Interval.Seconds()),
+		}
+
+		var run models.JobRun
+		if err := config.DB.Where("job_name = ?", job.Name).Order("started_at DESC").First(&run).Error; err == nil {
+			statuses[i].LastRun = &run
+		}
+	}
+	return statuses
+}
+
+// GetRuns returns the most recent runs of a job, newest first
+func (s *SchedulerService) GetRuns(jobName string, limit int) ([]models.JobRun, error) {
+	var runs []models.JobRun
+	err := config.DB.Where("job_name = ?", jobName).
+		Order("started_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+
+	return runs, err
+}
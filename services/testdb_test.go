@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/pressly/goose/v3"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDatabaseEnv names the Postgres database the tests that need one run
+// against. It is migrated like the server does and emptied before every test,
+// so point it at a throwaway database. Without it those tests are skipped.
+const testDatabaseEnv = "TEST_DATABASE_URL"
+
+var (
+	openTestDBOnce sync.Once
+	testDB         *gorm.DB
+	testDBErr      error
+)
+
+// useTestDB points config.DB at an empty, migrated test database for the rest
+// of the test
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	openTestDBOnce.Do(func() {
+		testDB, testDBErr = openTestDB(dsn)
+	})
+	if testDBErr != nil {
+		t.Fatalf("opening the test database: %v", testDBErr)
+	}
+	if err := emptyTables(testDB); err != nil {
+		t.Fatalf("emptying the test database: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = testDB
+	t.Cleanup(func() { config.DB = previous })
+	return testDB
+}
+
+func openTestDB(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := config.MigrateSchema(db); err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("postgres"); err != nil {
+		return nil, err
+	}
+	if err := goose.Up(sqlDB, "../migrations"); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// emptyTables removes every row left by the previous test, including the
+// ones the migrations seed
+func emptyTables(db *gorm.DB) error {
+	var tables []string
+	if err := db.Raw(`SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> 'goose_db_version'`).
+		Scan(&tables).Error; err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	for i, table := range tables {
+		tables[i] = fmt.Sprintf("%q", table)
+	}
+	return db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error
+}
+
+// createTestUser stores a professional
+func createTestUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+	user := &models.User{
+		Email:    strings.ToLower(name) + "@example.com",
+		Password: "x",
+		Name:     name,
+		Role:     models.RoleProfessional,
+		IsActive: true,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return user
+}
+
+// grantTestCredits gives the user a lot of amount credits expiring in the
+// given number of days
+func grantTestCredits(t *testing.T, db *gorm.DB, userID uint, amount, days int) *models.Credit {
+	t.Helper()
+	credit, err := NewCreditService().grantLot(db, userID, amount, time.Now().AddDate(0, 0, days), CreditEntry{
+		Type:   models.TransactionTypeGrant,
+		Reason: "Créditos de prueba",
+	})
+	if err != nil {
+		t.Fatalf("granting %d credits: %v", amount, err)
+	}
+	return credit
+}
+
+// ledgerEntries lists the user's ledger entries, oldest first
+func ledgerEntries(t *testing.T, db *gorm.DB, userID uint) []models.CreditTransaction {
+	t.Helper()
+	var entries []models.CreditTransaction
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&entries).Error; err != nil {
+		t.Fatalf("loading the ledger: %v", err)
+	}
+	return entries
+}
+
+// requireBalance fails unless the user's active credits and the ledger both
+// add up to want
+func requireBalance(t *testing.T, userID uint, want int) {
+	t.Helper()
+	service := NewCreditService()
+	active, err := service.GetActiveCredits(userID)
+	if err != nil {
+		t.Fatalf("GetActiveCredits: %v", err)
+	}
+	ledger, err := service.BalanceAt(userID, time.Now())
+	if err != nil {
+		t.Fatalf("BalanceAt: %v", err)
+	}
+	if active != want || ledger != want {
+		t.Fatalf("active credits = %d, ledger balance = %d, want %d", active, ledger, want)
+	}
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IkingariSolorzano/omma-be/config"
+	"github.com/IkingariSolorzano/omma-be/models"
+	"github.com/IkingariSolorzano/omma-be/websocket"
+)
+
+// Default time a waitlisted user has to accept an offered slot
+const defaultWaitlistOfferMinutes = 30
+
+type WaitlistService struct {
+	reservationService *ReservationService
+}
+
+func NewWaitlistService() *WaitlistService {
+	return &WaitlistService{
+		reservationService: NewReservationService(),
+	}
+}
+
+// offerWindow reads WAITLIST_OFFER_MINUTES, falling back to the default
+func (s *WaitlistService) offerWindow() time.Duration {
+	minutes := defaultWaitlistOfferMinutes
+	if value := os.Getenv("WAITLIST_OFFER_MINUTES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			minutes = parsed
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func (s *WaitlistService) JoinWaitlist(userID, spaceID uint, startTime, endTime time.Time, autoBook bool) (*models.WaitlistEntry, error) {
+	if !endTime.After(startTime) {
+		return nil, errors.New("La hora de inicio debe ser anterior a la hora de fin")
+	}
+	if startTime.Before(time.Now()) {
+		return nil, errors.New("No se puede unir a la lista de espera de un horario pasado")
+	}
+
+	var space models.Space
+	if err := config.DB.First(&space, spaceID).Error; err != nil {
+		return nil, errors.New("Espacio no encontrado")
+	}
+
+	bookingService := s.reservationService.bookingService
+	if bookingService.checkConflicts(spaceID, startTime, endTime, 0) == nil &&
+		bookingService.checkOffers(spaceID, startTime, endTime, &userID) == nil {
+		return nil, errors.New("El periodo está disponible, puede reservarlo directamente")
+	}
+
+	var count int64
+	config.DB.Model(&models.WaitlistEntry{}).
+		Where("user_id = ? AND space_id = ? AND start_time = ? AND end_time = ? AND status IN (?, ?)",
+			userID, spaceID, startTime, endTime, models.WaitlistWaiting, models.WaitlistOffered).
+		Count(&count)
+	if count > 0 {
+		return nil, errors.New("Ya se encuentra en la lista de espera para este horario")
+	}
+
+	entry := models.WaitlistEntry{
+		UserID:    userID,
+		SpaceID:   spaceID,
+		StartTime: startTime,
+		EndTime:   endTime,
+		AutoBook:  autoBook,
+		Status:    models.WaitlistWaiting,
+	}
+
+	if err := config.DB.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (s *WaitlistService) LeaveWaitlist(entryID, userID uint) error {
+	entry, err := s.getUserEntry(entryID, userID)
+	if err != nil {
+		return err
+	}
+
+	if entry.Status != models.WaitlistWaiting && entry.Status != models.WaitlistOffered {
+		return errors.New("La solicitud ya no está activa en la lista de espera")
+	}
+
+	wasOffered := entry.Status == models.WaitlistOffered
+	if err := config.DB.Model(entry).Update("status", models.WaitlistCancelled).Error; err != nil {
+		return err
+	}
+
+	// A declined offer passes the slot to the next in line
+	if wasOffered {
+		s.ProcessReleasedSlot(entry.SpaceID, entry.StartTime, entry.EndTime)
+	}
+
+	return nil
+}
+
+func (s *WaitlistService) GetUserEntries(userID uint) ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	err := config.DB.Preload("Space").
+		Where("user_id = ?", userID).
+		Order("start_time ASC").
+		Find(&entries).Error
+
+	return entries, err
+}
+
+func (s *WaitlistService) GetActiveEntries() ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	err := config.DB.Preload("User").Preload("Space").
+		Where("status IN (?, ?)", models.WaitlistWaiting, models.WaitlistOffered).
+		Order("start_time ASC, created_at ASC").
+		Find(&entries).Error
+
+	return entries, err
+}
+
+// AcceptOffer books the offered slot for the user through the regular reservation rules
+func (s *WaitlistService) AcceptOffer(entryID, userID uint) (*models.Reservation, error) {
+	entry, err := s.getUserEntry(entryID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Status != models.WaitlistOffered {
+		return nil, errors.New("No hay una oferta activa para esta solicitud")
+	}
+	if entry.OfferExpiresAt != nil && entry.OfferExpiresAt.Before(time.Now()) {
+		s.expireEntry(entry)
+		s.ProcessReleasedSlot(entry.SpaceID, entry.StartTime, entry.EndTime)
+		return nil, errors.New("La oferta ha expirado")
+	}
+
+	reservation, _, err := s.reservationService.CreateReservation(userID, entry.SpaceID, entry.StartTime, entry.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	config.DB.Model(entry).Updates(map[string]interface{}{
+		"status":         models.WaitlistBooked,
+		"reservation_id": reservation.ID,
+	})
+
+	return reservation, nil
+}
+
+// ProcessReleasedSlot promotes the oldest waitlisted entry whose window became
+// free after a reservation for the space was cancelled. Entries that opted in to
+// auto-booking are booked directly; the rest receive a time-limited offer.
+func (s *WaitlistService) ProcessReleasedSlot(spaceID uint, startTime, endTime time.Time) {
+	var entries []models.WaitlistEntry
+	err := config.DB.Preload("Space").
+		Where("space_id = ? AND status = ? AND start_time < ? AND end_time > ?",
+			spaceID, models.WaitlistWaiting, endTime, startTime).
+		Order("created_at ASC").
+		Find(&entries).Error
+	if err != nil {
+		log.Printf("[WAITLIST] Error loading entries for space %d: %v", spaceID, err)
+		return
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+
+		if entry.StartTime.Before(time.Now()) {
+			s.expireEntry(entry)
+			continue
+		}
+
+		// The window must be completely free and not already offered to someone else
+		if err := s.reservationService.bookingService.checkConflicts(spaceID, entry.StartTime, entry.EndTime, 0); err != nil {
+			continue
+		}
+		if s.hasOutstandingOffer(entry) {
+			continue
+		}
+
+		if entry.AutoBook {
+			reservation, _, err := s.reservationService.CreateReservation(entry.UserID, spaceID, entry.StartTime, entry.EndTime)
+			if err == nil {
+				entry.Status = models.WaitlistBooked
+				entry.ReservationID = &reservation.ID
+				config.DB.Model(entry).Updates(map[string]interface{}{
+					"status":         entry.Status,
+					"reservation_id": reservation.ID,
+				})
+				s.notify(websocket.EventWaitlistBooked, entry)
+				return
+			}
+			log.Printf("[WAITLIST] Auto-booking failed for entry %d, sending offer instead: %v", entry.ID, err)
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(s.offerWindow())
+		entry.Status = models.WaitlistOffered
+		entry.OfferedAt = &now
+		entry.OfferExpiresAt = &expiresAt
+		config.DB.Model(entry).Updates(map[string]interface{}{
+			"status":           entry.Status,
+			"offered_at":       now,
+			"offer_expires_at": expiresAt,
+		})
+		s.notify(websocket.EventWaitlistOffered, entry)
+		return
+	}
+}
+
+// ExpireOffers expires offers that were not accepted in time and passes each
+// slot on to the next entry in line
+func (s *WaitlistService) ExpireOffers() (int64, error) {
+	var entries []models.WaitlistEntry
+	if err := config.DB.Preload("Space").
+		Where("status = ? AND offer_expires_at <= ?", models.WaitlistOffered, time.Now()).
+		Find(&entries).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range entries {
+		s.expireEntry(&entries[i])
+		s.ProcessReleasedSlot(entries[i].SpaceID, entries[i].StartTime, entries[i].EndTime)
+	}
+
+	return int64(len(entries)), nil
+}
+
+func (s *WaitlistService) expireEntry(entry *models.WaitlistEntry) {
+	entry.Status = models.WaitlistExpired
+	config.DB.Model(entry).Update("status", entry.Status)
+	s.notify(websocket.EventWaitlistExpired, entry)
+}
+
+// hasOutstandingOffer checks whether an overlapping window of the same space is
+// currently offered to another user
+func (s *WaitlistService) hasOutstandingOffer(entry *models.WaitlistEntry) bool {
+	var count int64
+	config.DB.Model(&models.WaitlistEntry{}).
+		Where("space_id = ? AND status = ? AND offer_expires_at > ? AND start_time < ? AND end_time > ? AND id != ?",
+			entry.SpaceID, models.WaitlistOffered, time.Now(), entry.EndTime, entry.StartTime, entry.ID).
+		Count(&count)
+	return count > 0
+}
+
+func (s *WaitlistService) getUserEntry(entryID, userID uint) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	if err := config.DB.Preload("Space").Where("id = ? AND user_id = ?", entryID, userID).First(&entry).Error; err != nil {
+		return nil, errors.New("Solicitud de lista de espera no encontrada")
+	}
+	return &entry, nil
+}
+
+// notify sends the entry's update only to its own user
+func (s *WaitlistService) notify(eventType string, entry *models.WaitlistEntry) {
+	if config.WSHub == nil {
+		return
+	}
+
+	event := websocket.WaitlistEvent{
+		EntryID:       entry.ID,
+		UserID:        entry.UserID,
+		SpaceID:       entry.SpaceID,
+		SpaceName:     entry.Space.Name,
+		StartTime:     entry.StartTime.Format(time.RFC3339),
+		EndTime:       entry.EndTime.Format(time.RFC3339),
+		Status:        string(entry.Status),
+		ReservationID: entry.ReservationID,
+	}
+	if entry.OfferExpiresAt != nil {
+		event.ExpiresAt = entry.OfferExpiresAt.Format(time.RFC3339)
+	}
+	config.WSHub.SendToUser(entry.UserID, eventType, event)
+}
@@ -7,6 +7,12 @@ const (
 	EventReservationUpdated   = "reservation:updated"
 	EventReservationCancelled = "reservation:cancelled"
 	EventReservationApproved  = "reservation:approved"
+	EventReservationRejected  = "reservation:rejected"
+
+	// Waitlist events
+	EventWaitlistOffered = "waitlist:offered"
+	EventWaitlistBooked  = "waitlist:booked"
+	EventWaitlistExpired = "waitlist:expired"
 
 	// User events
 	EventUserStatusChanged = "user:status_changed"
@@ -27,7 +33,22 @@ type ReservationEvent struct {
 	StartTime     string `json:"start_time"`
 	EndTime       string `json:"end_time"`
 	Status        string `json:"status"`
-	Action        string `json:"action"` // created, updated, cancelled, approved
+	Action        string `json:"action"`            // created, updated, cancelled, approved, rejected
+	UserID        *uint  `json:"user_id,omitempty"` // Professional the reservation belongs to
+	Reason        string `json:"reason,omitempty"`  // Why the reservation was rejected
+}
+
+// WaitlistEvent represents a waitlist offer, automatic booking or expiration
+type WaitlistEvent struct {
+	EntryID       uint   `json:"entry_id"`
+	UserID        uint   `json:"user_id"`
+	SpaceID       uint   `json:"space_id"`
+	SpaceName     string `json:"space_name"`
+	StartTime     string `json:"start_time"`
+	EndTime       string `json:"end_time"`
+	Status        string `json:"status"`
+	ReservationID *uint  `json:"reservation_id,omitempty"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
 }
 
 // UserStatusEvent represents a user status change event
@@ -14,6 +14,9 @@ type Hub struct {
 	// Inbound messages from the clients
 	broadcast chan []byte
 
+	// Messages for the clients of a single user
+	direct chan userMessage
+
 	// Register requests from the clients
 	register chan *Client
 
@@ -28,6 +31,7 @@ type Hub struct {
 func NewHub() *Hub {
 	return &Hub{
 		broadcast:  make(chan []byte),
+		direct:     make(chan userMessage),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
@@ -64,6 +68,21 @@ func (h *Hub) Run() {
 				}
 			}
 			h.mu.RUnlock()
+
+		case message := <-h.direct:
+			h.mu.Lock()
+			for client := range h.clients {
+				if client.userID != message.userID {
+					continue
+				}
+				select {
+				case client.send <- message.data:
+				default:
+					close(client.send)
+					delete(h.clients, client)
+				}
+			}
+			h.mu.Unlock()
 		}
 	}
 }
@@ -85,6 +104,29 @@ func (h *Hub) BroadcastMessage(eventType string, data interface{}) {
 	log.Printf("[WS] Broadcasting message type: %s to %d clients", eventType, len(h.clients))
 }
 
+// SendToUser sends a message only to the connections of the given user
+func (h *Hub) SendToUser(userID uint, eventType string, data interface{}) {
+	message := Message{
+		Type: eventType,
+		Data: data,
+	}
+
+	jsonMessage, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("[WS] Error marshaling message: %v", err)
+		return
+	}
+
+	h.direct <- userMessage{userID: userID, data: jsonMessage}
+	log.Printf("[WS] Sending message type: %s to user %d", eventType, userID)
+}
+
+// userMessage is a message addressed to the clients of one user
+type userMessage struct {
+	userID uint
+	data   []byte
+}
+
 // Message represents a WebSocket message
 type Message struct {
 	Type string      `json:"type"`